@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"mime"
+	"net/textproto"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// attachment describes a file attached to an email.
+type attachment struct {
+	Name        string
+	ContentType string
+	// ContentID is set on inline parts that HTML bodies refer to as cid:.
+	ContentID string
+}
+
+// attachmentFileName returns the decoded file name of a MIME part, or ""
+// when the part is not a file. mime.ParseMediaType already joins RFC 2231
+// continuations and decodes their charset-tagged filename* values; names
+// sent as encoded words (=?UTF-8?Q?...?=) are decoded here.
+func attachmentFileName(header textproto.MIMEHeader) string {
+	name := ""
+	if _, params, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		name = params["filename"]
+	}
+	if name == "" {
+		// Older mailers only set the name parameter of Content-Type.
+		if _, params, err := mime.ParseMediaType(header.Get("Content-Type")); err == nil {
+			name = params["name"]
+		}
+	}
+	if name == "" {
+		return ""
+	}
+	return sanitizeAttachmentName(decodeHeaderWords(name))
+}
+
+// isAttachment reports whether a MIME part is a file rather than a body.
+func isAttachment(header textproto.MIMEHeader) bool {
+	disposition, _, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	if disposition == "attachment" || attachmentFileName(header) != "" {
+		return true
+	}
+	// Inline images are often sent with only a Content-ID.
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	return header.Get("Content-ID") != "" && !strings.HasPrefix(mediaType, "text/")
+}
+
+// sanitizeAttachmentName keeps only the last path element of name and drops
+// control characters, so a crafted name cannot point outside the directory
+// the file is saved to.
+func sanitizeAttachmentName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." {
+		return "attachment"
+	}
+	return name
+}
+
+var cidImage = regexp.MustCompile(`(?i)<img\b[^>]*?\bsrc\s*=\s*["']?cid:([^"'\s>]+)["']?[^>]*>`)
+
+// replaceInlineImages swaps <img src="cid:..."> tags in an HTML body for a
+// readable placeholder naming the inline image, since terminals cannot show
+// the image itself.
+func replaceInlineImages(html string, attachments []attachment) string {
+	if html == "" {
+		return html
+	}
+	return cidImage.ReplaceAllStringFunc(html, func(tag string) string {
+		cid := cidImage.FindStringSubmatch(tag)[1]
+		if unescaped, err := url.PathUnescape(cid); err == nil {
+			cid = unescaped
+		}
+		for _, a := range attachments {
+			if a.ContentID != "" && strings.EqualFold(a.ContentID, cid) {
+				return fmt.Sprintf("[image: %s (%s)]", a.Name, a.ContentType)
+			}
+		}
+		return "[image]"
+	})
+}
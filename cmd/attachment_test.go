@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"net/textproto"
+	"testing"
+)
+
+func TestAttachmentFileName(t *testing.T) {
+	tests := []struct {
+		name        string
+		disposition string
+		contentType string
+		want        string
+	}{
+		{name: "plain", disposition: `attachment; filename="report.pdf"`, want: "report.pdf"},
+		{name: "RFC 2231", disposition: `attachment; filename*=UTF-8''r%C3%A9sum%C3%A9.pdf`, want: "résumé.pdf"},
+		{name: "RFC 2231 continuations", disposition: `attachment; filename*0*=UTF-8''%E6%97%A5%E6%9C%AC; filename*1=".txt"`, want: "日本.txt"},
+		{name: "encoded word", disposition: `attachment; filename="=?UTF-8?Q?Pr=C3=A9sentation.pptx?="`, want: "Présentation.pptx"},
+		{name: "encoded word in base64", disposition: `attachment; filename="=?UTF-8?B?5pel5pys6KqeLnR4dA==?="`, want: "日本語.txt"},
+		{name: "Content-Type name", contentType: `application/pdf; name="old.pdf"`, want: "old.pdf"},
+		{name: "path", disposition: `attachment; filename="../../.ssh/authorized_keys"`, want: "authorized_keys"},
+		{name: "windows path", disposition: `attachment; filename="C:\\Users\\me\\evil.exe"`, want: "evil.exe"},
+		{name: "dots", disposition: `attachment; filename=".."`, want: "attachment"},
+		{name: "no name", disposition: "attachment", want: ""},
+		{name: "inline body", disposition: "inline", contentType: "text/plain", want: ""},
+	}
+	for _, tt := range tests {
+		header := textproto.MIMEHeader{}
+		if tt.disposition != "" {
+			header.Set("Content-Disposition", tt.disposition)
+		}
+		if tt.contentType != "" {
+			header.Set("Content-Type", tt.contentType)
+		}
+		if got := attachmentFileName(header); got != tt.want {
+			t.Errorf("%s: attachmentFileName = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeAttachmentName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "notes.txt", want: "notes.txt"},
+		{name: "  spaced.txt ", want: "spaced.txt"},
+		{name: "bell\a\r\nname.txt", want: "bellname.txt"},
+		{name: "dir/", want: "attachment"},
+		{name: ".", want: "attachment"},
+		{name: "", want: "attachment"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeAttachmentName(tt.name); got != tt.want {
+			t.Errorf("sanitizeAttachmentName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsAttachment(t *testing.T) {
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   bool
+	}{
+		{name: "disposition", header: map[string]string{"Content-Disposition": "attachment"}, want: true},
+		{name: "file name", header: map[string]string{"Content-Type": `image/png; name="a.png"`}, want: true},
+		{name: "inline image", header: map[string]string{"Content-Type": "image/png", "Content-ID": "<logo@x>"}, want: true},
+		{name: "text body", header: map[string]string{"Content-Type": "text/plain"}},
+		{name: "html with an id", header: map[string]string{"Content-Type": "text/html", "Content-ID": "<body@x>"}},
+	}
+	for _, tt := range tests {
+		header := textproto.MIMEHeader{}
+		for key, value := range tt.header {
+			header.Set(key, value)
+		}
+		if got := isAttachment(header); got != tt.want {
+			t.Errorf("%s: isAttachment = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParsedEmailListsDecodedAttachmentNames(t *testing.T) {
+	raw := "From: alice@example.com\r\n" +
+		"Subject: Files\r\n" +
+		"Content-Type: multipart/mixed; boundary=b\r\n" +
+		"\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/plain\r\n\r\nSee attached\r\n" +
+		"--b\r\n" +
+		"Content-Type: application/pdf\r\n" +
+		"Content-Disposition: attachment; filename*=UTF-8''r%C3%A9sum%C3%A9.pdf\r\n\r\n%PDF\r\n" +
+		"--b\r\n" +
+		"Content-Type: application/octet-stream\r\n" +
+		"Content-Disposition: attachment; filename=\"=?UTF-8?Q?../../na=C3=AFve.bin?=\"\r\n\r\nxx\r\n" +
+		"--b--\r\n"
+	email, err := parseEmailBody(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, a := range email.Attachments {
+		names = append(names, a.Name)
+	}
+	if len(names) != 2 || names[0] != "résumé.pdf" || names[1] != "naïve.bin" {
+		t.Errorf("attachments = %q", names)
+	}
+	if email.TextBody != "See attached" {
+		t.Errorf("body = %q", email.TextBody)
+	}
+}
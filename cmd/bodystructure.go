@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"slices"
+	"strings"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// messageParts is what the reader needs from a message structure: where its
+// text and HTML bodies are, and the attachments, which are listed but only
+// downloaded with the full message.
+type messageParts struct {
+	contentType string
+	text, html  *bodyPart
+	attachments []attachment
+}
+
+type bodyPart struct {
+	path     []int
+	encoding string
+}
+
+// findParts walks structure like parseParts walks a full message: the first
+// text and HTML parts found are the bodies, files are attachments. Forwarded
+// messages are not descended into.
+func findParts(structure *imap.BodyStructure) messageParts {
+	parts := messageParts{contentType: strings.ToLower(structure.MIMEType + "/" + structure.MIMESubType)}
+	structure.Walk(func(path []int, part *imap.BodyStructure) bool {
+		mediaType := strings.ToLower(part.MIMEType + "/" + part.MIMESubType)
+		switch {
+		case strings.HasPrefix(mediaType, "multipart/"):
+			return true
+		case isAttachmentPart(part):
+			name, _ := part.Filename()
+			parts.attachments = append(parts.attachments, attachment{
+				Name:        sanitizeAttachmentName(name),
+				ContentType: mediaType,
+				ContentID:   strings.Trim(part.Id, "<> "),
+			})
+		case mediaType == "text/html" && parts.html == nil:
+			parts.html = &bodyPart{path: path, encoding: part.Encoding}
+		case mediaType == "text/plain" && parts.text == nil:
+			parts.text = &bodyPart{path: path, encoding: part.Encoding}
+		}
+		return false
+	})
+	return parts
+}
+
+// isAttachmentPart is isAttachment for a part of a message structure.
+func isAttachmentPart(part *imap.BodyStructure) bool {
+	if strings.EqualFold(part.Disposition, "attachment") {
+		return true
+	}
+	if name, _ := part.Filename(); name != "" {
+		return true
+	}
+	return part.Id != "" && !strings.EqualFold(part.MIMEType, "text")
+}
+
+// sections returns the sections to fetch for these parts, with the header
+// first.
+func (p messageParts) sections(peek bool) []*imap.BodySectionName {
+	sections := []*imap.BodySectionName{{BodyPartName: imap.BodyPartName{Specifier: imap.HeaderSpecifier}, Peek: peek}}
+	for _, part := range []*bodyPart{p.text, p.html} {
+		if part != nil {
+			sections = append(sections, &imap.BodySectionName{BodyPartName: imap.BodyPartName{Path: part.path}, Peek: peek})
+		}
+	}
+	return sections
+}
+
+// fetchEmailParts fetches what the reader shows of the messages with the
+// given UIDs: their BODYSTRUCTURE first, then only the header and the text
+// and HTML parts, so that large attachments are not downloaded to read an
+// email. Messages with no text part are fetched in full instead. Raw is
+// left empty; the full source is fetched when it is needed.
+func fetchEmailParts(imapClient *client.Client, uids []uint32, peek bool) (map[uint32]Email, error) {
+	structures, err := fetchBodyStructures(imapClient, uids)
+	if err != nil {
+		return nil, err
+	}
+
+	// Messages laid out the same way are fetched together
+	groups := make(map[string][]uint32)
+	var keys []string
+	var whole []uint32
+	found := make(map[uint32]messageParts, len(structures))
+	for _, uid := range uids {
+		structure, ok := structures[uid]
+		if !ok {
+			continue
+		}
+		parts := findParts(structure)
+		if parts.text == nil && parts.html == nil {
+			whole = append(whole, uid)
+			continue
+		}
+		found[uid] = parts
+		key := fmt.Sprint(parts.text, parts.html)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], uid)
+	}
+
+	bodies := make(map[uint32]Email, len(uids))
+	for _, key := range keys {
+		for batch := range slices.Chunk(groups[key], maxBodyBatch) {
+			sections := found[batch[0]].sections(peek)
+			items := []imap.FetchItem{imap.FetchUid}
+			for _, section := range sections {
+				items = append(items, section.FetchItem())
+			}
+			err := uidFetch(imapClient, batch, items, func(msg *imap.Message) error {
+				parts, ok := found[msg.Uid]
+				if !ok {
+					return nil
+				}
+				fetched := make([][]byte, len(sections))
+				for i, section := range sections {
+					if literal := msg.GetBody(section); literal != nil {
+						data, err := io.ReadAll(literal)
+						if err != nil {
+							return err
+						}
+						fetched[i] = data
+					}
+				}
+				bodies[msg.Uid] = partsEmail(parts, fetched)
+				return nil
+			})
+			if err != nil {
+				return bodies, err
+			}
+		}
+	}
+
+	if len(whole) > 0 {
+		full, err := fetchEmailBodies(imapClient, whole, peek)
+		for uid, email := range full {
+			bodies[uid] = email
+		}
+		if err != nil {
+			return bodies, err
+		}
+	}
+	return bodies, nil
+}
+
+// partsEmail builds the email from the sections fetched for parts: the
+// header, then the text and HTML parts that were found.
+func partsEmail(parts messageParts, fetched [][]byte) Email {
+	email := Email{
+		ContentType: parts.contentType,
+		RawHeader:   string(fetched[0]),
+		Attachments: parts.attachments,
+	}
+	next := 1
+	if parts.text != nil {
+		email.TextBody = string(decodeTransferEncoding(fetched[next], parts.text.encoding))
+		next++
+	}
+	if parts.html != nil {
+		email.HTMLBody = string(decodeTransferEncoding(fetched[next], parts.html.encoding))
+	}
+	email.HTMLBody = replaceInlineImages(email.HTMLBody, email.Attachments)
+	email.Body = cmp.Or(email.TextBody, email.HTMLBody)
+	return email
+}
+
+// decodeTransferEncoding undoes the Content-Transfer-Encoding of a part
+// fetched on its own, which the server sends still encoded. Data that does
+// not decode is kept as it is.
+func decodeTransferEncoding(data []byte, encoding string) []byte {
+	var r io.Reader
+	switch strings.ToLower(encoding) {
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, bytes.NewReader(data))
+	case "quoted-printable":
+		r = quotedprintable.NewReader(bytes.NewReader(data))
+	default:
+		return data
+	}
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		return data
+	}
+	return decoded
+}
+
+// fetchBodyStructures fetches the BODYSTRUCTURE of the messages with the
+// given UIDs, maxBodyBatch at a time.
+func fetchBodyStructures(imapClient *client.Client, uids []uint32) (map[uint32]*imap.BodyStructure, error) {
+	structures := make(map[uint32]*imap.BodyStructure, len(uids))
+	items := []imap.FetchItem{imap.FetchUid, imap.FetchBodyStructure}
+	for batch := range slices.Chunk(uids, maxBodyBatch) {
+		err := uidFetch(imapClient, batch, items, func(msg *imap.Message) error {
+			if msg.BodyStructure != nil {
+				structures[msg.Uid] = msg.BodyStructure
+			}
+			return nil
+		})
+		if err != nil {
+			return structures, err
+		}
+	}
+	return structures, nil
+}
+
+// uidFetch runs one UID FETCH of items for uids and calls handle for each
+// message returned. The first error handle returns is reported once the
+// command is done.
+func uidFetch(imapClient *client.Client, uids []uint32, items []imap.FetchItem, handle func(*imap.Message) error) error {
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(uids...)
+	messages := make(chan *imap.Message, len(uids))
+	done := make(chan error, 1)
+	go func() {
+		done <- imapClient.UidFetch(seqSet, items, messages)
+	}()
+	var handleErr error
+	for msg := range messages {
+		if handleErr == nil {
+			handleErr = handle(msg)
+		}
+	}
+	if err := <-done; err != nil {
+		logger.Printf("Error fetching messages: %v", err)
+		return err
+	}
+	return handleErr
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-imap"
+)
+
+func TestFindParts(t *testing.T) {
+	text := &imap.BodyStructure{MIMEType: "text", MIMESubType: "plain", Encoding: "quoted-printable"}
+	html := &imap.BodyStructure{MIMEType: "text", MIMESubType: "html", Encoding: "base64"}
+	pdf := &imap.BodyStructure{
+		MIMEType: "application", MIMESubType: "pdf", Encoding: "base64",
+		Disposition: "attachment", DispositionParams: map[string]string{"filename": "report.pdf"},
+	}
+	logo := &imap.BodyStructure{MIMEType: "image", MIMESubType: "png", Id: "<logo@example.com>"}
+	notes := &imap.BodyStructure{MIMEType: "text", MIMESubType: "plain", Disposition: "attachment", DispositionParams: map[string]string{"filename": "notes.txt"}}
+
+	tests := []struct {
+		name        string
+		structure   *imap.BodyStructure
+		contentType string
+		text, html  []int
+		attachments []string
+	}{
+		{
+			name:        "plain text",
+			structure:   text,
+			contentType: "text/plain",
+			text:        []int{1},
+		},
+		{
+			name: "alternative with a large attachment",
+			structure: &imap.BodyStructure{MIMEType: "multipart", MIMESubType: "mixed", Parts: []*imap.BodyStructure{
+				{MIMEType: "multipart", MIMESubType: "alternative", Parts: []*imap.BodyStructure{text, html}},
+				pdf,
+			}},
+			contentType: "multipart/mixed",
+			text:        []int{1, 1},
+			html:        []int{1, 2},
+			attachments: []string{"report.pdf"},
+		},
+		{
+			name: "inline image and a text attachment",
+			structure: &imap.BodyStructure{MIMEType: "multipart", MIMESubType: "related", Parts: []*imap.BodyStructure{
+				html, logo, notes,
+			}},
+			contentType: "multipart/related",
+			html:        []int{1},
+			attachments: []string{"attachment", "notes.txt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := findParts(tt.structure)
+			if parts.contentType != tt.contentType {
+				t.Errorf("contentType = %q, want %q", parts.contentType, tt.contentType)
+			}
+			for _, check := range []struct {
+				name string
+				part *bodyPart
+				want []int
+			}{{"text", parts.text, tt.text}, {"html", parts.html, tt.html}} {
+				switch {
+				case check.part == nil && check.want != nil:
+					t.Errorf("no %s part, want %v", check.name, check.want)
+				case check.part != nil && !slices.Equal(check.part.path, check.want):
+					t.Errorf("%s part at %v, want %v", check.name, check.part.path, check.want)
+				}
+			}
+			var names []string
+			for _, a := range parts.attachments {
+				names = append(names, a.Name)
+			}
+			if !slices.Equal(names, tt.attachments) {
+				t.Errorf("attachments = %q, want %q", names, tt.attachments)
+			}
+		})
+	}
+}
+
+func TestPartsEmail(t *testing.T) {
+	parts := messageParts{
+		contentType: "multipart/mixed",
+		text:        &bodyPart{path: []int{1, 1}, encoding: "quoted-printable"},
+		html:        &bodyPart{path: []int{1, 2}, encoding: "base64"},
+		attachments: []attachment{{Name: "report.pdf", ContentType: "application/pdf"}},
+	}
+	fetched := [][]byte{
+		[]byte("Subject: Report\r\n\r\n"),
+		[]byte("Caf=C3=A9 at noon"),
+		[]byte(base64.StdEncoding.EncodeToString([]byte("<p>Café at noon</p>"))),
+	}
+	email := partsEmail(parts, fetched)
+	if email.TextBody != "Café at noon" {
+		t.Errorf("TextBody = %q", email.TextBody)
+	}
+	if email.HTMLBody != "<p>Café at noon</p>" {
+		t.Errorf("HTMLBody = %q", email.HTMLBody)
+	}
+	if email.Body != email.TextBody {
+		t.Errorf("Body = %q, want the text part", email.Body)
+	}
+	if email.RawHeader != "Subject: Report\r\n\r\n" || email.ContentType != "multipart/mixed" {
+		t.Errorf("RawHeader, ContentType = %q, %q", email.RawHeader, email.ContentType)
+	}
+	if len(email.Attachments) != 1 || email.Attachments[0].Name != "report.pdf" {
+		t.Errorf("Attachments = %+v", email.Attachments)
+	}
+
+	// HTML only
+	parts.text = nil
+	email = partsEmail(parts, [][]byte{fetched[0], fetched[2]})
+	if email.Body != "<p>Café at noon</p>" {
+		t.Errorf("Body = %q, want the HTML part", email.Body)
+	}
+}
+
+func TestDecodeTransferEncoding(t *testing.T) {
+	tests := []struct {
+		encoding string
+		data     string
+		want     string
+	}{
+		{encoding: "7bit", data: "plain", want: "plain"},
+		{encoding: "BASE64", data: "aGVsbG8=", want: "hello"},
+		{encoding: "quoted-printable", data: "a=3Db", want: "a=b"},
+		{encoding: "base64", data: "not base64!", want: "not base64!"},
+	}
+	for _, tt := range tests {
+		if got := string(decodeTransferEncoding([]byte(tt.data), tt.encoding)); got != tt.want {
+			t.Errorf("decodeTransferEncoding(%q, %q) = %q, want %q", tt.data, tt.encoding, got, tt.want)
+		}
+	}
+}
+
+func TestFetchEmailPartsLeavesAttachments(t *testing.T) {
+	attachmentData := base64.StdEncoding.EncodeToString(make([]byte, 1<<20))
+	var wrapped strings.Builder
+	for chunk := range slices.Chunk([]byte(attachmentData), 76) {
+		wrapped.Write(chunk)
+		wrapped.WriteString("\r\n")
+	}
+	multipart := testMessage("Report", `multipart/mixed; boundary="outer"`,
+		"--outer\r\n"+
+			"Content-Type: multipart/alternative; boundary=\"inner\"\r\n\r\n"+
+			"--inner\r\nContent-Type: text/plain; charset=utf-8\r\n\r\nSee attached\r\n"+
+			"--inner\r\nContent-Type: text/html; charset=utf-8\r\n\r\n<p>See attached</p>\r\n"+
+			"--inner--\r\n"+
+			"--outer\r\nContent-Type: application/octet-stream\r\n"+
+			"Content-Disposition: attachment; filename=\"big.bin\"\r\n"+
+			"Content-Transfer-Encoding: base64\r\n\r\n"+wrapped.String()+
+			"--outer--\r\n")
+	c, _ := newMockIMAP(t, multipart, testMessage("Plain", "text/plain", "Just text\r\n"))
+
+	bodies, err := fetchEmailParts(c, []uint32{7, 8}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := bodies[7]
+	if strings.TrimSpace(report.TextBody) != "See attached" || strings.TrimSpace(report.HTMLBody) != "<p>See attached</p>" {
+		t.Errorf("bodies = %q, %q", report.TextBody, report.HTMLBody)
+	}
+	if len(report.Attachments) != 1 || report.Attachments[0].Name != "big.bin" {
+		t.Errorf("Attachments = %+v", report.Attachments)
+	}
+	if report.Raw != "" || strings.Contains(report.RawHeader, "AAAA") {
+		t.Error("the attachment was downloaded")
+	}
+	if !strings.Contains(report.RawHeader, "Subject: Report") {
+		t.Errorf("RawHeader = %q", report.RawHeader)
+	}
+	if got := strings.TrimSpace(bodies[8].Body); got != "Just text" {
+		t.Errorf("plain email body = %q", got)
+	}
+}
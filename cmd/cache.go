@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// envelopeCache is the on-disk copy of the newest envelopes of a mailbox,
+// used to show the list right away while the server is queried.
+type envelopeCache struct {
+	UIDValidity   uint32  `json:"uid_validity"`
+	TotalMessages uint32  `json:"total_messages"`
+	Emails        []Email `json:"emails"`
+}
+
+// envelopeCachePath returns the cache file for one account and mailbox.
+func envelopeCachePath(config imapConfig, mailbox string) (string, error) {
+	dir, err := configSubdir("cache")
+	if err != nil {
+		return "", err
+	}
+	key := sha256.Sum256([]byte(config.username + "@" + config.host + ":" + config.port + "/" + mailbox))
+	return filepath.Join(dir, hex.EncodeToString(key[:8])+".json"), nil
+}
+
+// loadEnvelopeCache reads the cached envelopes of mailbox. It reports false
+// when there is no usable cache.
+func loadEnvelopeCache(config imapConfig, mailbox string) (envelopeCache, bool) {
+	var cache envelopeCache
+	path, err := envelopeCachePath(config, mailbox)
+	if err != nil {
+		return cache, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cache, false
+	}
+	if err := json.Unmarshal(data, &cache); err != nil || len(cache.Emails) == 0 {
+		return cache, false
+	}
+	return cache, true
+}
+
+// saveEnvelopeCache stores up to limit envelopes of mailbox, without their
+// bodies. The cache is replaced as a whole, so a changed UIDVALIDITY simply
+// overwrites envelopes whose UIDs are no longer valid.
+func saveEnvelopeCache(config imapConfig, mailbox string, uidValidity, totalMessages uint32, emails []Email, limit int) error {
+	path, err := envelopeCachePath(config, mailbox)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("could not create cache directory: %w", err)
+	}
+
+	cache := envelopeCache{UIDValidity: uidValidity, TotalMessages: totalMessages}
+	for _, email := range emails[:min(len(emails), limit)] {
+		email.Body, email.HTMLBody, email.TextBody, email.ContentType, email.Raw, email.RawHeader = "", "", "", "", "", ""
+		email.Attachments = nil
+		cache.Emails = append(cache.Emails, email)
+	}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestCachedEnvelopesUIDValidity(t *testing.T) {
+	tests := []struct {
+		name        string
+		uidValidity uint32
+		wantMarked  bool
+	}{
+		// The memory backend's UIDVALIDITY is 1
+		{name: "same UIDVALIDITY", uidValidity: 1, wantMarked: true},
+		{name: "mailbox recreated", uidValidity: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newMockIMAP(t)
+			a := NewApp(imapConfig{inbox: "INBOX"}, readOptions{perPage: 20})
+			a.client = c
+			a.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+
+			cached := []Email{{UID: 6, Subject: "Cached", Date: time.Now()}}
+			a.Update(emailsLoadedMsg{emails: cached, totalMessages: 1, fromCache: true, uidValidity: tt.uidValidity})
+			if !a.syncing {
+				t.Fatal("cached envelopes are not shown as syncing")
+			}
+			a.marked[6] = true
+
+			// Cached UIDs are not acted on until the server confirms them
+			pressKey(t, a, "enter")
+			if a.state != listView {
+				t.Errorf("opened a cached email before the sync, state = %v", a.state)
+			}
+			if a.successMessage == "" {
+				t.Error("no toast explains why the email did not open")
+			}
+
+			runCmd(t, a, a.loadEmails(1, false))
+			if a.syncing {
+				t.Error("still syncing after the server answered")
+			}
+			if a.uidValidity != 1 {
+				t.Errorf("uidValidity = %d, want the server's 1", a.uidValidity)
+			}
+			if i := a.findEmail(6); i < 0 || a.emails[i].Subject == "Cached" {
+				t.Error("the list still shows the cached envelope")
+			}
+			if a.marked[6] != tt.wantMarked {
+				t.Errorf("marked[6] = %v, want %v", a.marked[6], tt.wantMarked)
+			}
+
+			pressKey(t, a, "enter")
+			if a.state != emailView {
+				t.Errorf("state = %v after the sync, want the email view", a.state)
+			}
+		})
+	}
+}
+
+func TestEnvelopeCacheRoundTrip(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	config := imapConfig{host: "imap.example.com", port: "993", username: "me"}
+
+	if _, ok := loadEnvelopeCache(config, "INBOX"); ok {
+		t.Fatal("found a cache before saving one")
+	}
+	emails := []Email{
+		{UID: 3, Subject: "Three", Body: "body", Raw: "raw"},
+		{UID: 2, Subject: "Two"},
+		{UID: 1, Subject: "One"},
+	}
+	if err := saveEnvelopeCache(config, "INBOX", 42, 3, emails, 2); err != nil {
+		t.Fatal(err)
+	}
+	cache, ok := loadEnvelopeCache(config, "INBOX")
+	if !ok {
+		t.Fatal("the saved cache is not found")
+	}
+	if cache.UIDValidity != 42 || cache.TotalMessages != 3 {
+		t.Errorf("UIDValidity, TotalMessages = %d, %d, want 42, 3", cache.UIDValidity, cache.TotalMessages)
+	}
+	if len(cache.Emails) != 2 || cache.Emails[0].UID != 3 {
+		t.Fatalf("cached emails = %+v, want the 2 newest", cache.Emails)
+	}
+	if cache.Emails[0].Body != "" || cache.Emails[0].Raw != "" {
+		t.Error("the cache kept a body")
+	}
+	if _, ok := loadEnvelopeCache(config, "Archive"); ok {
+		t.Error("another mailbox shares the cache")
+	}
+}
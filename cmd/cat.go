@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+var Cat = &cli.Command{
+	Name:      "cat",
+	Usage:     "Print a single email to stdout",
+	ArgsUsage: "<uid>",
+	Flags: append(imapFlags(),
+		themeFlag(),
+		&cli.StringFlag{
+			Name:  "mailbox",
+			Usage: "mailbox containing the email (default: the --inbox mailbox)",
+		},
+		&cli.BoolFlag{
+			Name:  "raw",
+			Usage: "print the original RFC822 source",
+		},
+		&cli.BoolFlag{
+			Name:  "html",
+			Usage: "print the HTML part instead of the text body",
+		},
+	),
+	Action: func(ctx context.Context, c *cli.Command) error {
+		if c.Args().Len() != 1 {
+			return fmt.Errorf("usage: cleu cat <uid>")
+		}
+		uid, err := strconv.ParseUint(c.Args().First(), 10, 32)
+		if err != nil || uid == 0 {
+			return fmt.Errorf("invalid UID %q", c.Args().First())
+		}
+
+		if err := applyTheme(c.String("theme")); err != nil {
+			return err
+		}
+		config, err := loadIMAPConfig(c)
+		if err != nil {
+			return err
+		}
+
+		imapClient, err := connectToServer(config)
+		if err != nil {
+			return err
+		}
+		defer imapClient.Logout()
+
+		mailbox := mailboxOrInbox(c, config)
+		if _, err := imapClient.Select(mailbox, true); err != nil {
+			return fmt.Errorf("failed to select %s: %w", mailbox, err)
+		}
+
+		email, err := fetchEmailBodyParsed(imapClient, uint32(uid), false)
+		if err != nil {
+			return fmt.Errorf("could not load email with UID %d from %s: %w", uid, mailbox, err)
+		}
+
+		switch {
+		case c.Bool("raw"):
+			_, err = os.Stdout.WriteString(email.Raw)
+			return err
+		case c.Bool("html"):
+			if email.HTMLBody == "" {
+				return fmt.Errorf("email %d has no HTML part", uid)
+			}
+			_, err = fmt.Println(email.HTMLBody)
+			return err
+		}
+
+		fmt.Print(formatHeadersForCat(email.Raw))
+		fmt.Println(strings.TrimSpace(email.Body))
+		return nil
+	},
+}
+
+// formatHeadersForCat returns the main headers of a raw message, decoded and
+// followed by a blank line.
+func formatHeadersForCat(raw string) string {
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		return ""
+	}
+
+	decoder := new(mime.WordDecoder)
+	var headers strings.Builder
+	for _, name := range []string{"From", "To", "Cc", "Date", "Subject"} {
+		value := msg.Header.Get(name)
+		if value == "" {
+			continue
+		}
+		if decoded, err := decoder.DecodeHeader(value); err == nil {
+			value = decoded
+		}
+		headers.WriteString(fmt.Sprintf("%s: %s\n", name, value))
+	}
+	headers.WriteString("\n")
+	return headers.String()
+}
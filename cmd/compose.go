@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The fields of the compose view, in tab order.
+const (
+	composeTo = iota
+	composeCc
+	composeSubject
+	composeBody
+	composeFields
+)
+
+// composeModel is the compose view of the read TUI: inputs for the headers
+// and a textarea for the body, sent without leaving the interface.
+type composeModel struct {
+	inputs   []textinput.Model // To, Cc and Subject
+	body     textarea.Model
+	focus    int
+	headers  []string // In-Reply-To and References when replying
+	original uint32   // the email being replied to or forwarded, 0 otherwise
+	forward  bool     // original is being forwarded rather than replied to
+	sending  bool
+	err      error
+}
+
+// composeSentMsg reports how sending the composed email went, and which
+// email it replied to or forwarded.
+type composeSentMsg struct {
+	err      error
+	original uint32
+	forward  bool
+	dryRun   string // what --dry-run printed, nothing was sent
+}
+
+func newCompose(to, subject, body string, headers []string) composeModel {
+	m := composeModel{headers: headers}
+	for i, prompt := range []string{"To: ", "Cc: ", "Subject: "} {
+		input := textinput.New()
+		input.Prompt = fromStyle.Render(prompt)
+		m.inputs = append(m.inputs, input)
+		if i == composeTo {
+			m.inputs[i].SetValue(to)
+		}
+	}
+	m.inputs[composeCc].Placeholder = "optional"
+	m.inputs[composeSubject].SetValue(subject)
+
+	m.body = textarea.New()
+	m.body.ShowLineNumbers = false
+	m.body.CharLimit = 0
+	m.body.Placeholder = "Write your email..."
+	m.body.SetValue(body)
+	// SetValue leaves the cursor at the end, start typing above the quote
+	for m.body.Line() > 0 {
+		m.body.CursorUp()
+	}
+	m.body.CursorStart()
+
+	// Replies start in the body, new emails with the recipient
+	if to != "" {
+		m.focusField(composeBody)
+	} else {
+		m.focusField(composeTo)
+	}
+	return m
+}
+
+func (m *composeModel) setSize(width, height int) {
+	for i := range m.inputs {
+		m.inputs[i].Width = max(width-12, 10)
+	}
+	m.body.SetWidth(max(width-2, 10))
+	// Headers, blank lines, error, status bar and help
+	m.body.SetHeight(max(height-9, 3))
+}
+
+func (m *composeModel) focusField(field int) tea.Cmd {
+	m.focus = field
+	for i := range m.inputs {
+		m.inputs[i].Blur()
+	}
+	m.body.Blur()
+	if field == composeBody {
+		return m.body.Focus()
+	}
+	return m.inputs[field].Focus()
+}
+
+func (m composeModel) update(msg tea.Msg) (composeModel, tea.Cmd) {
+	var cmd tea.Cmd
+	if m.focus == composeBody {
+		m.body, cmd = m.body.Update(msg)
+	} else {
+		m.inputs[m.focus], cmd = m.inputs[m.focus].Update(msg)
+	}
+	return m, cmd
+}
+
+// email returns what was typed as an email ready to send.
+func (m composeModel) email() *EmailForm {
+	return &EmailForm{
+		To:      strings.TrimSpace(m.inputs[composeTo].Value()),
+		Cc:      strings.TrimSpace(m.inputs[composeCc].Value()),
+		Subject: strings.TrimSpace(m.inputs[composeSubject].Value()),
+		Body:    m.body.Value(),
+		Headers: m.headers,
+		Confirm: true,
+	}
+}
+
+func (m composeModel) view(from string) string {
+	var content strings.Builder
+	content.WriteString(subjectStyle.Render("✉️  New Email") + "  " + dateStyle.Render("from "+from) + "\n\n")
+	for _, input := range m.inputs {
+		content.WriteString(input.View() + "\n")
+	}
+	content.WriteString("\n" + m.body.View() + "\n")
+	switch {
+	case m.sending:
+		content.WriteString(loadingStyle.Render("Sending..."))
+	case m.err != nil:
+		content.WriteString(errorStyle.Render("❌ " + m.err.Error()))
+	}
+	return content.String()
+}
+
+// validateComposed checks what the compose view needs before sending,
+// with the same rules as the send form.
+func validateComposed(email *EmailForm, maxRecipients int) error {
+	if email.To == "" {
+		return fmt.Errorf("add at least one recipient")
+	}
+	if email.Subject == "" {
+		return fmt.Errorf("the subject is required")
+	}
+	if strings.TrimSpace(email.Body) == "" {
+		return fmt.Errorf("the body is required")
+	}
+	for _, check := range []error{
+		validateRecipients("To", email.To),
+		validateRecipients("Cc", email.Cc),
+		validateHeaderValue("Subject", email.Subject),
+	} {
+		if check != nil {
+			return check
+		}
+	}
+	if count := recipientCount(email); exceedsRecipientLimit(count, maxRecipients) {
+		return fmt.Errorf("this email has %d recipients, more than --max-recipients %d; send it with cleu send to confirm", count, maxRecipients)
+	}
+	return nil
+}
+
+// sendComposed sends email in the background. Results are not printed, the
+// TUI owns the terminal; with --dry-run what would be sent is returned to be
+// shown in the pager.
+func sendComposed(email *EmailForm, config smtpConfig, original uint32, forward bool) tea.Cmd {
+	return func() tea.Msg {
+		session := newSMTPSession(config)
+		session.out = io.Discard
+		var output strings.Builder
+		if config.dryRun {
+			session.out = &output
+		}
+		defer session.close()
+		if err := session.sendEmail(email); err != nil {
+			return composeSentMsg{err: err}
+		}
+		if config.dryRun {
+			return composeSentMsg{dryRun: output.String()}
+		}
+		return composeSentMsg{original: original, forward: forward}
+	}
+}
+
+var (
+	rePrefix  = regexp.MustCompile(`(?i)^\s*re\s*:`)
+	fwdPrefix = regexp.MustCompile(`(?i)^\s*fwd?\s*:`)
+)
+
+// replyCompose prefills a reply to email: the sender as recipient, "Re:" in
+// front of the subject, the original quoted below and the threading headers.
+func replyCompose(email Email) composeModel {
+	to := email.FromAddress
+	if to != "" && email.FromName != "" {
+		to = (&mail.Address{Name: email.FromName, Address: email.FromAddress}).String()
+	}
+	subject := email.Subject
+	if !rePrefix.MatchString(subject) {
+		subject = "Re: " + subject
+	}
+
+	var body strings.Builder
+	body.WriteString("\n\n")
+	fmt.Fprintf(&body, "On %s, %s wrote:\n", email.Date.Format("Mon, Jan 2, 2006 at 15:04"), email.From)
+	for _, line := range strings.Split(strings.TrimRight(cleanupWhitespace(quotableBody(email)), "\n"), "\n") {
+		if strings.HasPrefix(line, ">") {
+			body.WriteString(">" + line + "\n")
+		} else {
+			body.WriteString("> " + line + "\n")
+		}
+	}
+
+	var headers []string
+	if email.MessageID != "" {
+		headers = append(headers,
+			"In-Reply-To: "+email.MessageID,
+			"References: "+strings.Join(append(append([]string{}, email.References...), email.MessageID), " "))
+	}
+	m := newCompose(to, subject, body.String(), headers)
+	m.original = email.UID
+	return m
+}
+
+// forwardCompose prefills a forward of email, with its headers and body
+// below the space left for a note.
+func forwardCompose(email Email) composeModel {
+	subject := email.Subject
+	if !fwdPrefix.MatchString(subject) {
+		subject = "Fwd: " + subject
+	}
+
+	var body strings.Builder
+	body.WriteString("\n\n---------- Forwarded message ----------\n")
+	from := email.From
+	if email.FromAddress != "" && email.FromAddress != from {
+		from += " <" + email.FromAddress + ">"
+	}
+	fmt.Fprintf(&body, "From: %s\n", from)
+	fmt.Fprintf(&body, "Date: %s\n", email.Date.Format("Mon, Jan 2, 2006 at 15:04"))
+	fmt.Fprintf(&body, "Subject: %s\n", email.Subject)
+	if email.To != "" {
+		fmt.Fprintf(&body, "To: %s\n", email.To)
+	}
+	body.WriteString("\n" + cleanupWhitespace(quotableBody(email)))
+
+	m := newCompose("", subject, body.String(), nil)
+	m.original, m.forward = email.UID, true
+	return m
+}
+
+// quotableBody returns the body of email as text for a reply or a forward:
+// the text part, or else the HTML part rendered to text.
+func quotableBody(email Email) string {
+	if email.TextBody != "" {
+		return email.TextBody
+	}
+	if email.HTMLBody != "" {
+		return htmlToText(email.HTMLBody)
+	}
+	return email.Body
+}
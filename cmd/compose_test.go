@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap"
+)
+
+// testMessage returns a raw message from alice dated now.
+func testMessage(subject, contentType, body string) string {
+	return "From: Alice <alice@example.com>\r\n" +
+		"To: me@example.com\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"Message-ID: <1@example.com>\r\n" +
+		"Content-Type: " + contentType + "\r\n" +
+		"\r\n" + body
+}
+
+func TestSendingMarksTheOriginal(t *testing.T) {
+	tests := []struct {
+		name         string
+		key          string // "a" replies, "f" forwards
+		readOnly     bool
+		wantAnswered bool
+		wantFlag     bool
+	}{
+		{name: "reply", key: "a", wantAnswered: true, wantFlag: true},
+		{name: "reply read-only", key: "a", readOnly: true},
+		{name: "forward", key: "f", wantFlag: true},
+		{name: "forward read-only", key: "f", readOnly: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, user := newMockIMAP(t, testMessage("Lunch", "text/plain", "Are you free?\r\n"))
+			smtpServer, smtp := newMockSMTP(t)
+			a := newTestApp(t, c, readOptions{smtp: smtp, readOnly: tt.readOnly})
+
+			if !a.selectUID(7) {
+				t.Fatal("email 7 is not listed")
+			}
+			pressKey(t, a, "enter")
+			pressKey(t, a, tt.key)
+			if a.state != composeView {
+				t.Fatalf("state = %v, want the compose view", a.state)
+			}
+			if tt.key == "f" {
+				a.compose.inputs[composeTo].SetValue("bob@example.com")
+			}
+			pressKey(t, a, "ctrl+s")
+
+			if got := len(smtpServer.received()); got != 1 {
+				t.Fatalf("the server got %d messages, want 1", got)
+			}
+			flag := imap.AnsweredFlag
+			if tt.key == "f" {
+				flag = forwardedFlag
+			}
+			// Keywords are case-insensitive, the mock server lowercases them
+			if got := slices.Contains(serverFlags(t, user, 7), imap.CanonicalFlag(flag)); got != tt.wantFlag {
+				t.Errorf("%s on the server = %v, want %v", flag, got, tt.wantFlag)
+			}
+			if tt.key == "f" && slices.Contains(serverFlags(t, user, 7), imap.AnsweredFlag) {
+				t.Error("forwarding marked the original as answered")
+			}
+			if i := a.findEmail(7); a.emails[i].Answered != tt.wantAnswered {
+				t.Errorf("Answered on the loaded email = %v, want %v", a.emails[i].Answered, tt.wantAnswered)
+			}
+		})
+	}
+}
+
+func TestReplyCompose(t *testing.T) {
+	email := Email{
+		UID:         7,
+		Subject:     "Lunch",
+		From:        "Alice",
+		FromName:    "Alice",
+		FromAddress: "alice@example.com",
+		MessageID:   "<1@example.com>",
+		References:  []string{"<0@example.com>"},
+		Body:        "Are you free?",
+	}
+	m := replyCompose(email)
+	if got := m.inputs[composeTo].Value(); got != `"Alice" <alice@example.com>` {
+		t.Errorf("To = %q", got)
+	}
+	if got := m.inputs[composeSubject].Value(); got != "Re: Lunch" {
+		t.Errorf("Subject = %q", got)
+	}
+	if !strings.Contains(m.body.Value(), "> Are you free?") {
+		t.Errorf("body does not quote the original:\n%s", m.body.Value())
+	}
+	if m.original != 7 || m.forward {
+		t.Errorf("original = %d, forward = %v, want a reply to 7", m.original, m.forward)
+	}
+	want := []string{"In-Reply-To: <1@example.com>", "References: <0@example.com> <1@example.com>"}
+	if !slices.Equal(m.headers, want) {
+		t.Errorf("headers = %q, want %q", m.headers, want)
+	}
+
+	// Replying again does not stack prefixes
+	email.Subject = "RE: Lunch"
+	if got := replyCompose(email).inputs[composeSubject].Value(); got != "RE: Lunch" {
+		t.Errorf("Subject = %q", got)
+	}
+}
+
+func TestComposeDryRun(t *testing.T) {
+	c, user := newMockIMAP(t, testMessage("Lunch", "text/plain", "Are you free?\r\n"))
+	smtpServer, smtp := newMockSMTP(t)
+	smtp.dryRun = true
+	a := newTestApp(t, c, readOptions{smtp: smtp})
+
+	if !a.selectUID(7) {
+		t.Fatal("email 7 is not listed")
+	}
+	pressKey(t, a, "enter")
+	pressKey(t, a, "a")
+	pressKey(t, a, "ctrl+s")
+
+	if a.state != listView {
+		t.Errorf("state = %v, want the list", a.state)
+	}
+	if a.successMessage != "Dry run, nothing sent" {
+		t.Errorf("toast = %q", a.successMessage)
+	}
+	if got := len(smtpServer.received()); got != 0 {
+		t.Errorf("the server got %d messages in a dry run", got)
+	}
+	if slices.Contains(serverFlags(t, user, 7), imap.AnsweredFlag) {
+		t.Error("a dry run marked the original as answered")
+	}
+}
+
+func TestQuotableBody(t *testing.T) {
+	tests := []struct {
+		name  string
+		email Email
+		want  string
+	}{
+		{
+			name:  "text part",
+			email: Email{Body: "Hi", TextBody: "Hi", HTMLBody: "<p>Hello</p>"},
+			want:  "Hi",
+		},
+		{
+			name:  "HTML only",
+			email: Email{Body: "<p>Hello <b>you</b></p><p>Bye</p>", HTMLBody: "<p>Hello <b>you</b></p><p>Bye</p>"},
+			want:  "Hello you\nBye",
+		},
+		{
+			name:  "no parts",
+			email: Email{Body: "Plain"},
+			want:  "Plain",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quotableBody(tt.email); got != tt.want {
+				t.Errorf("quotableBody() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	m := replyCompose(Email{HTMLBody: "<div>Hello</div>", Body: "<div>Hello</div>"})
+	if body := m.body.Value(); !strings.Contains(body, "> Hello\n") || strings.Contains(body, "<div>") {
+		t.Errorf("reply to an HTML-only email quotes:\n%s", body)
+	}
+}
+
+func TestComposeAllowPlaceholders(t *testing.T) {
+	tests := []struct {
+		name     string
+		allow    bool
+		wantSent int
+	}{
+		{name: "refused by default"},
+		{name: "allowed with --allow-placeholders", allow: true, wantSent: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			c, _ := newMockIMAP(t)
+			smtpServer, smtp := newMockSMTP(t)
+			a := newTestApp(t, c, readOptions{smtp: smtp, allowPlaceholders: tt.allow})
+
+			pressKey(t, a, "c")
+			a.compose.inputs[composeTo].SetValue("bob@example.com")
+			a.compose.inputs[composeSubject].SetValue("Hello {{.Name}}")
+			a.compose.body.SetValue("Hi")
+			pressKey(t, a, "ctrl+s")
+
+			if got := len(smtpServer.received()); got != tt.wantSent {
+				t.Errorf("the server got %d messages, want %d", got, tt.wantSent)
+			}
+			if tt.wantSent == 0 && (a.compose.err == nil || !strings.Contains(a.compose.err.Error(), "{{.Name}}")) {
+				t.Errorf("compose error = %v, want it to name the placeholder", a.compose.err)
+			}
+		})
+	}
+}
+
+func TestComposeEditsTheBodyInTheEditor(t *testing.T) {
+	t.Setenv("EDITOR", "sed -i s/Hello/Bye/")
+	body, err := editInEditor("Hello Bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "Bye Bob" {
+		t.Errorf("edited body = %q, want %q", body, "Bye Bob")
+	}
+
+	c, _ := newMockIMAP(t)
+	a := newTestApp(t, c, readOptions{})
+	pressKey(t, a, "c")
+	a.compose.body.SetValue("Hello")
+	a.Update(editorClosedMsg{err: errors.New("editor vi failed")})
+	if a.compose.err == nil || a.compose.body.Value() != "Hello" {
+		t.Errorf("after a failed edit: err = %v, body = %q", a.compose.err, a.compose.body.Value())
+	}
+	a.Update(editorClosedMsg{body: body})
+	if a.compose.err != nil || a.compose.body.Value() != "Bye Bob" || a.compose.focus != composeBody {
+		t.Errorf("after editing: err = %v, body = %q, focus = %d", a.compose.err, a.compose.body.Value(), a.compose.focus)
+	}
+}
+
+func TestComposeEscKeepsChangedEmails(t *testing.T) {
+	tests := []struct {
+		name      string
+		edit      func(m *composeModel)
+		wantDraft bool
+	}{
+		{name: "untouched", edit: func(m *composeModel) {}},
+		{name: "recipient", edit: func(m *composeModel) { m.inputs[composeTo].SetValue("bob@example.com") }, wantDraft: true},
+		{name: "cc", edit: func(m *composeModel) { m.inputs[composeCc].SetValue("carol@example.com") }, wantDraft: true},
+		{name: "subject", edit: func(m *composeModel) { m.inputs[composeSubject].SetValue("Hello") }, wantDraft: true},
+		{name: "body", edit: func(m *composeModel) { m.body.SetValue("Hi") }, wantDraft: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			c, _ := newMockIMAP(t)
+			a := newTestApp(t, c, readOptions{})
+
+			pressKey(t, a, "c")
+			tt.edit(&a.compose)
+			pressKey(t, a, "esc")
+
+			drafts, err := loadDrafts()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(drafts) == 1; got != tt.wantDraft {
+				t.Errorf("%d drafts saved, want a draft: %v", len(drafts), tt.wantDraft)
+			}
+		})
+	}
+}
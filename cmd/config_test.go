@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+// loadConfigsWith runs a command with flags and args and returns the
+// configurations it loads.
+func loadConfigsWith(t *testing.T, flags []cli.Flag, args ...string) (imapConfig, smtpConfig) {
+	t.Helper()
+	imap, smtp, err := loadConfigs(flags, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return imap, smtp
+}
+
+// loadConfigs is loadConfigsWith returning the error instead of failing.
+func loadConfigs(flags []cli.Flag, args ...string) (imapConfig, smtpConfig, error) {
+	var imap imapConfig
+	var smtp smtpConfig
+	cmd := &cli.Command{
+		Name:  "test",
+		Flags: flags,
+		Action: func(ctx context.Context, c *cli.Command) error {
+			var err error
+			if c.Value("timeout") != nil {
+				if imap, err = loadIMAPConfig(c); err != nil {
+					return err
+				}
+			}
+			smtp, err = loadSMTPConfig(c)
+			return err
+		},
+	}
+	err := cmd.Run(context.Background(), append([]string{"test"}, args...))
+	return imap, smtp, err
+}
+
+func setServerEnv(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	for name, value := range map[string]string{
+		"IMAP_HOST": "imap.example.com", "IMAP_PORT": "993", "IMAP_USERNAME": "me", "IMAP_PASSWORD": "secret",
+		"SMTP_HOST": "smtp.example.com", "SMTP_PORT": "465", "SMTP_USERNAME": "me", "SMTP_PASSWORD": "secret",
+	} {
+		t.Setenv(name, value)
+	}
+	for _, name := range []string{"CLEU_DIAL_TIMEOUT", "CLEU_TIMEOUT", "CLEU_SMTP_DIAL_TIMEOUT", "CLEU_PROXY"} {
+		// Registered for restoring, then removed for the test
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+func TestSMTPDialTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		flags    []cli.Flag
+		env      map[string]string
+		args     []string
+		wantIMAP time.Duration
+		wantSMTP time.Duration
+	}{
+		{name: "send default", flags: smtpFlags(), wantSMTP: 10 * time.Second},
+		{name: "send env", flags: smtpFlags(), env: map[string]string{"CLEU_SMTP_DIAL_TIMEOUT": "3s"}, wantSMTP: 3 * time.Second},
+		{name: "read default", flags: imapAndSMTPFlags(), wantIMAP: 10 * time.Second, wantSMTP: 10 * time.Second},
+		{
+			name:     "read SMTP env",
+			flags:    imapAndSMTPFlags(),
+			env:      map[string]string{"CLEU_SMTP_DIAL_TIMEOUT": "3s"},
+			wantIMAP: 10 * time.Second, wantSMTP: 3 * time.Second,
+		},
+		{
+			name:     "read --dial-timeout covers both",
+			flags:    imapAndSMTPFlags(),
+			args:     []string{"--dial-timeout", "5s"},
+			wantIMAP: 5 * time.Second, wantSMTP: 5 * time.Second,
+		},
+		{
+			name:     "read both flags",
+			flags:    imapAndSMTPFlags(),
+			args:     []string{"--dial-timeout", "5s", "--smtp-dial-timeout", "4s"},
+			wantIMAP: 5 * time.Second, wantSMTP: 4 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setServerEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			imap, smtp := loadConfigsWith(t, tt.flags, tt.args...)
+			if imap.dialTimeout != tt.wantIMAP {
+				t.Errorf("IMAP dial timeout = %s, want %s", imap.dialTimeout, tt.wantIMAP)
+			}
+			if smtp.dialTimeout != tt.wantSMTP {
+				t.Errorf("SMTP dial timeout = %s, want %s", smtp.dialTimeout, tt.wantSMTP)
+			}
+		})
+	}
+}
+
+func TestMissingCredentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   []cli.Flag
+		env     map[string]string
+		unset   []string
+		wantErr string
+	}{
+		{name: "IMAP password", flags: imapAndSMTPFlags(), unset: []string{"IMAP_PASSWORD"}, wantErr: "IMAP_PASSWORD_CMD"},
+		{name: "IMAP username", flags: imapAndSMTPFlags(), unset: []string{"IMAP_USERNAME"}, wantErr: "IMAP_USERNAME_CMD"},
+		{name: "SMTP password", flags: smtpFlags(), unset: []string{"SMTP_PASSWORD"}, wantErr: "SMTP_PASSWORD_CMD"},
+		{name: "SMTP username", flags: smtpFlags(), unset: []string{"SMTP_USERNAME"}, wantErr: "SMTP_USERNAME_CMD"},
+		{name: "IMAP password command", flags: imapAndSMTPFlags(), unset: []string{"IMAP_PASSWORD"}, env: map[string]string{"IMAP_PASSWORD_CMD": "echo secret"}},
+		{name: "SMTP password command", flags: smtpFlags(), unset: []string{"SMTP_PASSWORD"}, env: map[string]string{"SMTP_PASSWORD_CMD": "echo secret"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setServerEnv(t)
+			for _, name := range tt.unset {
+				os.Unsetenv(name)
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			_, smtp, err := loadConfigs(tt.flags)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if smtp.password != "secret" {
+					t.Errorf("SMTP password = %q", smtp.password)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want a mention of %s", err, tt.wantErr)
+			}
+		})
+	}
+}
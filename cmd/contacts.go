@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+var Contacts = &cli.Command{
+	Name:  "contacts",
+	Usage: "Manage the address book used to complete recipients",
+	Commands: []*cli.Command{
+		{
+			Name:      "add",
+			Usage:     "Add or rename a contact",
+			ArgsUsage: `"Name <address>" | address`,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "name",
+					Usage: "display name of the contact",
+				},
+			},
+			Action: func(ctx context.Context, c *cli.Command) error {
+				if c.Args().Len() != 1 {
+					return fmt.Errorf("expected one address, for example: cleu contacts add \"Alice <alice@example.com>\"")
+				}
+				address, err := mail.ParseAddress(c.Args().First())
+				if err != nil {
+					return fmt.Errorf("invalid address: %w", err)
+				}
+				if c.IsSet("name") {
+					address.Name = c.String("name")
+				}
+				contacts, err := loadContacts()
+				if err != nil {
+					return err
+				}
+				contacts = addContact(contacts, contact{Name: address.Name, Email: address.Address}, true)
+				if err := saveContacts(contacts); err != nil {
+					return err
+				}
+				fmt.Printf("Saved %s\n", contact{Name: address.Name, Email: address.Address})
+				return nil
+			},
+		},
+		{
+			Name:  "list",
+			Usage: "List saved contacts",
+			Action: func(ctx context.Context, c *cli.Command) error {
+				contacts, err := loadContacts()
+				if err != nil {
+					return err
+				}
+				if len(contacts) == 0 {
+					fmt.Println("No saved contacts.")
+					return nil
+				}
+				for _, contact := range contacts {
+					fmt.Println(contact)
+				}
+				return nil
+			},
+		},
+	},
+}
+
+// contact is an address book entry, saved in contactsPath.
+type contact struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email"`
+}
+
+// String formats the contact as it would be typed in a recipient field.
+// Names that would need quoting are left out.
+func (c contact) String() string {
+	if c.Name == "" || strings.ContainsAny(c.Name, `,;"<>@`) {
+		return c.Email
+	}
+	return c.Name + " <" + c.Email + ">"
+}
+
+// contactsPath is the JSON address book under cleu's config directory.
+func contactsPath() (string, error) {
+	return configSubdir("contacts.json")
+}
+
+// loadContacts returns the saved contacts, or none when there is no address
+// book yet.
+func loadContacts() ([]contact, error) {
+	path, err := contactsPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read contacts: %w", err)
+	}
+	var contacts []contact
+	if err := json.Unmarshal(data, &contacts); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	return contacts, nil
+}
+
+func saveContacts(contacts []contact) error {
+	path, err := contactsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("could not create config directory: %w", err)
+	}
+	sort.Slice(contacts, func(i, j int) bool {
+		return strings.ToLower(contacts[i].String()) < strings.ToLower(contacts[j].String())
+	})
+	data, err := json.MarshalIndent(contacts, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("could not save contacts: %w", err)
+	}
+	return nil
+}
+
+// addContact adds c to contacts, matching existing entries by address. The
+// name of an existing entry is only replaced when rename is set or it had
+// none.
+func addContact(contacts []contact, c contact, rename bool) []contact {
+	for i, existing := range contacts {
+		if strings.EqualFold(existing.Email, c.Email) {
+			if c.Name != "" && (rename || existing.Name == "") {
+				contacts[i].Name = c.Name
+			}
+			return contacts
+		}
+	}
+	return append(contacts, c)
+}
+
+// collectContacts adds the recipients of a sent email to the address book.
+func collectContacts(recipients ...[]*mail.Address) error {
+	contacts, err := loadContacts()
+	if err != nil {
+		return err
+	}
+	for _, list := range recipients {
+		for _, address := range list {
+			contacts = addContact(contacts, contact{Name: address.Name, Email: address.Address}, false)
+		}
+	}
+	return saveContacts(contacts)
+}
+
+// recipientSuggestions completes the last address being typed in value, a
+// comma separated recipient list, from the start of a contact's name or
+// address. Suggestions repeat what was typed before that address, since the
+// input matches them against its whole value.
+func recipientSuggestions(contacts []contact, value string) []string {
+	typed := value[strings.LastIndex(value, ",")+1:]
+	token := strings.ToLower(strings.TrimLeft(typed, " "))
+	if token == "" {
+		return nil
+	}
+	before := value[:len(value)-len(strings.TrimLeft(typed, " "))]
+
+	var suggestions []string
+	for _, c := range contacts {
+		formatted := c.String()
+		switch {
+		case strings.HasPrefix(strings.ToLower(formatted), token):
+			suggestions = append(suggestions, before+formatted)
+		case strings.HasPrefix(strings.ToLower(c.Email), token):
+			suggestions = append(suggestions, before+c.Email)
+		}
+	}
+	return suggestions
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/urfave/cli/v3"
+)
+
+var Doctor = &cli.Command{
+	Name:  "doctor",
+	Usage: "Check the IMAP and SMTP settings by connecting to both servers, without sending anything",
+	Flags: imapAndSMTPFlags(),
+	Action: func(ctx context.Context, c *cli.Command) error {
+		var checks checklist
+
+		fmt.Println("IMAP")
+		if config, err := loadIMAPConfig(c); checks.check("settings", err) {
+			fmt.Printf("     %s on %s:%s\n", config.username, config.host, config.port)
+			imapClient, err := connectToServer(config)
+			if checks.check("connect and log in", err) {
+				mailbox, err := imapClient.Select(config.inbox, true)
+				if checks.check("open "+config.inbox, err) {
+					// SELECT only reports the first unseen message, not how many
+					if unseen, err := searchUnseen(imapClient); err == nil {
+						fmt.Printf("     %d messages, %d unread\n", mailbox.Messages, len(unseen))
+					} else {
+						fmt.Printf("     %d messages\n", mailbox.Messages)
+					}
+				}
+				imapClient.Logout()
+			}
+		}
+
+		fmt.Println("SMTP")
+		if config, err := loadSMTPConfig(c); checks.check("settings", err) {
+			fmt.Printf("     %s on %s:%s (TLS: %s)\n", config.username, config.host, config.port, config.tlsMode)
+			// On failure, name the step that failed
+			name := "connect and authenticate"
+			smtpClient, err := connectSMTP(config, func(stage string) { name = stage })
+			if err == nil {
+				name = "connect and authenticate"
+			}
+			if checks.check(name, err) {
+				checks.check("NOOP", smtpClient.Noop())
+				checks.check("QUIT", smtpClient.Quit())
+			}
+		}
+
+		if checks.failed > 0 {
+			return fmt.Errorf("%d check(s) failed", checks.failed)
+		}
+		fmt.Println("\nEverything works.")
+		return nil
+	},
+}
+
+// checklist prints the result of each check and counts the failures.
+type checklist struct {
+	failed int
+}
+
+// check prints name with a pass or fail mark and the error, and reports
+// whether it passed.
+func (l *checklist) check(name string, err error) bool {
+	if err != nil {
+		l.failed++
+		fmt.Printf("  ❌ %s: %v\n", name, err)
+		return false
+	}
+	fmt.Printf("  ✅ %s\n", name)
+	return true
+}
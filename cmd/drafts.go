@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/huh"
+	"github.com/urfave/cli/v3"
+)
+
+var Drafts = &cli.Command{
+	Name:  "drafts",
+	Usage: "List saved drafts and resume one",
+	Flags: smtpFlags(),
+	Action: func(ctx context.Context, c *cli.Command) error {
+		drafts, err := loadDrafts()
+		if err != nil {
+			return err
+		}
+		if len(drafts) == 0 {
+			fmt.Println("No saved drafts.")
+			return nil
+		}
+
+		options := make([]huh.Option[int], len(drafts))
+		for i, d := range drafts {
+			options[i] = huh.NewOption(d.label(), i)
+		}
+		var choice int
+		err = huh.NewForm(
+			huh.NewGroup(
+				huh.NewSelect[int]().
+					Title("Drafts").
+					Description("Pick a draft to resume").
+					Options(options...).
+					Value(&choice),
+			),
+		).WithTheme(huh.ThemeCharm()).Run()
+		if errors.Is(err, huh.ErrUserAborted) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("form error: %w", err)
+		}
+
+		config, err := loadSMTPConfig(c)
+		if err != nil {
+			return err
+		}
+		d := drafts[choice]
+		d.Email.Confirm = false
+		return composeAndSend(&d.Email, config, d.ID)
+	},
+}
+
+// draft is an unsent email saved as JSON in draftsDir.
+type draft struct {
+	ID    string    `json:"id"`
+	Saved time.Time `json:"saved"`
+	Email EmailForm `json:"email"`
+}
+
+func (d draft) label() string {
+	subject := d.Email.Subject
+	if strings.TrimSpace(subject) == "" {
+		subject = "(No Subject)"
+	}
+	to := d.Email.To
+	if strings.TrimSpace(to) == "" {
+		to = "no recipients"
+	}
+	return fmt.Sprintf("%s → %s (saved %s)", subject, to, d.Saved.Format("Jan 2, 15:04"))
+}
+
+// configSubdir returns the path of name under cleu's config directory.
+func configSubdir(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("could not find the config directory: %w", err)
+	}
+	return filepath.Join(dir, "cleu", name), nil
+}
+
+// draftsDir is where drafts are kept, under the user's config directory.
+func draftsDir() (string, error) {
+	return configSubdir("drafts")
+}
+
+// saveDraft writes email to the draft with the given ID, creating a new
+// draft when id is empty, and returns the path it was saved to.
+func saveDraft(email *EmailForm, id string) (string, error) {
+	dir, err := draftsDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("could not create drafts directory: %w", err)
+	}
+	if id == "" {
+		id = time.Now().Format("20060102-150405.000")
+	}
+
+	d := draft{ID: id, Saved: time.Now(), Email: *email}
+	d.Email.Confirm = false
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, id+".json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", fmt.Errorf("could not save draft: %w", err)
+	}
+	return path, nil
+}
+
+// loadDrafts returns every saved draft, most recently saved first.
+func loadDrafts() ([]draft, error) {
+	dir, err := draftsDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var drafts []draft
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read draft: %w", err)
+		}
+		var d draft
+		if err := json.Unmarshal(data, &d); err != nil {
+			return nil, fmt.Errorf("could not parse draft %s: %w", path, err)
+		}
+		d.ID = strings.TrimSuffix(filepath.Base(path), ".json")
+		drafts = append(drafts, d)
+	}
+	sort.Slice(drafts, func(i, j int) bool {
+		return drafts[i].Saved.After(drafts[j].Saved)
+	})
+	return drafts, nil
+}
+
+func deleteDraft(id string) error {
+	dir, err := draftsDir()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dir, id+".json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not delete draft: %w", err)
+	}
+	return nil
+}
+
+// offerDraft asks whether to keep an unsent email as a draft. Nothing is
+// asked when the form was left empty.
+func offerDraft(email *EmailForm, id string) error {
+	if strings.TrimSpace(email.To+email.Cc+email.Bcc+email.Subject+email.Body) == "" {
+		return nil
+	}
+
+	save := true
+	err := huh.NewForm(
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title("Save as draft?").
+				Description("Resume it later with cleu drafts").
+				Value(&save),
+		),
+	).WithTheme(huh.ThemeCharm()).Run()
+	if errors.Is(err, huh.ErrUserAborted) || (err == nil && !save) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("form error: %w", err)
+	}
+
+	path, err := saveDraft(email, id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("📝 Draft saved to %s\n", path)
+	return nil
+}
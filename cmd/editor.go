@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// editInEditor opens $EDITOR on a temporary file seeded with text and returns
+// the contents saved by the user.
+func editInEditor(text string) (string, error) {
+	cmd, path, err := editorCommand(text)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(path)
+
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return readEdited(cmd, path, cmd.Run())
+}
+
+type editorClosedMsg struct {
+	body string
+	err  error
+}
+
+// editInEditorCmd is editInEditor for the TUI: it suspends the interface
+// while $EDITOR runs and reports the result as an editorClosedMsg.
+func editInEditorCmd(text string) tea.Cmd {
+	cmd, path, err := editorCommand(text)
+	if err != nil {
+		return func() tea.Msg { return editorClosedMsg{err: err} }
+	}
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		defer os.Remove(path)
+		body, err := readEdited(cmd, path, err)
+		return editorClosedMsg{body: body, err: err}
+	})
+}
+
+// editorCommand writes text to a temporary file and returns the $EDITOR
+// command that opens it, along with the file to remove once it exits.
+func editorCommand(text string) (*exec.Cmd, string, error) {
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		return nil, "", fmt.Errorf("$EDITOR is not set")
+	}
+
+	tmpFile, err := os.CreateTemp("", "cleu-*.md")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if _, err := tmpFile.WriteString(text); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return nil, "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpFile.Name())
+		return nil, "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+	return exec.Command(editor[0], append(editor[1:], tmpFile.Name())...), tmpFile.Name(), nil
+}
+
+// readEdited returns what the editor run by cmd saved to path, or runErr
+// when it failed.
+func readEdited(cmd *exec.Cmd, path string, runErr error) (string, error) {
+	if runErr != nil {
+		return "", fmt.Errorf("editor %s failed: %w", cmd.Args[0], runErr)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read edited file: %w", err)
+	}
+	return string(content), nil
+}
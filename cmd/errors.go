@@ -0,0 +1,20 @@
+package cmd
+
+import "errors"
+
+// configError marks errors caused by missing or invalid settings or flags,
+// as opposed to failures while talking to the servers.
+type configError struct {
+	err error
+}
+
+func (e configError) Error() string { return e.err.Error() }
+
+func (e configError) Unwrap() error { return e.err }
+
+// IsConfigError reports whether err comes from the user's settings, so main
+// can exit with a distinct code.
+func IsConfigError(err error) bool {
+	var c configError
+	return errors.As(err, &c)
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/urfave/cli/v3"
+)
+
+var Export = &cli.Command{
+	Name:  "export",
+	Usage: "Export a mailbox to an mbox file",
+	Flags: append(imapFlags(),
+		&cli.StringFlag{
+			Name:     "mbox",
+			Usage:    "path of the mbox file to write",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "mailbox",
+			Usage: "mailbox to export (default: the --inbox mailbox)",
+		},
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "only export the N most recent messages (0 exports everything)",
+		},
+		&cli.StringFlag{
+			Name:  "range",
+			Usage: `sequence range to export, e.g. "1:500" (overrides --limit)`,
+		},
+	),
+	Action: func(ctx context.Context, c *cli.Command) error {
+		config, err := loadIMAPConfig(c)
+		if err != nil {
+			return err
+		}
+
+		imapClient, err := connectToServer(config)
+		if err != nil {
+			return err
+		}
+		defer imapClient.Logout()
+
+		file, err := os.Create(c.String("mbox"))
+		if err != nil {
+			return fmt.Errorf("failed to create mbox file: %w", err)
+		}
+		defer file.Close()
+
+		count, err := exportMbox(imapClient, file, mailboxOrInbox(c, config), c.String("range"), c.Int("limit"))
+		if err != nil {
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("failed to write mbox file: %w", err)
+		}
+
+		fmt.Printf("Exported %d message(s) from %s to %s\n", count, mailboxOrInbox(c, config), c.String("mbox"))
+		return nil
+	},
+}
+
+// exportMbox streams the messages of mailbox to w in mbox format, one message
+// at a time, and returns how many were written.
+func exportMbox(imapClient *client.Client, w io.Writer, mailbox, seqRange string, limit int) (int, error) {
+	status, err := imapClient.Select(mailbox, true)
+	if err != nil {
+		return 0, fmt.Errorf("failed to select %s: %w", mailbox, err)
+	}
+	if status.Messages == 0 {
+		return 0, nil
+	}
+
+	var seqSet *imap.SeqSet
+	switch {
+	case seqRange != "":
+		seqSet, err = imap.ParseSeqSet(seqRange)
+		if err != nil {
+			return 0, fmt.Errorf("invalid range %q: %w", seqRange, err)
+		}
+	case limit > 0 && uint32(limit) < status.Messages:
+		seqSet = new(imap.SeqSet)
+		seqSet.AddRange(status.Messages-uint32(limit)+1, status.Messages)
+	default:
+		seqSet = new(imap.SeqSet)
+		seqSet.AddRange(1, status.Messages)
+	}
+
+	section := &imap.BodySectionName{Peek: true}
+	items := []imap.FetchItem{imap.FetchEnvelope, imap.FetchInternalDate, section.FetchItem()}
+
+	messages := make(chan *imap.Message, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- imapClient.Fetch(seqSet, items, messages)
+	}()
+
+	writer := bufio.NewWriter(w)
+	count := 0
+	var writeErr error
+	for msg := range messages {
+		if writeErr != nil {
+			continue
+		}
+		body := msg.GetBody(section)
+		if body == nil {
+			continue
+		}
+		raw, err := io.ReadAll(body)
+		if err != nil {
+			writeErr = err
+			continue
+		}
+		sender := "MAILER-DAEMON"
+		if msg.Envelope != nil && len(msg.Envelope.From) > 0 && msg.Envelope.From[0] != nil {
+			if address := msg.Envelope.From[0].Address(); address != "" {
+				sender = address
+			}
+		}
+		if writeErr = writeMboxMessage(writer, sender, msg.InternalDate, raw); writeErr == nil {
+			count++
+		}
+	}
+	if err := <-done; err != nil {
+		return count, fmt.Errorf("failed to fetch messages: %w", err)
+	}
+	if writeErr != nil {
+		return count, fmt.Errorf("failed to write mbox: %w", writeErr)
+	}
+	return count, writer.Flush()
+}
+
+// writeMboxMessage writes one message in mboxrd format: a "From " separator
+// line, the message with ">" added to any line that looks like a separator, and
+// a trailing blank line.
+func writeMboxMessage(w io.Writer, sender string, date time.Time, raw []byte) error {
+	if date.IsZero() {
+		date = time.Now()
+	}
+	if _, err := fmt.Fprintf(w, "From %s %s\n", sender, date.UTC().Format(time.ANSIC)); err != nil {
+		return err
+	}
+
+	raw = bytes.ReplaceAll(raw, []byte("\r\n"), []byte("\n"))
+	raw = bytes.TrimSuffix(raw, []byte("\n"))
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		if bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) {
+			if _, err := w.Write([]byte(">")); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte("\n")); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write([]byte("\n"))
+	return err
+}
+
+// saveEML writes the raw RFC822 source of email to dir and returns the path
+// of the new file.
+func saveEML(dir string, email Email) (string, error) {
+	if email.Raw == "" {
+		return "", fmt.Errorf("email source is not loaded yet")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create export directory: %w", err)
+	}
+	path := filepath.Join(dir, emlFileName(email))
+	if err := os.WriteFile(path, []byte(email.Raw), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// emlFileName builds a file name like "2006-01-02_subject-words_1234.eml".
+func emlFileName(email Email) string {
+	name := sanitizeFileName(email.Subject)
+	if name == "" {
+		name = "email"
+	}
+	return fmt.Sprintf("%s_%s_%d.eml", email.Date.Format("2006-01-02"), name, email.UID)
+}
+
+// sanitizeFileName keeps letters and digits, collapses everything else into
+// single dashes and caps the length.
+func sanitizeFileName(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	name := []rune(strings.Trim(b.String(), "-"))
+	if len(name) > 50 {
+		name = name[:50]
+	}
+	return strings.Trim(string(name), "-")
+}
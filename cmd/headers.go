@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// customHeader is an extra header added with --header or the headers file.
+type customHeader struct {
+	Name  string
+	Value string
+}
+
+// protectedHeaders describe the MIME structure cleu builds and can never be
+// set by hand.
+var protectedHeaders = []string{"MIME-Version", "Content-Type", "Content-Transfer-Encoding"}
+
+// addressingHeaders decide who gets the email and how it is identified, so
+// replacing them needs --allow-header-override.
+var addressingHeaders = []string{"From", "Sender", "To", "Cc", "Bcc", "Reply-To", "Subject", "Date", "Message-ID"}
+
+func containsHeader(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCustomHeaders parses "Name: Value" lines. Header names must be
+// printable ASCII without colons and values cannot contain line breaks, so
+// nothing can be smuggled into the message. Headers cleu sets itself, other
+// than the addressing ones, are simply replaced.
+func parseCustomHeaders(lines []string, allowOverride bool) ([]customHeader, error) {
+	headers := make([]customHeader, 0, len(lines))
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header %q, expected \"Name: Value\"", line)
+		}
+		for _, r := range name {
+			if r < '!' || r > '~' {
+				return nil, fmt.Errorf("invalid header name %q", name)
+			}
+		}
+		if err := validateHeaderValue(name, value); err != nil {
+			return nil, err
+		}
+		if containsHeader(protectedHeaders, name) {
+			return nil, fmt.Errorf("the %s header cannot be set by hand", name)
+		}
+		if containsHeader(addressingHeaders, name) && !allowOverride {
+			return nil, fmt.Errorf("refusing to replace the %s header, pass --allow-header-override to do it anyway", name)
+		}
+		headers = append(headers, customHeader{Name: name, Value: value})
+	}
+	return headers, nil
+}
+
+// loadDefaultHeaders reads the headers file in cleu's config directory
+// (for example ~/.config/cleu/headers), one "Name: Value" per line, added to
+// every email. Blank lines and lines starting with # are skipped.
+func loadDefaultHeaders() ([]string, error) {
+	path, err := configSubdir("headers")
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read default headers: %w", err)
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read default headers: %w", err)
+	}
+	return lines, nil
+}
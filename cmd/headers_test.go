@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseCustomHeaders(t *testing.T) {
+	tests := []struct {
+		name          string
+		lines         []string
+		allowOverride bool
+		want          []customHeader
+		wantErr       string
+	}{
+		{name: "none", want: []customHeader{}},
+		{
+			name:  "valid",
+			lines: []string{"List-Unsubscribe: <mailto:leave@example.com>", "  X-Mailer :  cleu  "},
+			want:  []customHeader{{Name: "List-Unsubscribe", Value: "<mailto:leave@example.com>"}, {Name: "X-Mailer", Value: "cleu"}},
+		},
+		{name: "value with a colon", lines: []string{"X-Link: https://example.com"}, want: []customHeader{{Name: "X-Link", Value: "https://example.com"}}},
+		{name: "built-in replaced", lines: []string{"User-Agent: mine"}, want: []customHeader{{Name: "User-Agent", Value: "mine"}}},
+		{name: "no colon", lines: []string{"X-Broken"}, wantErr: "expected"},
+		{name: "no name", lines: []string{": value"}, wantErr: "expected"},
+		{name: "space in the name", lines: []string{"X Bad: value"}, wantErr: "invalid header name"},
+		{name: "non-ASCII name", lines: []string{"X-Pé: value"}, wantErr: "invalid header name"},
+		{name: "CRLF injection", lines: []string{"X-Note: hi\r\nBcc: spy@example.com"}, wantErr: "line break"},
+		{name: "LF injection", lines: []string{"X-Note: hi\nBcc: spy@example.com"}, wantErr: "line break"},
+		{name: "protected", lines: []string{"Content-Type: text/html"}, wantErr: "cannot be set"},
+		{name: "protected even with override", lines: []string{"mime-version: 2.0"}, allowOverride: true, wantErr: "cannot be set"},
+		{name: "addressing", lines: []string{"From: boss@example.com"}, wantErr: "--allow-header-override"},
+		{name: "addressing in another case", lines: []string{"bcc: spy@example.com"}, wantErr: "--allow-header-override"},
+		{name: "addressing allowed", lines: []string{"Subject: Replaced"}, allowOverride: true, want: []customHeader{{Name: "Subject", Value: "Replaced"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCustomHeaders(tt.lines, tt.allowOverride)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("headers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadDefaultHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string // written to the headers file unless empty
+		want    []string
+	}{
+		{name: "no file"},
+		{name: "lines", content: "# defaults\n\nX-Mailer: cleu\n  Organization: Example  \n", want: []string{"X-Mailer: cleu", "Organization: Example"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			if tt.content != "" {
+				path, err := configSubdir("headers")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := loadDefaultHeaders()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("headers = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomHeadersInMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults []string
+		headers  []string
+		override bool
+		want     map[string][]string
+		wantErr  bool
+	}{
+		{
+			name:    "appended",
+			headers: []string{"List-Unsubscribe: <mailto:leave@example.com>"},
+			want:    map[string][]string{"List-Unsubscribe": {"<mailto:leave@example.com>"}, "User-Agent": {"CLI-Email-Client"}},
+		},
+		{
+			name:     "defaults and flags",
+			defaults: []string{"Organization: Example"},
+			headers:  []string{"X-Mailer: mine"},
+			want:     map[string][]string{"Organization": {"Example"}, "X-Mailer": {"mine"}},
+		},
+		{
+			name:    "replaces a built-in header once",
+			headers: []string{"User-Agent: mine"},
+			want:    map[string][]string{"User-Agent": {"mine"}},
+		},
+		{
+			name:     "addressing override",
+			headers:  []string{"Subject: Replaced"},
+			override: true,
+			want:     map[string][]string{"Subject": {"Replaced"}},
+		},
+		{name: "addressing refused", headers: []string{"To: spy@example.com"}, wantErr: true},
+		{name: "injection refused", defaults: []string{"X-Note: a\r\nBcc: spy@example.com"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := &EmailForm{To: "bob@example.com", Subject: "Hi", Body: "Hello", Headers: tt.headers, AllowHeaderOverride: tt.override}
+			server, _, err := sendThroughMock(t, email, func(config *smtpConfig) { config.headers = tt.defaults })
+			if tt.wantErr {
+				if err == nil || len(server.received()) != 0 {
+					t.Errorf("sent anyway (error %v)", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			header := server.received()[0].parsed(t).Header
+			for name, want := range tt.want {
+				if got := header[name]; !slices.Equal(got, want) {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// shortcut documents one key binding. The inline help line and the "?"
+// overlay are both built from these tables, so keep them next to the
+// handlers in App.Update when adding keys.
+type shortcut struct {
+	keys        string
+	description string
+}
+
+var listShortcuts = []shortcut{
+	{"↑/↓", "navigate"},
+	{"home/end", "top/bottom"},
+	{":", "jump"},
+	{"enter", "read"},
+	{"d", "delete"},
+	{"M", "move to folder"},
+	{"space", "select"},
+	{"*/i", "select all/invert"},
+	{"y", "copy sender"},
+	{"c", "compose"},
+	{"s", "sort"},
+	{"o", "newest/oldest first"},
+	{"U", "unread only"},
+	{"t", "threads"},
+	{"tab", "expand thread"},
+	{"A", "mark all read"},
+	{"E", "empty trash"},
+	{"/", "search"},
+	{"r", "refresh"},
+	{"?", "help"},
+	{"q", "quit"},
+}
+
+var emailShortcuts = []shortcut{
+	{"↑/↓", "scroll"},
+	{"home/end", "top/bottom"},
+	{"ctrl+d/ctrl+u", "half page"},
+	{"d", "delete"},
+	{"M", "move to folder"},
+	{"a/f", "reply/forward"},
+	{"c", "compose"},
+	{"e", "export .eml"},
+	{"y/Y", "copy sender/body"},
+	{"z", "show/hide quoted text"},
+	{"m", "markdown/plain text"},
+	{"H", "raw headers"},
+	{"R", "raw source in $PAGER"},
+	{"I", "inline images"},
+	{"esc", "back"},
+	{"?", "help"},
+	{"q", "quit"},
+}
+
+// vimShortcuts are the extra motions enabled with --vim-keys, in both the
+// inbox and the email view.
+var vimShortcuts = []shortcut{
+	{"j/k", "down/up"},
+	{"gg/G", "top/bottom"},
+	{"ctrl+d/ctrl+u", "half page"},
+}
+
+var composeShortcuts = []shortcut{
+	{"tab/shift+tab", "next/previous field"},
+	{"ctrl+e", "edit the body in $EDITOR"},
+	{"ctrl+s", "send"},
+	{"esc", "close, keeping a draft"},
+}
+
+var folderShortcuts = []shortcut{
+	{"↑/↓", "choose"},
+	{"/", "filter"},
+	{"enter", "move here"},
+	{"esc", "cancel"},
+}
+
+var confirmShortcuts = []shortcut{
+	{"←/→", "select"},
+	{"enter", "confirm"},
+	{"esc", "cancel"},
+}
+
+var jumpShortcuts = []shortcut{
+	{"<n>", "go to email n"},
+	{"p<n>", "go to page n"},
+	{"enter", "jump"},
+	{"esc", "cancel"},
+}
+
+// readOnlyActions are the keys that change the mailbox, with what they do,
+// refused with --read-only.
+var readOnlyActions = map[string]string{
+	"d": "deleting",
+	"A": "marking all as read",
+	"E": "emptying the trash",
+	"M": "moving",
+}
+
+// available drops the shortcuts that are disabled in read-only mode.
+func (a *App) available(shortcuts []shortcut) []shortcut {
+	if !a.options.readOnly {
+		return shortcuts
+	}
+	var kept []shortcut
+	for _, s := range shortcuts {
+		if _, disabled := readOnlyActions[s.keys]; !disabled {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// helpLine renders shortcuts as a one-line "key: description" list.
+func helpLine(shortcuts []shortcut) string {
+	parts := make([]string, len(shortcuts))
+	for i, s := range shortcuts {
+		parts[i] = s.keys + ": " + s.description
+	}
+	return strings.Join(parts, " • ")
+}
+
+func (a *App) renderHelpOverlay() string {
+	groups := []struct {
+		title     string
+		shortcuts []shortcut
+	}{
+		{"Inbox", a.available(listShortcuts)},
+		{"Reading an email", a.available(emailShortcuts)},
+		{"Vim motions", vimShortcuts},
+		{"Jump prompt", jumpShortcuts},
+		{"Confirmation dialogs", confirmShortcuts},
+	}
+
+	var content strings.Builder
+	content.WriteString(subjectStyle.Render("⌨️  Keyboard Shortcuts") + "\n")
+	for _, group := range groups {
+		if group.title == "Vim motions" && !a.options.vimKeys {
+			continue
+		}
+		content.WriteString("\n" + fromStyle.Render(group.title) + "\n")
+		width := 0
+		for _, s := range group.shortcuts {
+			width = max(width, lipgloss.Width(s.keys))
+		}
+		for _, s := range group.shortcuts {
+			keys := lipgloss.NewStyle().Width(width + 2).Render(s.keys)
+			content.WriteString("  " + keys + emailInfoStyle.Render(s.description) + "\n")
+		}
+	}
+	content.WriteString("\n" + helpStyle.Render("Press any key to close"))
+
+	overlay := helpOverlayStyle.Render(content.String())
+	return lipgloss.Place(a.width, a.height, lipgloss.Center, lipgloss.Center, overlay)
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Default ports used when IMAP_PORT or SMTP_PORT is left empty.
+const (
+	defaultIMAPPort     = "993"
+	defaultSMTPTLSPort  = "465"
+	defaultSMTPSTLSPort = "587"
+)
+
+// normalizeHost trims the host read from the environment variable name and
+// rejects values that cannot be dialed, such as URLs.
+func normalizeHost(name, host string) (string, error) {
+	host = strings.TrimSpace(host)
+	if strings.Contains(host, "://") || strings.ContainsAny(host, " \t/") {
+		return "", fmt.Errorf("%s must be a host name such as mail.example.com, got %q", name, host)
+	}
+	return host, nil
+}
+
+// normalizePort trims the port read from the environment variable name,
+// using defaultPort when it is empty, and checks that it is a valid port.
+func normalizePort(name, port, defaultPort string) (string, error) {
+	port = strings.TrimSpace(port)
+	if port == "" {
+		port = defaultPort
+	}
+	if port == "" {
+		return "", nil
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("invalid port: %s must be a number between 1 and 65535, got %q", name, port)
+	}
+	return port, nil
+}
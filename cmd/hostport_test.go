@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeHost(t *testing.T) {
+	tests := []struct {
+		host    string
+		want    string
+		wantErr bool
+	}{
+		{host: "mail.example.com", want: "mail.example.com"},
+		{host: "  mail.example.com\n", want: "mail.example.com"},
+		{host: "127.0.0.1", want: "127.0.0.1"},
+		{host: "imaps://mail.example.com", wantErr: true},
+		{host: "mail.example.com/inbox", wantErr: true},
+		{host: "mail example.com", wantErr: true},
+		{host: "mail.example.com\t2", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := normalizeHost("IMAP_HOST", tt.host)
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "IMAP_HOST must be a host name") {
+				t.Errorf("normalizeHost(%q) error = %v", tt.host, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("normalizeHost(%q) = %q, %v; want %q", tt.host, got, err, tt.want)
+		}
+	}
+}
+
+func TestNormalizePort(t *testing.T) {
+	tests := []struct {
+		port        string
+		defaultPort string
+		want        string
+		wantErr     bool
+	}{
+		{port: "993", want: "993"},
+		{port: "993 ", want: "993"},
+		{port: " 587\n", defaultPort: "465", want: "587"},
+		{port: "", defaultPort: "993", want: "993"},
+		{port: "   ", defaultPort: "465", want: "465"},
+		{port: "", want: ""},
+		{port: "imaps", wantErr: true},
+		{port: "0", wantErr: true},
+		{port: "65536", wantErr: true},
+		{port: "-1", wantErr: true},
+		{port: "99 3", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := normalizePort("SMTP_PORT", tt.port, tt.defaultPort)
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "invalid port: SMTP_PORT") {
+				t.Errorf("normalizePort(%q) error = %v", tt.port, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("normalizePort(%q, %q) = %q, %v; want %q", tt.port, tt.defaultPort, got, err, tt.want)
+		}
+	}
+}
+
+func TestHostAndPortSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		wantIMAP string // host:port
+		wantSMTP string
+		wantErr  string
+	}{
+		{name: "set", wantIMAP: "imap.example.com:993", wantSMTP: "smtp.example.com:465"},
+		{name: "whitespace", env: map[string]string{"IMAP_HOST": " imap.example.com ", "IMAP_PORT": "993 ", "SMTP_PORT": " 2525"}, wantIMAP: "imap.example.com:993", wantSMTP: "smtp.example.com:2525"},
+		{name: "IMAP default", env: map[string]string{"IMAP_PORT": ""}, wantIMAP: "imap.example.com:993", wantSMTP: "smtp.example.com:465"},
+		{name: "SMTP implicit default", env: map[string]string{"SMTP_PORT": "", "CLEU_SMTP_TLS": "implicit"}, wantIMAP: "imap.example.com:993", wantSMTP: "smtp.example.com:465"},
+		{name: "SMTP STARTTLS default", env: map[string]string{"SMTP_PORT": "", "CLEU_SMTP_TLS": "starttls"}, wantIMAP: "imap.example.com:993", wantSMTP: "smtp.example.com:587"},
+		{name: "SMTP auto needs a port", env: map[string]string{"SMTP_PORT": ""}, wantErr: "please set SMTP_PORT"},
+		{name: "IMAP port not a number", env: map[string]string{"IMAP_PORT": "imaps"}, wantErr: "invalid port: IMAP_PORT"},
+		{name: "SMTP port out of range", env: map[string]string{"SMTP_PORT": "70000"}, wantErr: "invalid port: SMTP_PORT"},
+		{name: "IMAP URL", env: map[string]string{"IMAP_HOST": "imaps://imap.example.com"}, wantErr: "IMAP_HOST must be a host name"},
+		{name: "SMTP URL", env: map[string]string{"SMTP_HOST": "smtp://smtp.example.com"}, wantErr: "SMTP_HOST must be a host name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setServerEnv(t)
+			t.Setenv("CLEU_SMTP_TLS", "")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			imap, smtp, err := loadConfigs(imapAndSMTPFlags())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := imap.host + ":" + imap.port; got != tt.wantIMAP {
+				t.Errorf("IMAP = %s, want %s", got, tt.wantIMAP)
+			}
+			if got := smtp.host + ":" + smtp.port; got != tt.wantSMTP {
+				t.Errorf("SMTP = %s, want %s", got, tt.wantSMTP)
+			}
+		})
+	}
+}
+
+func TestConnectToServerIPv6(t *testing.T) {
+	ln, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("no IPv6 loopback: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	// The silent server never finishes the handshake, but it was reached
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	config := imapConfig{host: "::1", port: port, insecure: true, dialTimeout: 100 * time.Millisecond}
+	_, err = connectToServer(config)
+	if want := "[::1]:" + port; err == nil || !strings.Contains(err.Error(), want) || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("error = %v, want a timeout connecting to %s", err, want)
+	}
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// htmlToText renders an HTML body as plain text, for quoting it in a reply:
+// tags are dropped, block elements and <br> break lines, runs of whitespace
+// collapse and the content of head, script and style is left out.
+func htmlToText(body string) string {
+	var lines []string
+	var line strings.Builder
+	space := false
+	breakLine := func(force bool) {
+		if line.Len() > 0 || force {
+			lines = append(lines, line.String())
+		}
+		line.Reset()
+		space = false
+	}
+
+	tokenizer := html.NewTokenizer(strings.NewReader(body))
+	skip := 0
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			breakLine(false)
+			return strings.TrimSpace(strings.Join(lines, "\n"))
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			token := tokenizer.Token()
+			switch token.DataAtom {
+			case atom.Head, atom.Script, atom.Style, atom.Title:
+				if token.Type == html.StartTagToken {
+					skip++
+				} else if token.Type == html.EndTagToken && skip > 0 {
+					skip--
+				}
+			case atom.Br:
+				breakLine(true)
+			case atom.P, atom.Div, atom.Tr, atom.Li, atom.Blockquote, atom.Table, atom.Pre, atom.Hr,
+				atom.Ul, atom.Ol, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+				breakLine(false)
+			}
+		case html.TextToken:
+			if skip > 0 {
+				continue
+			}
+			for _, r := range string(tokenizer.Text()) {
+				if unicode.IsSpace(r) {
+					space = line.Len() > 0
+					continue
+				}
+				if space {
+					line.WriteByte(' ')
+					space = false
+				}
+				line.WriteRune(r)
+			}
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import "testing"
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{name: "inline tags", html: "Hello <b>big</b> <i>world</i>", want: "Hello big world"},
+		{name: "paragraphs", html: "<p>One</p><p>Two</p>", want: "One\nTwo"},
+		{name: "line breaks", html: "One<br>Two<br/>Three", want: "One\nTwo\nThree"},
+		{name: "whitespace collapses", html: "<p>  lots \n of\t space </p>", want: "lots of space"},
+		{name: "entities", html: "Fish &amp; chips &lt;3", want: "Fish & chips <3"},
+		{
+			name: "head, style and script are left out",
+			html: "<html><head><title>T</title><style>p{}</style></head><body><script>x()</script><p>Body</p></body></html>",
+			want: "Body",
+		},
+		{name: "list items", html: "<ul><li>a</li><li>b</li></ul>", want: "a\nb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := htmlToText(tt.html); got != tt.want {
+				t.Errorf("htmlToText(%q) = %q, want %q", tt.html, got, tt.want)
+			}
+		})
+	}
+}
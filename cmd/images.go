@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"strings"
+
+	// Formats kitty is sent as PNG
+	_ "image/gif"
+	_ "image/jpeg"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/urfave/cli/v3"
+)
+
+// Values of --inline-images. Auto draws the images only in a terminal
+// detectImageProtocol recognises; kitty and iterm force that protocol.
+const (
+	inlineImagesAuto  = "auto"
+	inlineImagesKitty = "kitty"
+	inlineImagesITerm = "iterm"
+	inlineImagesOff   = "off"
+)
+
+// inlineImagesFlag is the --inline-images flag of read.
+func inlineImagesFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "inline-images",
+		Usage:   `how I draws the inline images of an email: "auto", "kitty", "iterm" or "off"`,
+		Value:   inlineImagesAuto,
+		Sources: cli.EnvVars("CLEU_INLINE_IMAGES"),
+		Validator: func(v string) error {
+			switch v {
+			case inlineImagesAuto, inlineImagesKitty, inlineImagesITerm, inlineImagesOff:
+				return nil
+			}
+			return fmt.Errorf("inline-images must be %q, %q, %q or %q, got %q", inlineImagesAuto, inlineImagesKitty, inlineImagesITerm, inlineImagesOff, v)
+		},
+	}
+}
+
+// imageProtocol resolves an --inline-images value to the protocol to draw
+// images with, "" when they are not drawn.
+func imageProtocol(mode string) string {
+	switch mode {
+	case inlineImagesKitty, inlineImagesITerm:
+		return mode
+	case inlineImagesAuto:
+		return detectImageProtocol()
+	}
+	return ""
+}
+
+// detectImageProtocol guesses from the environment whether the terminal
+// draws images. Only variables are checked, the terminal is never queried:
+// TERM naming kitty or KITTY_WINDOW_ID for the kitty graphics protocol, and
+// TERM_PROGRAM or LC_TERMINAL naming iTerm2 or WezTerm for the iTerm2 one.
+// Multiplexers such as tmux hide these, hence the flag to force a protocol.
+func detectImageProtocol() string {
+	if strings.Contains(os.Getenv("TERM"), "kitty") || os.Getenv("KITTY_WINDOW_ID") != "" {
+		return inlineImagesKitty
+	}
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm":
+		return inlineImagesITerm
+	}
+	if os.Getenv("LC_TERMINAL") == "iTerm2" {
+		return inlineImagesITerm
+	}
+	return ""
+}
+
+// inlineImage is an image part of an email, decoded.
+type inlineImage struct {
+	attachment
+	data []byte
+}
+
+// inlineImages returns the images with a Content-ID in the raw source of an
+// email, the ones HTML bodies show with cid: and replaceInlineImages names.
+func inlineImages(raw string) []inlineImage {
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		return nil
+	}
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return nil
+	}
+	var images []inlineImage
+	var walk func(body io.Reader, boundary string)
+	walk = func(body io.Reader, boundary string) {
+		reader := multipart.NewReader(body, boundary)
+		for {
+			part, err := reader.NextRawPart()
+			if err != nil {
+				return
+			}
+			partMediaType, partParams, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			if strings.HasPrefix(partMediaType, "multipart/") {
+				walk(part, partParams["boundary"])
+				continue
+			}
+			contentID := strings.Trim(part.Header.Get("Content-ID"), "<> ")
+			if contentID == "" || !strings.HasPrefix(partMediaType, "image/") {
+				continue
+			}
+			data, err := io.ReadAll(part)
+			if err != nil {
+				continue
+			}
+			images = append(images, inlineImage{
+				attachment: attachment{
+					Name:        cmp.Or(attachmentFileName(part.Header), "image"),
+					ContentType: partMediaType,
+					ContentID:   contentID,
+				},
+				data: decodeTransferEncoding(data, part.Header.Get("Content-Transfer-Encoding")),
+			})
+		}
+	}
+	walk(msg.Body, params["boundary"])
+	return images
+}
+
+// writeImage draws img at the cursor with protocol. Kitty is sent PNG, which
+// is the one format it is guaranteed to read, so other formats are converted.
+func writeImage(w io.Writer, protocol string, img inlineImage) error {
+	switch protocol {
+	case inlineImagesKitty:
+		data := img.data
+		if img.ContentType != "image/png" {
+			decoded, _, err := image.Decode(bytes.NewReader(img.data))
+			if err != nil {
+				return fmt.Errorf("cannot decode %s: %w", img.Name, err)
+			}
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, decoded); err != nil {
+				return err
+			}
+			data = buf.Bytes()
+		}
+		// The payload goes in chunks of at most 4096 bytes
+		encoded := base64.StdEncoding.EncodeToString(data)
+		for first := true; first || encoded != ""; first = false {
+			chunk := encoded[:min(4096, len(encoded))]
+			encoded = encoded[len(chunk):]
+			more := 0
+			if encoded != "" {
+				more = 1
+			}
+			control := fmt.Sprintf("m=%d", more)
+			if first {
+				control = "a=T,f=100," + control
+			}
+			if _, err := fmt.Fprintf(w, "\x1b_G%s;%s\x1b\\", control, chunk); err != nil {
+				return err
+			}
+		}
+	case inlineImagesITerm:
+		_, err := fmt.Fprintf(w, "\x1b]1337;File=name=%s;size=%d;inline=1:%s\a",
+			base64.StdEncoding.EncodeToString([]byte(img.Name)), len(img.data), base64.StdEncoding.EncodeToString(img.data))
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown image protocol %q", protocol)
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// imagesCommand draws the inline images of an email while the TUI is
+// suspended, then waits for enter. It is run with tea.Exec.
+type imagesCommand struct {
+	protocol string
+	images   []inlineImage
+	stdin    io.Reader
+	stdout   io.Writer
+}
+
+func (c *imagesCommand) SetStdin(r io.Reader)  { c.stdin = r }
+func (c *imagesCommand) SetStdout(w io.Writer) { c.stdout = w }
+func (c *imagesCommand) SetStderr(io.Writer)   {}
+
+func (c *imagesCommand) Run() error {
+	for _, img := range c.images {
+		fmt.Fprintf(c.stdout, "[image: %s (%s)]\n", img.Name, img.ContentType)
+		if err := writeImage(c.stdout, c.protocol, img); err != nil {
+			fmt.Fprintln(c.stdout, err)
+		}
+	}
+	fmt.Fprint(c.stdout, "\nPress enter to go back to the email")
+	_, err := bufio.NewReader(c.stdin).ReadString('\n')
+	if err == io.EOF {
+		err = nil
+	}
+	return err
+}
+
+// showInlineImages draws the inline images of raw with protocol, or says why
+// it cannot.
+func (a *App) showInlineImages(raw string) tea.Cmd {
+	images := inlineImages(raw)
+	if len(images) == 0 {
+		return a.showToast("This email has no inline images")
+	}
+	return tea.Exec(&imagesCommand{protocol: a.options.imageProtocol, images: images}, func(err error) tea.Msg {
+		if err != nil {
+			err = fmt.Errorf("showing the images failed: %w", err)
+		}
+		return pagerClosedMsg{err: err}
+	})
+}
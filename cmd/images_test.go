@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+)
+
+func TestImageProtocol(t *testing.T) {
+	tests := []struct {
+		name string
+		mode string
+		env  map[string]string
+		want string
+	}{
+		{name: "auto in a plain terminal", mode: inlineImagesAuto, env: map[string]string{"TERM": "xterm-256color"}},
+		{name: "auto in kitty", mode: inlineImagesAuto, env: map[string]string{"TERM": "xterm-kitty"}, want: inlineImagesKitty},
+		{name: "auto in a kitty window", mode: inlineImagesAuto, env: map[string]string{"KITTY_WINDOW_ID": "1"}, want: inlineImagesKitty},
+		{name: "auto in iTerm2", mode: inlineImagesAuto, env: map[string]string{"TERM_PROGRAM": "iTerm.app"}, want: inlineImagesITerm},
+		{name: "auto in iTerm2 over ssh", mode: inlineImagesAuto, env: map[string]string{"LC_TERMINAL": "iTerm2"}, want: inlineImagesITerm},
+		{name: "auto in WezTerm", mode: inlineImagesAuto, env: map[string]string{"TERM_PROGRAM": "WezTerm"}, want: inlineImagesITerm},
+		{name: "forced kitty", mode: inlineImagesKitty, env: map[string]string{"TERM": "screen"}, want: inlineImagesKitty},
+		{name: "forced iterm", mode: inlineImagesITerm, want: inlineImagesITerm},
+		{name: "off in kitty", mode: inlineImagesOff, env: map[string]string{"TERM": "xterm-kitty"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"TERM", "KITTY_WINDOW_ID", "TERM_PROGRAM", "LC_TERMINAL"} {
+				t.Setenv(name, "")
+				os.Unsetenv(name)
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			if got := imageProtocol(tt.mode); got != tt.want {
+				t.Errorf("imageProtocol(%q) = %q, want %q", tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInlineImagesFlagValidates(t *testing.T) {
+	t.Setenv("CLEU_INLINE_IMAGES", "sixel")
+	cmd := &cli.Command{
+		Name:   "test",
+		Flags:  []cli.Flag{inlineImagesFlag()},
+		Action: func(context.Context, *cli.Command) error { return nil },
+	}
+	if err := cmd.Run(context.Background(), []string{"test"}); err == nil || !strings.Contains(err.Error(), "inline-images") {
+		t.Errorf("error = %v, want the value refused", err)
+	}
+}
+
+// testPNG returns a small PNG image.
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestInlineImages(t *testing.T) {
+	data := testPNG(t)
+	raw := "From: alice@example.com\r\n" +
+		"Subject: Logo\r\n" +
+		"Content-Type: multipart/alternative; boundary=outer\r\n" +
+		"\r\n" +
+		"--outer\r\n" +
+		"Content-Type: text/plain\r\n\r\nSee the logo\r\n" +
+		"--outer\r\n" +
+		"Content-Type: multipart/related; boundary=inner\r\n\r\n" +
+		"--inner\r\n" +
+		"Content-Type: text/html\r\n\r\n<img src=\"cid:logo@x\">\r\n" +
+		"--inner\r\n" +
+		"Content-Type: image/png; name=\"logo.png\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"Content-ID: <logo@x>\r\n\r\n" +
+		base64.StdEncoding.EncodeToString(data) + "\r\n" +
+		"--inner\r\n" +
+		"Content-Type: application/pdf; name=\"report.pdf\"\r\n" +
+		"Content-ID: <report@x>\r\n\r\n%PDF\r\n" +
+		"--inner--\r\n" +
+		"--outer--\r\n"
+
+	images := inlineImages(raw)
+	if len(images) != 1 {
+		t.Fatalf("found %d images, want 1", len(images))
+	}
+	img := images[0]
+	if img.Name != "logo.png" || img.ContentType != "image/png" || img.ContentID != "logo@x" {
+		t.Errorf("image = %+v", img.attachment)
+	}
+	if !bytes.Equal(img.data, data) {
+		t.Error("the image data was not decoded")
+	}
+	if got := inlineImages("Subject: plain\r\n\r\nHello\r\n"); got != nil {
+		t.Errorf("a plain email has images: %v", got)
+	}
+}
+
+func TestWriteImage(t *testing.T) {
+	img := inlineImage{attachment: attachment{Name: "logo.png", ContentType: "image/png"}, data: testPNG(t)}
+	tests := []struct {
+		protocol string
+		prefix   string
+		wantErr  bool
+	}{
+		{protocol: inlineImagesKitty, prefix: "\x1b_Ga=T,f=100,m=0;"},
+		{protocol: inlineImagesITerm, prefix: "\x1b]1337;File=name=" + base64.StdEncoding.EncodeToString([]byte("logo.png")) + ";"},
+		{protocol: "sixel", wantErr: true},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		err := writeImage(&out, tt.protocol, img)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: error = %v", tt.protocol, err)
+		}
+		if !strings.HasPrefix(out.String(), tt.prefix) {
+			t.Errorf("%s: wrote %q", tt.protocol, out.String())
+		}
+	}
+}
+
+func TestWriteImageKittyChunks(t *testing.T) {
+	// Noise makes the PNG too large for one chunk
+	noise := image.NewGray(image.Rect(0, 0, 100, 100))
+	seed := uint32(1)
+	for i := range noise.Pix {
+		seed = seed*1664525 + 1013904223
+		noise.Pix[i] = byte(seed >> 24)
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, noise)
+	var out bytes.Buffer
+	if err := writeImage(&out, inlineImagesKitty, inlineImage{attachment: attachment{ContentType: "image/png"}, data: buf.Bytes()}); err != nil {
+		t.Fatal(err)
+	}
+	chunks := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\x1b\\")
+	chunks = chunks[:len(chunks)-1]
+	if len(chunks) < 2 {
+		t.Fatalf("%d chunks for %d bytes", len(chunks), buf.Len())
+	}
+	for i, chunk := range chunks {
+		wantMore := "m=1;"
+		if i == len(chunks)-1 {
+			wantMore = "m=0;"
+		}
+		if !strings.Contains(chunk, wantMore) {
+			t.Errorf("chunk %d does not say %s", i, wantMore)
+		}
+		if payload := chunk[strings.Index(chunk, ";")+1:]; len(payload) > 4096 {
+			t.Errorf("chunk %d has %d bytes", i, len(payload))
+		}
+	}
+}
+
+func TestInlineImagesKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		protocol  string
+		wantToast string
+	}{
+		{name: "not drawn", wantToast: "Images are not drawn in this terminal, see --inline-images"},
+		{name: "no images", protocol: inlineImagesKitty, wantToast: "This email has no inline images"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := newSelectionApp(t, readOptions{imageProtocol: tt.protocol})
+			pressKey(t, a, "enter")
+			if a.state != emailView {
+				t.Fatal("the email did not open")
+			}
+			pressKey(t, a, "I")
+			if a.successMessage != tt.wantToast {
+				t.Errorf("toast = %q, want %q", a.successMessage, tt.wantToast)
+			}
+		})
+	}
+}
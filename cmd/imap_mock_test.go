@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/backend"
+	"github.com/emersion/go-imap/backend/memory"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-imap/server"
+)
+
+// newMockIMAP starts an in-memory IMAP server for the duration of the test.
+// Its INBOX holds the memory backend's own message, UID 6, followed by
+// messages, so the first of them is UID 7. It returns a client logged in
+// with INBOX selected, and the server side of the account for checks.
+func newMockIMAP(t *testing.T, messages ...string) (*client.Client, *memory.User) {
+	t.Helper()
+	be, user := newMemoryBackend(t, messages)
+	return serveMockIMAP(t, be), user
+}
+
+// newFailingFetchIMAP is newMockIMAP with FETCH answering NO while the
+// returned switch is set, as a server does when it cannot read its store.
+func newFailingFetchIMAP(t *testing.T, messages ...string) (*client.Client, *atomic.Bool) {
+	t.Helper()
+	hooks := new(fetchHooks)
+	return newHookedIMAP(t, hooks, messages), &hooks.fail
+}
+
+// newCountingFetchIMAP is newMockIMAP counting the FETCH commands the
+// server gets, UID FETCH included.
+func newCountingFetchIMAP(t *testing.T, messages ...string) (*client.Client, *atomic.Int32) {
+	t.Helper()
+	hooks := new(fetchHooks)
+	return newHookedIMAP(t, hooks, messages), &hooks.fetches
+}
+
+func newHookedIMAP(t *testing.T, hooks *fetchHooks, messages []string) *client.Client {
+	t.Helper()
+	be, _ := newMemoryBackend(t, messages)
+	return serveMockIMAP(t, &hookedBackend{Backend: be, hooks: hooks})
+}
+
+func newMemoryBackend(t *testing.T, messages []string) (backend.Backend, *memory.User) {
+	t.Helper()
+	be := memory.New()
+	user, err := be.Login(nil, "username", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inbox, err := user.GetMailbox("INBOX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range messages {
+		if err := inbox.CreateMessage(nil, time.Now(), bytes.NewBufferString(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return be, user.(*memory.User)
+}
+
+// serveMockIMAP serves be for the duration of the test and returns a client
+// logged in with INBOX selected.
+func serveMockIMAP(t *testing.T, be backend.Backend) *client.Client {
+	t.Helper()
+	s := server.New(be)
+	s.AllowInsecureAuth = true
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(ln)
+	t.Cleanup(func() { s.Close() })
+
+	c, err := client.Dial(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Logout() })
+	if err := c.Login("username", "password"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Select("INBOX", false); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// fetchHooks is shared by hookedBackend and what it hands out: fetches
+// counts FETCH commands and fail makes them fail.
+type fetchHooks struct {
+	fail    atomic.Bool
+	fetches atomic.Int32
+}
+
+// hookedBackend, hookedUser and hookedMailbox run FETCH through hooks.
+type hookedBackend struct {
+	backend.Backend
+	hooks *fetchHooks
+}
+
+func (b *hookedBackend) Login(info *imap.ConnInfo, username, password string) (backend.User, error) {
+	user, err := b.Backend.Login(info, username, password)
+	if err != nil {
+		return nil, err
+	}
+	return &hookedUser{User: user, hooks: b.hooks}, nil
+}
+
+type hookedUser struct {
+	backend.User
+	hooks *fetchHooks
+}
+
+func (u *hookedUser) GetMailbox(name string) (backend.Mailbox, error) {
+	mailbox, err := u.User.GetMailbox(name)
+	if err != nil {
+		return nil, err
+	}
+	return &hookedMailbox{Mailbox: mailbox, hooks: u.hooks}, nil
+}
+
+type hookedMailbox struct {
+	backend.Mailbox
+	hooks *fetchHooks
+}
+
+func (m *hookedMailbox) ListMessages(uid bool, seqSet *imap.SeqSet, items []imap.FetchItem, ch chan<- *imap.Message) error {
+	m.hooks.fetches.Add(1)
+	if m.hooks.fail.Load() {
+		close(ch)
+		return errors.New("mailbox store unavailable")
+	}
+	return m.Mailbox.ListMessages(uid, seqSet, items, ch)
+}
+
+// newTestApp returns an App reading INBOX over c, sized like a terminal,
+// with the first page of emails loaded.
+func newTestApp(t *testing.T, c *client.Client, options readOptions) *App {
+	t.Helper()
+	if options.perPage == 0 {
+		options.perPage = 20
+	}
+	a := NewApp(imapConfig{inbox: "INBOX"}, options)
+	a.client = c
+	a.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	runCmd(t, a, a.loadEmails(1, false))
+	return a
+}
+
+// runCmd runs cmd and feeds what it returns back to the app, following
+// batches and the commands Update returns, until nothing is left. Ticks,
+// which would wait for real time, are skipped.
+func runCmd(t *testing.T, a *App, cmd tea.Cmd) {
+	t.Helper()
+	pending := []tea.Cmd{cmd}
+	for steps := 0; len(pending) > 0; steps++ {
+		if steps > 100 {
+			t.Fatal("commands did not settle")
+		}
+		next := pending[0]
+		pending = pending[1:]
+		if next == nil {
+			continue
+		}
+		// Commands that time out keep running, so each gets its own copy
+		done := make(chan tea.Msg, 1)
+		go func() { done <- next() }()
+		var msg tea.Msg
+		select {
+		case msg = <-done:
+		case <-time.After(200 * time.Millisecond):
+			// A tick or a wait on a channel
+			continue
+		}
+		switch msg := msg.(type) {
+		case nil:
+		case tea.BatchMsg:
+			pending = append(pending, msg...)
+		case tea.QuitMsg, spinner.TickMsg:
+		default:
+			_, next := a.Update(msg)
+			pending = append(pending, next)
+		}
+	}
+}
+
+// pressKey sends key to the app and runs what it triggers. Named keys such
+// as "enter" or "esc" are sent as such, anything else as typed runes.
+func pressKey(t *testing.T, a *App, key string) {
+	t.Helper()
+	msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+	for keyType, name := range map[tea.KeyType]string{
+		tea.KeyEnter: "enter", tea.KeyEsc: "esc", tea.KeyDown: "down", tea.KeyUp: "up",
+		tea.KeyCtrlS: "ctrl+s", tea.KeyTab: "tab", tea.KeySpace: " ", tea.KeyLeft: "left",
+	} {
+		if key == name {
+			msg = tea.KeyMsg{Type: keyType}
+		}
+	}
+	_, cmd := a.Update(msg)
+	runCmd(t, a, cmd)
+}
+
+// serverFlags returns the flags the server has for uid in INBOX.
+func serverFlags(t *testing.T, user *memory.User, uid uint32) []string {
+	t.Helper()
+	mailbox, err := user.GetMailbox("INBOX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range mailbox.(*memory.Mailbox).Messages {
+		if message.Uid == uid {
+			return message.Flags
+		}
+	}
+	t.Fatalf("no message with UID %d", uid)
+	return nil
+}
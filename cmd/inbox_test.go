@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/emersion/go-imap/backend/memory"
+	"github.com/emersion/go-imap/client"
+	"github.com/urfave/cli/v3"
+)
+
+// addMailbox creates name on the mock server holding messages.
+func addMailbox(t *testing.T, user *memory.User, name string, messages ...string) *memory.Mailbox {
+	t.Helper()
+	if err := user.CreateMailbox(name); err != nil {
+		t.Fatal(err)
+	}
+	mailbox, err := user.GetMailbox(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range messages {
+		if err := mailbox.CreateMessage(nil, time.Now(), strings.NewReader(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return mailbox.(*memory.Mailbox)
+}
+
+func selectedMailbox(c *client.Client) string {
+	if mailbox := c.Mailbox(); mailbox != nil {
+		return mailbox.Name
+	}
+	return ""
+}
+
+func TestConfiguredInboxIsUsedEverywhere(t *testing.T) {
+	const inbox = "[Gmail]/All Mail"
+	c, user := newMockIMAP(t, testMessage("Only in INBOX", "text/plain", "Hi"))
+	allMail := addMailbox(t, user, inbox,
+		testMessage("Archived one", "text/plain", "1"),
+		testMessage("Archived two", "text/plain", "2"),
+	)
+	trash := addMailbox(t, user, "Trash")
+
+	a := NewApp(imapConfig{inbox: inbox}, readOptions{perPage: 20, trashFolder: "Trash"})
+	a.client = c
+	a.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	runCmd(t, a, a.loadEmails(1, false))
+	if a.err != nil {
+		t.Fatal(a.err)
+	}
+	var subjects []string
+	for _, email := range a.emails {
+		subjects = append(subjects, email.Subject)
+	}
+	slices.Sort(subjects)
+	if !slices.Equal(subjects, []string{"Archived one", "Archived two"}) {
+		t.Errorf("listed %q, want the emails of %s", subjects, inbox)
+	}
+	if a.totalMessages != 2 {
+		t.Errorf("totalMessages = %d, want 2", a.totalMessages)
+	}
+
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{name: "delete", run: func() error {
+			if ok, message := moveEmailsToTrash(c, a.config.inbox, []uint32{allMail.Messages[0].Uid}, a.options.trashFolder); !ok {
+				return errors.New(message)
+			}
+			return nil
+		}},
+		{name: "empty trash", run: func() error {
+			_, _, err := emptyTrashFolder(c, a.config.inbox, a.options.trashFolder)
+			return err
+		}},
+		{name: "reload", run: func() error {
+			runCmd(t, a, a.loadEmails(1, false))
+			return a.err
+		}},
+	}
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		// Every operation goes back to the configured inbox, never INBOX
+		if got := selectedMailbox(c); got != inbox {
+			t.Errorf("after %s, %q is selected, want %q", step.name, got, inbox)
+		}
+	}
+	if len(allMail.Messages) != 1 || len(trash.Messages) != 0 || len(a.emails) != 1 {
+		t.Errorf("%d in %s, %d in the trash, %d listed; want 1, 0, 1", len(allMail.Messages), inbox, len(trash.Messages), len(a.emails))
+	}
+}
+
+func TestInboxSetting(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		args []string
+		want string
+	}{
+		{name: "default", want: "INBOX"},
+		{name: "env", env: "[Gmail]/All Mail", want: "[Gmail]/All Mail"},
+		{name: "flag", env: "Other", args: []string{"--inbox", "Archive"}, want: "Archive"},
+		{name: "blank", args: []string{"--inbox", "  "}, want: "INBOX"},
+		{name: "modified UTF-7", args: []string{"--inbox", "Courrier entrant &AOk-t&AOk-"}, want: "Courrier entrant été"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setServerEnv(t)
+			t.Setenv("CLEU_INBOX", tt.env)
+			if tt.env == "" {
+				os.Unsetenv("CLEU_INBOX")
+			}
+			imap, _ := loadConfigsWith(t, imapFlags(), tt.args...)
+			if imap.inbox != tt.want {
+				t.Errorf("inbox = %q, want %q", imap.inbox, tt.want)
+			}
+		})
+	}
+}
+
+func TestMailboxOrInbox(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{want: "[Gmail]/All Mail"},
+		{args: []string{"--mailbox", "Sent"}, want: "Sent"},
+	}
+	config := imapConfig{inbox: "[Gmail]/All Mail"}
+	for _, tt := range tests {
+		var got string
+		cmd := &cli.Command{
+			Name:  "list",
+			Flags: []cli.Flag{&cli.StringFlag{Name: "mailbox"}},
+			Action: func(ctx context.Context, c *cli.Command) error {
+				got = mailboxOrInbox(c, config)
+				return nil
+			},
+		}
+		if err := cmd.Run(context.Background(), append([]string{"list"}, tt.args...)); err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%v: mailbox = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
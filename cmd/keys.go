@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// setListKeys configures the list's movement keys. Vim motions add j/k on
+// top of the arrows; "gg" is handled by App.handleMotion so a single g does
+// not jump.
+func setListKeys(l *list.Model, vim bool) {
+	if vim {
+		l.KeyMap.CursorUp = key.NewBinding(key.WithKeys("up", "k"))
+		l.KeyMap.CursorDown = key.NewBinding(key.WithKeys("down", "j"))
+		l.KeyMap.GoToStart = key.NewBinding(key.WithKeys("home"))
+		l.KeyMap.GoToEnd = key.NewBinding(key.WithKeys("end", "G"))
+	} else {
+		l.KeyMap.CursorUp = key.NewBinding(key.WithKeys("up"))
+		l.KeyMap.CursorDown = key.NewBinding(key.WithKeys("down"))
+		l.KeyMap.GoToStart = key.NewBinding(key.WithKeys("home"))
+		l.KeyMap.GoToEnd = key.NewBinding(key.WithKeys("end"))
+	}
+}
+
+// setViewportKeys configures scrolling in the email view. The viewport's
+// default d/u half-page keys would clash with delete, so half pages are
+// always on ctrl+d/ctrl+u.
+func setViewportKeys(vp *viewport.Model, vim bool) {
+	vp.KeyMap.HalfPageDown = key.NewBinding(key.WithKeys("ctrl+d"))
+	vp.KeyMap.HalfPageUp = key.NewBinding(key.WithKeys("ctrl+u"))
+	vp.KeyMap.PageDown = key.NewBinding(key.WithKeys("pgdown", " "))
+	vp.KeyMap.PageUp = key.NewBinding(key.WithKeys("pgup"))
+	if vim {
+		vp.KeyMap.Up = key.NewBinding(key.WithKeys("up", "k"))
+		vp.KeyMap.Down = key.NewBinding(key.WithKeys("down", "j"))
+	} else {
+		vp.KeyMap.Up = key.NewBinding(key.WithKeys("up"))
+		vp.KeyMap.Down = key.NewBinding(key.WithKeys("down"))
+	}
+}
+
+// handleMotion applies the motions the list and viewport have no binding
+// for: gg and G in the email view, and home/end and half pages in the
+// list. It reports whether the key was used.
+func (a *App) handleMotion(msg tea.KeyMsg) bool {
+	pendingG := a.pendingG
+	a.pendingG = false
+
+	switch a.state {
+	case listView:
+		if a.list.FilterState() == list.Filtering {
+			return false
+		}
+		switch msg.String() {
+		case "g":
+			if !a.options.vimKeys {
+				return false
+			}
+			if pendingG {
+				a.list.Select(0)
+			} else {
+				a.pendingG = true
+			}
+			return true
+		case "ctrl+d", "ctrl+u":
+			for range max(a.list.Paginator.PerPage/2, 1) {
+				if msg.String() == "ctrl+d" {
+					a.list.CursorDown()
+				} else {
+					a.list.CursorUp()
+				}
+			}
+			return true
+		}
+
+	case emailView:
+		switch msg.String() {
+		case "home":
+			a.viewport.GotoTop()
+			return true
+		case "end":
+			a.viewport.GotoBottom()
+			return true
+		case "g":
+			if !a.options.vimKeys {
+				return false
+			}
+			if pendingG {
+				a.viewport.GotoTop()
+			} else {
+				a.pendingG = true
+			}
+			return true
+		case "G":
+			if !a.options.vimKeys {
+				return false
+			}
+			a.viewport.GotoBottom()
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+var List = &cli.Command{
+	Name:  "list",
+	Usage: "List emails without starting the interface",
+	Flags: append(imapFlags(),
+		themeFlag(),
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "print the emails as a JSON array",
+		},
+		&cli.StringFlag{
+			Name:  "mailbox",
+			Usage: "mailbox to list (default: the --inbox mailbox)",
+		},
+		&cli.IntFlag{
+			Name:  "page",
+			Usage: "page to list, starting from the newest emails",
+			Value: 1,
+			Validator: func(v int) error {
+				if v < 1 {
+					return fmt.Errorf("page must be a positive number, got %d", v)
+				}
+				return nil
+			},
+		},
+		&cli.IntFlag{
+			Name:    "per-page",
+			Usage:   "number of emails per page",
+			Value:   50,
+			Sources: cli.EnvVars("CLEU_PER_PAGE"),
+			Validator: func(v int) error {
+				if v < 1 {
+					return fmt.Errorf("per-page must be a positive number, got %d", v)
+				}
+				return nil
+			},
+		},
+	),
+	Action: func(ctx context.Context, c *cli.Command) error {
+		if err := applyTheme(c.String("theme")); err != nil {
+			return err
+		}
+		config, err := loadIMAPConfig(c)
+		if err != nil {
+			return err
+		}
+
+		imapClient, err := connectToServer(config)
+		if err != nil {
+			return err
+		}
+		defer imapClient.Logout()
+
+		emails, _, err := fetchEmails(imapClient, mailboxOrInbox(c, config), false, 0, (c.Int("page")-1)*c.Int("per-page"), c.Int("per-page"), nil)
+		if err != nil {
+			return fmt.Errorf("failed to fetch emails: %w", err)
+		}
+
+		if c.Bool("json") {
+			summaries := make([]emailSummary, len(emails))
+			for i, email := range emails {
+				summaries[i] = newEmailSummary(email)
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(summaries)
+		}
+
+		for _, email := range emails {
+			status := " "
+			if !email.Seen {
+				status = "*"
+			}
+			fmt.Printf("%s %d\t%s\t%s\t%s\n", status, email.UID, email.Date.Format("2006-01-02 15:04"), email.From, email.Subject)
+		}
+		return nil
+	},
+}
+
+// emailSummary is the JSON shape of an email printed by `cleu list --json`.
+type emailSummary struct {
+	UID     uint32 `json:"uid"`
+	Subject string `json:"subject"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Date    string `json:"date"`
+	Seen    bool   `json:"seen"`
+}
+
+func newEmailSummary(email Email) emailSummary {
+	from := email.FromAddress
+	if from == "" {
+		from = email.From
+	}
+	return emailSummary{
+		UID:     email.UID,
+		Subject: email.Subject,
+		From:    from,
+		To:      email.To,
+		Date:    email.Date.Format(time.RFC3339),
+		Seen:    email.Seen,
+	}
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/urfave/cli/v3"
+)
+
+// logger receives debug output about the IMAP and SMTP sessions. It discards
+// everything unless --verbose or CLEU_LOG_FILE asks for it.
+var logger = log.New(io.Discard, "", log.LstdFlags)
+
+// logOutput is where logger writes, nil when logging is off.
+var logOutput io.Writer
+
+// LogFlags are the global flags that turn on logging.
+func LogFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "log the IMAP and SMTP sessions, to stderr or CLEU_LOG_FILE",
+		},
+		&cli.StringFlag{
+			Name:      "log-file",
+			Usage:     "append logs to this file (implies --verbose)",
+			Sources:   cli.EnvVars("CLEU_LOG_FILE"),
+			TakesFile: true,
+		},
+	}
+}
+
+// SetupLogging points logger at the output picked by LogFlags. It runs
+// before any command.
+func SetupLogging(ctx context.Context, c *cli.Command) (context.Context, error) {
+	if path := c.String("log-file"); path != "" {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return ctx, configError{fmt.Errorf("could not open log file: %w", err)}
+		}
+		logOutput = file
+	} else if c.Bool("verbose") {
+		logOutput = os.Stderr
+	}
+	if logOutput != nil {
+		logger.SetOutput(logOutput)
+	}
+	return ctx, nil
+}
+
+// logAwayFromTerminal moves logging off stderr while the interface owns the
+// screen, into cleu.log in the config directory.
+func logAwayFromTerminal() error {
+	if logOutput != os.Stderr {
+		return nil
+	}
+	path, err := configSubdir("cleu.log")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("could not create config directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("could not open log file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Logging to %s while the interface is open\n", path)
+	logOutput = file
+	logger.SetOutput(file)
+	return nil
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-imap/utf7"
+)
+
+// mailboxName turns a mailbox name copied in its modified UTF-7 wire form,
+// such as "Envoy&AOk-s", into UTF-8. go-imap encodes names for the server
+// and decodes the ones it lists, so everything else already is UTF-8 and is
+// kept as it is.
+func mailboxName(name string) string {
+	if !strings.Contains(name, "&") {
+		return name
+	}
+	decoded, err := utf7.Encoding.NewDecoder().String(name)
+	if err != nil {
+		return name
+	}
+	return decoded
+}
+
+// listMailboxes returns every mailbox on the server, as LIST "" "*" reports
+// them.
+func listMailboxes(imapClient *client.Client) ([]*imap.MailboxInfo, error) {
+	mailboxes := make(chan *imap.MailboxInfo, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- imapClient.List("", "*", mailboxes)
+	}()
+
+	var listed []*imap.MailboxInfo
+	for mailbox := range mailboxes {
+		listed = append(listed, mailbox)
+	}
+	if err := <-done; err != nil {
+		return nil, err
+	}
+	return listed, nil
+}
+
+// specialUseMailbox returns the first mailbox the server lists with attr,
+// such as imap.TrashAttr, or "" when there is none.
+func specialUseMailbox(imapClient *client.Client, attr string) (string, error) {
+	mailboxes, err := listMailboxes(imapClient)
+	if err != nil {
+		return "", err
+	}
+	for _, mailbox := range mailboxes {
+		if hasAttribute(mailbox, attr) {
+			return mailbox.Name, nil
+		}
+	}
+	return "", nil
+}
+
+func hasAttribute(mailbox *imap.MailboxInfo, attr string) bool {
+	for _, a := range mailbox.Attributes {
+		if strings.EqualFold(a, attr) {
+			return true
+		}
+	}
+	return false
+}
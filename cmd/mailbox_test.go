@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/emersion/go-imap/utf7"
+)
+
+func TestMailboxName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "INBOX", want: "INBOX"},
+		{name: "Envoy&AOk-s", want: "Envoyés"},
+		{name: "&AMk-l&AOk-ments supprim&AOk-s", want: "Éléments supprimés"},
+		{name: "&ZeVnLIqe-", want: "日本語"},
+		{name: "Tom &- Jerry", want: "Tom & Jerry"},
+		{name: "Envoyés", want: "Envoyés"},
+		{name: "Tom & Jerry", want: "Tom & Jerry"},
+		{name: "[Gmail]/All Mail", want: "[Gmail]/All Mail"},
+	}
+	for _, tt := range tests {
+		if got := mailboxName(tt.name); got != tt.want {
+			t.Errorf("mailboxName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNonASCIIMailboxRoundTrip(t *testing.T) {
+	const folder = "Éléments supprimés"
+	wire, err := utf7.Encoding.NewEncoder().String(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wire != "&AMk-l&AOk-ments supprim&AOk-s" {
+		t.Fatalf("%q encodes to %q", folder, wire)
+	}
+	if got := mailboxName(wire); got != folder {
+		t.Fatalf("%q decodes to %q", wire, got)
+	}
+
+	c, user := newMockIMAP(t, testMessage("To delete", "text/plain", "Bye"))
+	trash := addMailbox(t, user, folder)
+
+	mailboxes, err := listMailboxes(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, mailbox := range mailboxes {
+		names = append(names, mailbox.Name)
+	}
+	if !slices.Contains(names, folder) {
+		t.Errorf("listed %q, want %q among them", names, folder)
+	}
+
+	// A trash folder given in its wire form, as copied from another client
+	configured := mailboxName(wire)
+	if got, err := findTrashFolder(c, configured); err != nil || got != folder {
+		t.Fatalf("findTrashFolder(%q) = %q, %v", configured, got, err)
+	}
+	if ok, message := moveEmailsToTrash(c, "INBOX", []uint32{7}, configured); !ok {
+		t.Fatal(message)
+	} else if message != "Email moved to "+folder {
+		t.Errorf("message = %q", message)
+	}
+	if len(trash.Messages) != 1 {
+		t.Errorf("%d messages in %s, want 1", len(trash.Messages), folder)
+	}
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// doubleClickInterval is how close two clicks on the same email must be to
+// open it.
+const doubleClickInterval = 400 * time.Millisecond
+
+// handleMouse selects emails on click, opens them on double click and
+// scrolls with the wheel. The email view's viewport scrolls by itself.
+func (a *App) handleMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	if a.state != listView || a.list.FilterState() == list.Filtering {
+		return a, nil
+	}
+
+	switch msg.Button {
+	case tea.MouseButtonWheelUp:
+		a.list.CursorUp()
+	case tea.MouseButtonWheelDown:
+		a.list.CursorDown()
+		a.skipEndOfMailbox()
+	case tea.MouseButtonLeft:
+		if msg.Action != tea.MouseActionPress {
+			return a, nil
+		}
+		index, ok := a.listIndexAt(msg.Y)
+		if !ok {
+			return a, nil
+		}
+		double := index == a.lastClickIndex && time.Since(a.lastClick) < doubleClickInterval
+		a.list.Select(index)
+		if _, end := a.list.SelectedItem().(EndOfMailboxItem); end {
+			a.skipEndOfMailbox()
+			return a, nil
+		}
+		a.lastClickIndex, a.lastClick = index, time.Now()
+		if double {
+			a.lastClick = time.Time{}
+			return a.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		}
+	}
+	return a, nil
+}
+
+// listIndexAt maps a screen row to the index of the list item drawn there.
+func (a *App) listIndexAt(y int) (int, bool) {
+	header := 0
+	if a.list.ShowTitle() || a.list.ShowFilter() {
+		header += lipgloss.Height(a.list.Styles.TitleBar.Render(a.list.Styles.Title.Render(" ")))
+	}
+	if a.list.ShowStatusBar() {
+		header += lipgloss.Height(a.list.Styles.StatusBar.Render(" "))
+	}
+	row := y - header
+	if row < 0 {
+		return 0, false
+	}
+
+	itemRows := listItemHeight + listItemSpacing
+	if row%itemRows >= listItemHeight {
+		return 0, false
+	}
+	index := a.list.Paginator.Page*a.list.Paginator.PerPage + row/itemRows
+	if row/itemRows >= a.list.Paginator.PerPage || index >= len(a.list.VisibleItems()) {
+		return 0, false
+	}
+	return index, true
+}
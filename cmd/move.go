@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// folderItem is a mailbox offered by the folder picker.
+type folderItem string
+
+func (f folderItem) FilterValue() string { return string(f) }
+func (f folderItem) Title() string       { return string(f) }
+func (f folderItem) Description() string { return "" }
+
+type foldersLoadedMsg struct {
+	folders []string
+}
+type emailsMovedMsg struct {
+	uids   []uint32
+	folder string
+}
+
+// actionTargets returns the emails M and d act on: the selected ones in the
+// list, or else the highlighted or open email.
+func (a *App) actionTargets() []uint32 {
+	if a.state == listView {
+		if uids := a.markedUIDs(); len(uids) > 0 {
+			return uids
+		}
+	}
+	if email, ok := a.currentEmail(); ok {
+		return []uint32{email.UID}
+	}
+	return nil
+}
+
+// loadFolders lists the mailboxes emails can be moved to: every one that
+// can be selected, except the one being read.
+func (a *App) loadFolders() tea.Cmd {
+	return a.withReconnect(func() tea.Msg {
+		mailboxes, err := listMailboxes(a.client)
+		if err != nil {
+			return errorMsg(fmt.Errorf("failed to list folders: %w", wrapTimeout(err, "listing folders", a.config.commandTimeout)))
+		}
+		var folders []string
+		for _, mailbox := range mailboxes {
+			if hasAttribute(mailbox, imap.NoSelectAttr) || mailbox.Name == a.config.inbox {
+				continue
+			}
+			folders = append(folders, mailbox.Name)
+		}
+		slices.SortFunc(folders, func(x, y string) int {
+			return strings.Compare(strings.ToLower(x), strings.ToLower(y))
+		})
+		return foldersLoadedMsg{folders: folders}
+	})
+}
+
+// openFolderPicker shows folders to pick where a.moveUIDs go.
+func (a *App) openFolderPicker(folders []string) {
+	items := make([]list.Item, len(folders))
+	for i, folder := range folders {
+		items[i] = folderItem(folder)
+	}
+	delegate := list.NewDefaultDelegate()
+	delegate.ShowDescription = false
+	delegate.SetSpacing(0)
+	picker := list.New(items, delegate, 0, 0)
+	setListKeys(&picker, a.options.vimKeys)
+	picker.KeyMap.Quit.SetEnabled(false)
+	picker.KeyMap.ForceQuit.SetEnabled(false)
+	picker.SetShowStatusBar(false)
+	picker.SetShowHelp(false)
+	picker.Title = fmt.Sprintf("Move %d email(s) to…", len(a.moveUIDs))
+	a.folderPicker = picker
+	a.setFolderPickerSize()
+	a.moveReturn = a.state
+	a.state = folderPickerView
+}
+
+func (a *App) setFolderPickerSize() {
+	// The help line below the picker
+	a.folderPicker.SetSize(a.width, max(a.height-2, 3))
+}
+
+// updateFolderPicker handles keys in the folder picker. While a filter is
+// typed the keys go to the list, so enter and esc apply or drop the filter.
+func (a *App) updateFolderPicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if a.folderPicker.FilterState() != list.Filtering {
+		switch msg.String() {
+		case "esc", "q", "ctrl+c":
+			if a.folderPicker.FilterState() == list.FilterApplied && msg.String() == "esc" {
+				break
+			}
+			a.state = a.moveReturn
+			return a, nil
+		case "enter":
+			folder, ok := a.folderPicker.SelectedItem().(folderItem)
+			if !ok || a.moving {
+				return a, nil
+			}
+			a.moving = true
+			return a, a.moveEmails(a.moveUIDs, string(folder))
+		}
+	}
+	var cmd tea.Cmd
+	a.folderPicker, cmd = a.folderPicker.Update(msg)
+	return a, cmd
+}
+
+// moveEmails moves the emails with uids to folder.
+func (a *App) moveEmails(uids []uint32, folder string) tea.Cmd {
+	return a.withReconnect(func() tea.Msg {
+		if err := moveByUID(a.client, uids, folder); err != nil {
+			return errorMsg(fmt.Errorf("failed to move emails to %s: %w", folder, wrapTimeout(err, "moving emails", a.config.commandTimeout)))
+		}
+		return emailsMovedMsg{uids: uids, folder: folder}
+	})
+}
+
+// moveByUID moves uids from the selected mailbox to folder with UID MOVE.
+// go-imap only falls back to COPY, STORE \Deleted and EXPUNGE when the
+// server does not advertise MOVE, so the fallback is also tried when the
+// server refuses MOVE for the mailbox.
+func moveByUID(imapClient *client.Client, uids []uint32, folder string) error {
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(uids...)
+	err := imapClient.UidMove(seqSet, folder)
+	if err == nil || isClosed(imapClient) {
+		return err
+	}
+	if copyErr := imapClient.UidCopy(seqSet, folder); copyErr != nil {
+		return err
+	}
+	item := imap.FormatFlagsOp(imap.AddFlags, true)
+	if err := imapClient.UidStore(seqSet, item, []interface{}{imap.DeletedFlag}, nil); err != nil {
+		return fmt.Errorf("copied, but could not mark the originals as deleted: %w", err)
+	}
+	if err := imapClient.Expunge(nil); err != nil {
+		return fmt.Errorf("copied, but could not expunge the originals: %w", err)
+	}
+	return nil
+}
+
+// removeEmails drops the emails with uids from the loaded list and the
+// counts, once they left the mailbox.
+func (a *App) removeEmails(uids []uint32) {
+	a.emails = slices.DeleteFunc(a.emails, func(email Email) bool {
+		if !slices.Contains(uids, email.UID) {
+			return false
+		}
+		if !email.Seen && a.unread != nil && *a.unread > 0 {
+			unread := *a.unread - 1
+			a.unread = &unread
+		}
+		a.totalMessages--
+		return true
+	})
+	for _, uid := range uids {
+		delete(a.marked, uid)
+	}
+	a.updateEmailList()
+	a.updateTitle()
+}
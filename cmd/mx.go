@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// mxVerifier looks up the MX records of recipient domains, once per domain,
+// to catch typos such as @gmial.com before sending.
+type mxVerifier struct {
+	lookupMX func(domain string) ([]*net.MX, error)
+	results  map[string]string
+}
+
+func newMXVerifier() *mxVerifier {
+	return &mxVerifier{lookupMX: net.LookupMX, results: make(map[string]string)}
+}
+
+// check returns why mail to domain may not be deliverable, or "" when it has
+// MX records.
+func (v *mxVerifier) check(domain string) string {
+	domain = strings.ToLower(domain)
+	if problem, ok := v.results[domain]; ok {
+		return problem
+	}
+	problem := ""
+	records, err := v.lookupMX(domain)
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		problem = "does not exist"
+	case err != nil:
+		problem = fmt.Sprintf("could not be looked up (%v)", err)
+	case len(records) == 0:
+		problem = "has no MX records"
+	}
+	v.results[domain] = problem
+	return problem
+}
+
+// warnings lists the recipients of email whose domain has no MX records.
+func (v *mxVerifier) warnings(email *EmailForm) []string {
+	var warnings []string
+	for _, list := range []string{email.To, email.Cc, email.Bcc} {
+		recipients, err := parseRecipients(list)
+		if err != nil {
+			continue
+		}
+		for _, recipient := range recipients {
+			domain := recipient.Address[strings.LastIndex(recipient.Address, "@")+1:]
+			if problem := v.check(domain); problem != "" {
+				warnings = append(warnings, fmt.Sprintf("%s: %s %s", recipient.Address, domain, problem))
+			}
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"errors"
+	"net"
+	"slices"
+	"testing"
+)
+
+// fakeResolver answers MX lookups from records and counts them by domain.
+type fakeResolver struct {
+	records map[string][]*net.MX
+	failing map[string]error
+	lookups map[string]int
+}
+
+func (r *fakeResolver) lookupMX(domain string) ([]*net.MX, error) {
+	if r.lookups == nil {
+		r.lookups = make(map[string]int)
+	}
+	r.lookups[domain]++
+	if err, ok := r.failing[domain]; ok {
+		return nil, err
+	}
+	records, ok := r.records[domain]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: domain, IsNotFound: true}
+	}
+	return records, nil
+}
+
+func newFakeMXVerifier() (*mxVerifier, *fakeResolver) {
+	resolver := &fakeResolver{
+		records: map[string][]*net.MX{
+			"example.com": {{Host: "mx.example.com.", Pref: 10}},
+			"empty.com":   {},
+		},
+		failing: map[string]error{"timeout.com": errors.New("i/o timeout")},
+	}
+	v := newMXVerifier()
+	v.lookupMX = resolver.lookupMX
+	return v, resolver
+}
+
+func TestMXVerifierCheck(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   string
+	}{
+		{domain: "example.com", want: ""},
+		{domain: "EXAMPLE.com", want: ""},
+		{domain: "gmial.com", want: "does not exist"},
+		{domain: "empty.com", want: "has no MX records"},
+		{domain: "timeout.com", want: "could not be looked up (i/o timeout)"},
+	}
+	v, _ := newFakeMXVerifier()
+	for _, tt := range tests {
+		if got := v.check(tt.domain); got != tt.want {
+			t.Errorf("check(%q) = %q, want %q", tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestMXVerifierCachesPerDomain(t *testing.T) {
+	v, resolver := newFakeMXVerifier()
+	for _, domain := range []string{"example.com", "Example.COM", "gmial.com", "example.com", "gmial.com"} {
+		v.check(domain)
+	}
+	want := map[string]int{"example.com": 1, "gmial.com": 1}
+	for domain, count := range want {
+		if resolver.lookups[domain] != count {
+			t.Errorf("%s looked up %d times, want %d", domain, resolver.lookups[domain], count)
+		}
+	}
+	if len(resolver.lookups) != len(want) {
+		t.Errorf("lookups = %v", resolver.lookups)
+	}
+}
+
+func TestMXWarnings(t *testing.T) {
+	tests := []struct {
+		name  string
+		email EmailForm
+		want  []string
+	}{
+		{name: "all fine", email: EmailForm{To: "a@example.com", Cc: "b@example.com"}},
+		{
+			name:  "typo in every list",
+			email: EmailForm{To: "a@gmial.com", Cc: "Bob <b@empty.com>", Bcc: "c@example.com, d@gmial.com"},
+			want:  []string{"a@gmial.com: gmial.com does not exist", "b@empty.com: empty.com has no MX records", "d@gmial.com: gmial.com does not exist"},
+		},
+		{name: "unparsable list skipped", email: EmailForm{To: "not an address", Cc: "x@gmial.com"}, want: []string{"x@gmial.com: gmial.com does not exist"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, resolver := newFakeMXVerifier()
+			if got := v.warnings(&tt.email); !slices.Equal(got, tt.want) {
+				t.Errorf("warnings = %q, want %q", got, tt.want)
+			}
+			for domain, count := range resolver.lookups {
+				if count != 1 {
+					t.Errorf("%s looked up %d times", domain, count)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+// sendAtLayout is the local time format accepted by --send-at.
+const sendAtLayout = "2006-01-02 15:04"
+
+var FlushOutbox = &cli.Command{
+	Name:  "flush-outbox",
+	Usage: "Send queued emails whose scheduled time has passed",
+	Flags: smtpFlags(),
+	Action: func(ctx context.Context, c *cli.Command) error {
+		config, err := loadSMTPConfig(c)
+		if err != nil {
+			return err
+		}
+
+		dir, err := outboxDir()
+		if err != nil {
+			return err
+		}
+		paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			return err
+		}
+
+		// Due emails share one connection
+		session := newSMTPSession(config)
+		defer session.close()
+
+		failed, err := flushQueued(session, paths)
+		if err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d queued email(s) could not be sent", failed)
+		}
+		return nil
+	},
+}
+
+// flushQueued sends the queued emails at paths whose time has come over
+// session, removing each one from the outbox once it went out, and returns
+// how many could not be sent. An email that reached some of its recipients
+// is removed too, with a warning about the others, so that flushing again
+// does not send it twice to the ones who got it.
+func flushQueued(session *smtpSession, paths []string) (int, error) {
+	failed := 0
+	for _, path := range paths {
+		queued, err := readQueuedEmail(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed++
+			continue
+		}
+		if time.Now().Before(queued.SendAt) {
+			continue
+		}
+
+		queued.Email.Confirm = true
+		err = session.sendEmail(&queued.Email)
+		var rejections *recipientsRejectedError
+		if errors.As(err, &rejections) && len(rejections.Accepted) > 0 {
+			fmt.Fprintf(os.Stderr, "⚠️  %q was not sent to every recipient: %v\n", queued.Email.Subject, err)
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to send %q: %v\n", queued.Email.Subject, err)
+			failed++
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return failed, fmt.Errorf("sent %q but could not remove it from the outbox: %w", queued.Email.Subject, err)
+		}
+	}
+	return failed, nil
+}
+
+// queuedEmail is an email waiting in outboxDir until SendAt.
+type queuedEmail struct {
+	SendAt time.Time `json:"send_at"`
+	Email  EmailForm `json:"email"`
+}
+
+// outboxDir is where scheduled emails are kept, under the user's config
+// directory.
+func outboxDir() (string, error) {
+	return configSubdir("outbox")
+}
+
+func validateSendAt(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	if _, err := parseSendAt(value); err != nil {
+		return fmt.Errorf("send time must look like %q", sendAtLayout)
+	}
+	return nil
+}
+
+func parseSendAt(value string) (time.Time, error) {
+	return time.ParseInLocation(sendAtLayout, strings.TrimSpace(value), time.Local)
+}
+
+// queueEmail saves email to the outbox for flush-outbox to send once its
+// SendAt time has passed, and returns the path it was saved to.
+func queueEmail(email *EmailForm) (string, error) {
+	sendAt, err := parseSendAt(email.SendAt)
+	if err != nil {
+		return "", fmt.Errorf("invalid send time: %w", err)
+	}
+
+	dir, err := outboxDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("could not create outbox directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(queuedEmail{SendAt: sendAt, Email: *email}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, time.Now().Format("20060102-150405.000")+".json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", fmt.Errorf("could not queue email: %w", err)
+	}
+	return path, nil
+}
+
+func readQueuedEmail(path string) (queuedEmail, error) {
+	var queued queuedEmail
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return queued, fmt.Errorf("could not read queued email: %w", err)
+	}
+	if err := json.Unmarshal(data, &queued); err != nil {
+		return queued, fmt.Errorf("could not parse queued email %s: %w", path, err)
+	}
+	return queued, nil
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFlushQueuedPartialRejection(t *testing.T) {
+	tests := []struct {
+		name       string
+		to         string
+		individual bool
+		delivered  []string
+	}{
+		{name: "one email", to: "alice@example.com, bob@example.com, carol@example.com", delivered: []string{"alice@example.com", "carol@example.com"}},
+		{name: "individual emails", to: "alice@example.com, bob@example.com", individual: true, delivered: []string{"alice@example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			server, config := newMockSMTP(t, "bob@example.com")
+
+			email := &EmailForm{
+				To:         tt.to,
+				Subject:    "Queued",
+				Body:       "Hello",
+				SendAt:     time.Now().Add(-time.Minute).Format(sendAtLayout),
+				Individual: tt.individual,
+			}
+			path, err := queueEmail(email)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// A second flush must not send the email again
+			for range 2 {
+				paths, err := filepath.Glob(filepath.Join(filepath.Dir(path), "*.json"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				session := newSMTPSession(config)
+				session.out = io.Discard
+				failed, err := flushQueued(session, paths)
+				session.close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if failed != 0 {
+					t.Errorf("failed = %d, want 0 for a partly delivered email", failed)
+				}
+			}
+
+			for _, address := range tt.delivered {
+				if got := server.copiesFor(address); got != 1 {
+					t.Errorf("%s got %d copies, want 1", address, got)
+				}
+			}
+			if got := server.copiesFor("bob@example.com"); got != 0 {
+				t.Errorf("rejected recipient got %d copies", got)
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("queued file still in the outbox: %v", err)
+			}
+		})
+	}
+}
+
+func TestFlushQueuedKeepsFutureAndFailedEmails(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	server, config := newMockSMTP(t, "nobody@example.com")
+
+	tests := []struct {
+		name       string
+		email      EmailForm
+		wantFailed int
+		wantKept   bool
+	}{
+		{
+			name:     "not due yet",
+			email:    EmailForm{To: "alice@example.com", Subject: "Later", Body: "Hi", SendAt: time.Now().Add(time.Hour).Format(sendAtLayout)},
+			wantKept: true,
+		},
+		{
+			name:       "every recipient rejected",
+			email:      EmailForm{To: "nobody@example.com", Subject: "Bounce", Body: "Hi", SendAt: time.Now().Add(-time.Minute).Format(sendAtLayout)},
+			wantFailed: 1,
+			wantKept:   true,
+		},
+		{
+			name:  "due",
+			email: EmailForm{To: "alice@example.com", Subject: "Now", Body: "Hi", SendAt: time.Now().Add(-time.Minute).Format(sendAtLayout)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := queueEmail(&tt.email)
+			if err != nil {
+				t.Fatal(err)
+			}
+			session := newSMTPSession(config)
+			session.out = io.Discard
+			defer session.close()
+			failed, err := flushQueued(session, []string{path})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if failed != tt.wantFailed {
+				t.Errorf("failed = %d, want %d", failed, tt.wantFailed)
+			}
+			_, statErr := os.Stat(path)
+			if kept := statErr == nil; kept != tt.wantKept {
+				t.Errorf("kept = %v, want %v", kept, tt.wantKept)
+			}
+		})
+	}
+	if got := server.copiesFor("alice@example.com"); got != 1 {
+		t.Errorf("alice got %d copies, want 1", got)
+	}
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type pagerClosedMsg struct {
+	err error
+}
+
+// pagerCommand returns $PAGER, or less or more when it is not set.
+func pagerCommand() ([]string, error) {
+	if pager := strings.Fields(os.Getenv("PAGER")); len(pager) > 0 {
+		return pager, nil
+	}
+	for _, name := range []string{"less", "more"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return []string{path}, nil
+		}
+	}
+	return nil, fmt.Errorf("$PAGER is not set and neither less nor more was found")
+}
+
+// viewInPager pipes text into the pager, suspending the TUI until the pager
+// exits.
+func viewInPager(text string) tea.Cmd {
+	pager, err := pagerCommand()
+	if err != nil {
+		return func() tea.Msg { return pagerClosedMsg{err: err} }
+	}
+	cmd := exec.Command(pager[0], pager[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		if err != nil {
+			err = fmt.Errorf("pager %s failed: %w", pager[0], err)
+		}
+		return pagerClosedMsg{err: err}
+	})
+}
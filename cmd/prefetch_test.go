@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPrefetchAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		busy       bool // another command holds the connection
+		dropped    bool // a reconnect dropped the client after the Cmd was built
+		wantBodies int
+	}{
+		{name: "idle connection", wantBodies: 5},
+		{name: "busy connection", busy: true},
+		{name: "dropped client", dropped: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var messages []string
+			for i := range 6 {
+				messages = append(messages, testMessage(fmt.Sprintf("Email %d", i), "text/plain", "Body\r\n"))
+			}
+			c, _ := newMockIMAP(t, messages...)
+			a := newTestApp(t, c, readOptions{prefetch: 5})
+
+			// The newest email is listed first, the rest follow it
+			first, ok := a.list.Items()[0].(Email)
+			if !ok {
+				t.Fatal("the list does not start with an email")
+			}
+			cmd := a.prefetchAfter(first.UID)
+			if cmd == nil {
+				t.Fatal("nothing to prefetch")
+			}
+			if tt.busy {
+				a.imapMu.Lock()
+			}
+			if tt.dropped {
+				a.client = nil
+			}
+			msg := cmd().(emailsPrefetchedMsg)
+			if tt.busy {
+				a.imapMu.Unlock()
+			}
+			if len(msg.uids) != 5 {
+				t.Errorf("prefetched UIDs = %v, want 5", msg.uids)
+			}
+			if len(msg.bodies) != tt.wantBodies {
+				t.Errorf("got %d bodies, want %d", len(msg.bodies), tt.wantBodies)
+			}
+
+			a.Update(msg)
+			if len(a.prefetching) != 0 {
+				t.Errorf("still prefetching %v", a.prefetching)
+			}
+			if len(a.prefetched) != tt.wantBodies {
+				t.Errorf("prefetched = %v, want %d emails", a.prefetched, tt.wantBodies)
+			}
+		})
+	}
+}
+
+// testUIDs returns n messages for newMockIMAP and the UIDs they get there.
+func testUIDs(n int) (messages []string, uids []uint32) {
+	for i := range n {
+		messages = append(messages, testMessage(fmt.Sprintf("Email %d", i), "text/plain", fmt.Sprintf("Body %d\r\n", i)))
+		uids = append(uids, uint32(7+i))
+	}
+	return messages, uids
+}
+
+func TestFetchEmailBodiesBatches(t *testing.T) {
+	tests := []struct {
+		messages    int
+		wantFetches int32
+	}{
+		{messages: 1, wantFetches: 1},
+		{messages: 3, wantFetches: 1},
+		{messages: maxBodyBatch, wantFetches: 1},
+		{messages: maxBodyBatch + 1, wantFetches: 2},
+		{messages: 25, wantFetches: 3},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.messages), func(t *testing.T) {
+			messages, uids := testUIDs(tt.messages)
+			c, fetches := newCountingFetchIMAP(t, messages...)
+			bodies, err := fetchEmailBodies(c, uids, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fetches.Load(); got != tt.wantFetches {
+				t.Errorf("%d FETCH commands, want %d", got, tt.wantFetches)
+			}
+			if len(bodies) != tt.messages {
+				t.Fatalf("%d bodies, want %d", len(bodies), tt.messages)
+			}
+			for i, uid := range uids {
+				if want := fmt.Sprintf("Body %d", i); !strings.Contains(bodies[uid].Body, want) {
+					t.Errorf("UID %d body = %q, want %q", uid, bodies[uid].Body, want)
+				}
+			}
+			// A message the server no longer has is left out
+			if missing, err := fetchEmailBodies(c, []uint32{999}, true); err != nil || len(missing) != 0 {
+				t.Errorf("fetching a missing UID = %v, %v", missing, err)
+			}
+		})
+	}
+}
+
+func TestFetchEmailPartsBatches(t *testing.T) {
+	tests := []struct {
+		messages    int
+		wantFetches int32 // BODYSTRUCTURE, then the parts
+	}{
+		{messages: 3, wantFetches: 2},
+		{messages: maxBodyBatch + 2, wantFetches: 4},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.messages), func(t *testing.T) {
+			messages, uids := testUIDs(tt.messages)
+			c, fetches := newCountingFetchIMAP(t, messages...)
+			bodies, err := fetchEmailParts(c, uids, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fetches.Load(); got != tt.wantFetches {
+				t.Errorf("%d FETCH commands, want %d", got, tt.wantFetches)
+			}
+			if len(bodies) != tt.messages {
+				t.Errorf("%d bodies, want %d", len(bodies), tt.messages)
+			}
+		})
+	}
+}
+
+func TestPrefetchFetchesBodiesTogether(t *testing.T) {
+	messages, _ := testUIDs(6)
+	c, fetches := newCountingFetchIMAP(t, messages...)
+	a := newTestApp(t, c, readOptions{prefetch: 4})
+	first, ok := a.list.Items()[0].(Email)
+	if !ok {
+		t.Fatal("the list does not start with an email")
+	}
+
+	fetches.Store(0)
+	msg := a.prefetchAfter(first.UID)().(emailsPrefetchedMsg)
+	if len(msg.bodies) != 4 {
+		t.Fatalf("%d bodies prefetched, want 4", len(msg.bodies))
+	}
+	// Two per chunk of prefetchChunk bodies rather than two per body
+	if want := int32(2 * (4 / prefetchChunk)); fetches.Load() != want {
+		t.Errorf("%d FETCH commands for 4 bodies, want %d", fetches.Load(), want)
+	}
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/term"
+)
+
+// stageFunc reports the stage a slow operation has reached, such as
+// "connecting to smtp.example.com:587". A nil stageFunc ignores the reports.
+type stageFunc func(stage string)
+
+func (f stageFunc) report(stage string) {
+	if f != nil {
+		f(stage)
+	}
+}
+
+type stageMsg string
+type stagesDoneMsg struct{ err error }
+
+// stagesModel shows a spinner next to the current stage until the work is
+// done, then which stage failed, if any.
+type stagesModel struct {
+	spinner spinner.Model
+	stage   string
+	done    bool
+	err     error
+}
+
+func (m stagesModel) Init() tea.Cmd {
+	return m.spinner.Tick
+}
+
+func (m stagesModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case stageMsg:
+		m.stage = string(msg)
+	case stagesDoneMsg:
+		m.done, m.err = true, msg.err
+		return m, tea.Quit
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+func (m stagesModel) View() string {
+	if m.done {
+		if m.err != nil {
+			return fmt.Sprintf("❌ Failed while %s\n", m.stage)
+		}
+		return ""
+	}
+	return m.spinner.View() + " " + strings.ToUpper(m.stage[:1]) + m.stage[1:] + "…\n"
+}
+
+// withStages runs fn and, unless quiet is set or stderr is not a terminal,
+// shows the stages it reports on stderr while it runs.
+func withStages(quiet bool, fn func(stage stageFunc) error) error {
+	if quiet || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return fn(nil)
+	}
+
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	p := tea.NewProgram(stagesModel{spinner: s, stage: "starting"}, tea.WithInput(nil), tea.WithOutput(os.Stderr))
+	done := make(chan error, 1)
+	go func() {
+		err := fn(func(stage string) { p.Send(stageMsg(stage)) })
+		done <- err
+		p.Send(stagesDoneMsg{err: err})
+	}()
+	if _, err := p.Run(); err != nil && !errors.Is(err, tea.ErrProgramKilled) {
+		return err
+	}
+	return <-done
+}
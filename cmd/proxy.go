@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/urfave/cli/v3"
+	"golang.org/x/net/proxy"
+)
+
+// proxyFlag is part of both the IMAP and the SMTP flags, so that both
+// servers are reached through the same proxy.
+func proxyFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "proxy",
+		Usage:   "connect to the servers through a SOCKS5 proxy, as socks5://[user:password@]host:port",
+		Sources: cli.EnvVars("CLEU_PROXY"),
+		Validator: func(v string) error {
+			_, err := parseProxy(v)
+			return err
+		},
+	}
+}
+
+// parseProxy parses a socks5://[user:password@]host:port URL. It returns nil
+// when raw is empty.
+func parseProxy(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	if u.Scheme != "socks5" && u.Scheme != "socks5h" {
+		return nil, fmt.Errorf("proxy must be a socks5:// URL, got %q", raw)
+	}
+	if _, port, err := net.SplitHostPort(u.Host); err != nil || port == "" {
+		return nil, fmt.Errorf("proxy URL must include a host and a port, got %q", raw)
+	}
+	return u, nil
+}
+
+// dialer opens the connections to the IMAP and SMTP servers. go-imap accepts
+// it as is.
+type dialer interface {
+	Dial(network, addr string) (net.Conn, error)
+}
+
+// newDialer returns a net.Dialer with timeout, or a dialer going through the
+// SOCKS5 proxy at proxyURL when it is set. The proxy credentials come from
+// the URL userinfo.
+func newDialer(proxyURL *url.URL, timeout time.Duration) (dialer, error) {
+	direct := &net.Dialer{Timeout: timeout}
+	if proxyURL == nil {
+		return direct, nil
+	}
+
+	var auth *proxy.Auth
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+	}
+	d, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, direct)
+	if err != nil {
+		return nil, fmt.Errorf("could not set up the proxy: %w", err)
+	}
+	return socksDialer{dialer: d.(proxy.ContextDialer), timeout: timeout}, nil
+}
+
+// socksDialer bounds the whole proxy handshake by timeout, not only the
+// connection to the proxy. The connections it returns have no deadline;
+// callers bound the TLS handshake and the greeting themselves.
+type socksDialer struct {
+	dialer  proxy.ContextDialer
+	timeout time.Duration
+}
+
+func (d socksDialer) Dial(network, addr string) (net.Conn, error) {
+	ctx := context.Background()
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+	conn, err := d.dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	// Reads and writes past the handshake are not bound by the dial timeout
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
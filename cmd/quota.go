@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-imap/responses"
+	"github.com/emersion/go-imap/utf7"
+)
+
+// quotaUsage is the storage usage reported by the QUOTA extension, in bytes.
+type quotaUsage struct {
+	used  uint64
+	limit uint64
+}
+
+func (q quotaUsage) String() string {
+	return fmt.Sprintf("%s of %s used", formatBytes(q.used), formatBytes(q.limit))
+}
+
+// fetchQuota asks for the storage quota of mailbox. It reports false, without
+// an error, when the server doesn't advertise QUOTA or sets no storage limit.
+func fetchQuota(imapClient *client.Client, mailbox string) (quotaUsage, bool, error) {
+	supported, err := imapClient.Support("QUOTA")
+	if err != nil || !supported {
+		return quotaUsage{}, false, err
+	}
+
+	// Raw commands skip the modified UTF-7 encoding go-imap does for Select
+	encoded, err := utf7.Encoding.NewEncoder().String(mailbox)
+	if err != nil {
+		return quotaUsage{}, false, err
+	}
+	var usage quotaUsage
+	found := false
+	cmd := &imap.Command{
+		Name:      "GETQUOTAROOT",
+		Arguments: []interface{}{imap.FormatMailboxName(encoded)},
+	}
+	status, err := imapClient.Execute(cmd, responses.HandlerFunc(func(resp imap.Resp) error {
+		name, fields, ok := imap.ParseNamedResp(resp)
+		if !ok || name != "QUOTA" {
+			return responses.ErrUnhandled
+		}
+		if u, ok := parseQuotaResponse(fields); ok && !found {
+			usage = u
+			found = true
+		}
+		return nil
+	}))
+	if err != nil {
+		return quotaUsage{}, false, err
+	}
+	if err := status.Err(); err != nil {
+		return quotaUsage{}, false, err
+	}
+	return usage, found, nil
+}
+
+// parseQuotaResponse reads the STORAGE resource from the fields of a
+// "* QUOTA <root> (STORAGE <used> <limit> ...)" response. STORAGE is counted
+// in units of 1024 octets.
+func parseQuotaResponse(fields []interface{}) (quotaUsage, bool) {
+	if len(fields) < 2 {
+		return quotaUsage{}, false
+	}
+	resources, ok := fields[1].([]interface{})
+	if !ok {
+		return quotaUsage{}, false
+	}
+	for i := 0; i+2 < len(resources); i += 3 {
+		name, err := imap.ParseString(resources[i])
+		if err != nil || !strings.EqualFold(name, "STORAGE") {
+			continue
+		}
+		used, err := parseQuotaNumber(resources[i+1])
+		if err != nil {
+			return quotaUsage{}, false
+		}
+		limit, err := parseQuotaNumber(resources[i+2])
+		if err != nil || limit == 0 {
+			return quotaUsage{}, false
+		}
+		return quotaUsage{used: used * 1024, limit: limit * 1024}, true
+	}
+	return quotaUsage{}, false
+}
+
+// parseQuotaNumber parses a quota value, which may not fit in the uint32 that
+// imap.ParseNumber allows.
+func parseQuotaNumber(f interface{}) (uint64, error) {
+	s, err := imap.ParseString(f)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// formatBytes renders n octets in binary units, such as "512 B", "1.5 KB"
+// or "24 MB".
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value := float64(n)
+	suffixes := []string{"KB", "MB", "GB", "TB", "PB"}
+	i := -1
+	for value >= unit && i < len(suffixes)-1 {
+		value /= unit
+		i++
+	}
+	if value >= 10 {
+		return fmt.Sprintf("%.0f %s", value, suffixes[i])
+	}
+	return fmt.Sprintf("%.1f %s", value, suffixes[i])
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"regexp"
+	"strings"
+)
+
+// quoteAttribution matches the line mail clients put above a quoted reply,
+// such as "On Mon, Jan 2, 2006 at 3:04 PM Alice <alice@example.com> wrote:".
+// German clients put the sender after the verb: "Am ... schrieb Alice:".
+var quoteAttribution = regexp.MustCompile(`(?i)^\s*(on\s.+\swrote|le\s.+\sa écrit|am\s.+\sschrieb(\s.+)?)\s?:\s*$`)
+
+// quoteSeparator matches the lines Outlook puts above the original message,
+// which it quotes without any ">".
+var quoteSeparator = regexp.MustCompile(`(?i)^\s*(-{2,}\s*original message\s*-{2,}|_{20,})\s*$`)
+
+// splitQuoted splits body into the new text and the quoted reply chain
+// below it: the trailing block of ">" lines with the attribution line above
+// it, or everything from an Outlook separator on. Quotes with replies
+// interleaved are left alone, and quoted is empty when nothing would remain
+// visible.
+func splitQuoted(body string) (visible, quoted string) {
+	lines := strings.Split(body, "\n")
+
+	start := len(lines)
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(line, ">") {
+			start = i
+		} else if line != "" {
+			break
+		}
+	}
+	if start < len(lines) {
+		i := start - 1
+		for i >= 0 && strings.TrimSpace(lines[i]) == "" {
+			i--
+		}
+		if i >= 0 && quoteAttribution.MatchString(lines[i]) {
+			start = i
+		}
+	}
+	for i, line := range lines[:start] {
+		if quoteSeparator.MatchString(line) {
+			start = i
+			break
+		}
+	}
+
+	visible = strings.TrimRight(strings.Join(lines[:start], "\n"), " \t\n")
+	if start == len(lines) || strings.TrimSpace(visible) == "" {
+		return body, ""
+	}
+	return visible, strings.Join(lines[start:], "\n")
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestSplitQuoted(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantVisible string
+		wantQuoted  string
+	}{
+		{
+			name:        "gmail",
+			body:        "Sounds good.\n\nOn Mon, Jan 2, 2006 at 3:04 PM Alice <alice@example.com> wrote:\n> Lunch tomorrow?\n> Alice\n",
+			wantVisible: "Sounds good.",
+			wantQuoted:  "On Mon, Jan 2, 2006 at 3:04 PM Alice <alice@example.com> wrote:\n> Lunch tomorrow?\n> Alice\n",
+		},
+		{
+			name:        "attribution with a blank line",
+			body:        "Yes\nOn Tue, Bob wrote:\n\n> > nested\n> quote",
+			wantVisible: "Yes",
+			wantQuoted:  "On Tue, Bob wrote:\n\n> > nested\n> quote",
+		},
+		{
+			name:        "french",
+			body:        "D'accord\n\nLe mar. 3 janv. 2006, Alice a écrit :\n> Déjeuner ?",
+			wantVisible: "D'accord",
+			wantQuoted:  "Le mar. 3 janv. 2006, Alice a écrit :\n> Déjeuner ?",
+		},
+		{
+			name:        "german",
+			body:        "Gern\nAm Mo., 2. Jan. 2006 um 15:04 Uhr schrieb Alice <alice@example.com>:\n> Mittag?",
+			wantVisible: "Gern",
+			wantQuoted:  "Am Mo., 2. Jan. 2006 um 15:04 Uhr schrieb Alice <alice@example.com>:\n> Mittag?",
+		},
+		{
+			name:        "german without a name",
+			body:        "Gern\nAm 3. Jan. 2006 schrieb:\n> Mittag?",
+			wantVisible: "Gern",
+			wantQuoted:  "Am 3. Jan. 2006 schrieb:\n> Mittag?",
+		},
+		{
+			name:        "no attribution",
+			body:        "Agreed\n> earlier text",
+			wantVisible: "Agreed",
+			wantQuoted:  "> earlier text",
+		},
+		{
+			name:        "outlook",
+			body:        "See below\n\n-----Original Message-----\nFrom: Alice\nSubject: Report\n\nThe report",
+			wantVisible: "See below",
+			wantQuoted:  "-----Original Message-----\nFrom: Alice\nSubject: Report\n\nThe report",
+		},
+		{
+			name:        "outlook underscores",
+			body:        "Thanks\n________________________________\nFrom: Alice",
+			wantVisible: "Thanks",
+			wantQuoted:  "________________________________\nFrom: Alice",
+		},
+		{name: "interleaved replies", body: "> question one\nanswer one\n> question two\nanswer two", wantVisible: "> question one\nanswer one\n> question two\nanswer two"},
+		{name: "only a quote", body: "On Mon, Alice wrote:\n> Forwarded", wantVisible: "On Mon, Alice wrote:\n> Forwarded"},
+		{name: "no quote", body: "Just text\n", wantVisible: "Just text\n"},
+		{name: "angle bracket inside a line", body: "Use a -> b\nthen c", wantVisible: "Use a -> b\nthen c"},
+	}
+	for _, tt := range tests {
+		visible, quoted := splitQuoted(tt.body)
+		if visible != tt.wantVisible || quoted != tt.wantQuoted {
+			t.Errorf("%s: splitQuoted = %q, %q; want %q, %q", tt.name, visible, quoted, tt.wantVisible, tt.wantQuoted)
+		}
+	}
+}
+
+func TestQuotedTextCollapses(t *testing.T) {
+	email := Email{
+		Subject: "Re: Lunch",
+		From:    "Bob",
+		Body:    "Sounds good.\n\nOn Mon, Alice wrote:\n> Lunch tomorrow at noon?",
+	}
+	tests := []struct {
+		name       string
+		showQuoted bool
+		want       []string
+		unwanted   []string
+	}{
+		{name: "collapsed", want: []string{"Sounds good.", "[+] show quoted text (z)"}, unwanted: []string{"Lunch tomorrow", "Alice wrote"}},
+		{name: "expanded", showQuoted: true, want: []string{"Sounds good.", "Alice wrote:", "Lunch tomorrow at noon?", "[-] hide quoted text (z)"}},
+	}
+	for _, tt := range tests {
+		for _, markdown := range []bool{false, true} {
+			view := ansi.Strip(formatEmailForView(email, 80, emailViewOptions{markdown: markdown, showQuoted: tt.showQuoted}))
+			for _, want := range tt.want {
+				if !strings.Contains(view, want) {
+					t.Errorf("%s, markdown %v: view does not contain %q:\n%s", tt.name, markdown, want, view)
+				}
+			}
+			for _, unwanted := range tt.unwanted {
+				if strings.Contains(view, unwanted) {
+					t.Errorf("%s, markdown %v: view contains %q:\n%s", tt.name, markdown, unwanted, view)
+				}
+			}
+		}
+	}
+	if view := formatEmailForView(Email{Body: "No quotes here"}, 80, emailViewOptions{}); strings.Contains(view, "quoted text") {
+		t.Errorf("marker shown without a quote:\n%s", view)
+	}
+}
+
+func TestZTogglesQuotedText(t *testing.T) {
+	c, _ := newMockIMAP(t, testMessage("Re: Lunch", "text/plain", "Sounds good.\r\n\r\nOn Mon, Alice wrote:\r\n> Lunch?"))
+	a := newTestApp(t, c, readOptions{})
+	a.list.Select(0)
+	if email, ok := a.list.SelectedItem().(Email); !ok || email.Subject != "Re: Lunch" {
+		t.Fatalf("selected %v", a.list.SelectedItem())
+	}
+	pressKey(t, a, "enter")
+	if a.state != emailView || a.showQuoted {
+		t.Fatalf("state %v, showQuoted %v after opening", a.state, a.showQuoted)
+	}
+	for _, want := range []bool{true, false} {
+		pressKey(t, a, "z")
+		if a.showQuoted != want {
+			t.Errorf("showQuoted = %v, want %v", a.showQuoted, want)
+		}
+	}
+	pressKey(t, a, "z")
+	pressKey(t, a, "esc")
+	pressKey(t, a, "enter")
+	if a.showQuoted {
+		t.Error("opening an email again does not collapse its quote")
+	}
+}
@@ -1,24 +1,37 @@
 package cmd
 
 import (
+	"cmp"
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"maps"
 	"mime"
 	"mime/multipart"
+	"net"
 	"net/mail"
+	"net/url"
 	"os"
+	"reflect"
 	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 	"github.com/emersion/go-imap"
 	"github.com/emersion/go-imap/client"
 	"github.com/urfave/cli/v3"
@@ -26,41 +39,390 @@ import (
 
 var Read = &cli.Command{
 	Name: "read",
+	Flags: append(imapAndSMTPFlags(),
+		&cli.IntFlag{
+			Name:    "per-page",
+			Usage:   "number of emails to fetch per page",
+			Value:   50,
+			Sources: cli.EnvVars("CLEU_PER_PAGE"),
+			Validator: func(v int) error {
+				if v < 1 {
+					return fmt.Errorf("per-page must be a positive number, got %d", v)
+				}
+				return nil
+			},
+		},
+		&cli.StringFlag{
+			Name:    "export-dir",
+			Usage:   "directory where exported .eml files are saved",
+			Value:   ".",
+			Sources: cli.EnvVars("CLEU_EXPORT_DIR"),
+		},
+		&cli.BoolFlag{
+			Name:    "hide-help",
+			Usage:   "hide the inline help line (press ? for shortcuts)",
+			Sources: cli.EnvVars("CLEU_HIDE_HELP"),
+		},
+		&cli.BoolFlag{
+			Name:    "confirm-delete",
+			Usage:   "ask for confirmation before deleting an email",
+			Value:   true,
+			Sources: cli.EnvVars("CLEU_CONFIRM_DELETE"),
+		},
+		&cli.BoolFlag{
+			Name:  "no-confirm",
+			Usage: "delete emails without asking for confirmation",
+		},
+		&cli.StringFlag{
+			Name:    "trash-folder",
+			Usage:   "name of the trash folder, instead of detecting it",
+			Sources: cli.EnvVars("CLEU_TRASH_FOLDER"),
+		},
+		&cli.IntFlag{
+			Name:    "wrap-width",
+			Usage:   "wrap emails at this column instead of the window width (0 follows the window)",
+			Sources: cli.EnvVars("CLEU_WRAP_WIDTH"),
+			Validator: func(v int) error {
+				if v < 0 {
+					return fmt.Errorf("wrap-width must not be negative, got %d", v)
+				}
+				return nil
+			},
+		},
+		themeFlag(),
+		inlineImagesFlag(),
+		&cli.BoolFlag{
+			Name:    "vim-keys",
+			Usage:   "move with j/k, gg/G and ctrl+d/ctrl+u",
+			Value:   true,
+			Sources: cli.EnvVars("CLEU_VIM_KEYS"),
+		},
+		&cli.BoolFlag{
+			Name:    "mouse",
+			Usage:   "click to select and open emails and scroll with the wheel",
+			Value:   true,
+			Sources: cli.EnvVars("CLEU_MOUSE"),
+		},
+		&cli.BoolFlag{
+			Name:    "plain-text",
+			Usage:   "show email bodies as plain text instead of rendering them as markdown (toggle with m)",
+			Sources: cli.EnvVars("CLEU_PLAIN_TEXT"),
+		},
+		&cli.StringFlag{
+			Name:    "from-format",
+			Usage:   `how senders are shown in the list: "name", "email" or "name <email>"`,
+			Value:   fromFormatName,
+			Sources: cli.EnvVars("CLEU_FROM_FORMAT"),
+			Validator: func(v string) error {
+				switch v {
+				case fromFormatName, fromFormatEmail, fromFormatBoth:
+					return nil
+				}
+				return fmt.Errorf(`from-format must be "name", "email" or "name <email>", got %q`, v)
+			},
+		},
+		&cli.BoolFlag{
+			Name:    "sender-colors",
+			Usage:   "give each sender in the list its own color",
+			Value:   true,
+			Sources: cli.EnvVars("CLEU_SENDER_COLORS"),
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "show what emails composed in the reader would send instead of sending them",
+		},
+		&cli.BoolFlag{
+			Name:  "allow-placeholders",
+			Usage: "send emails composed in the reader even when they still contain a {{ ... }} placeholder",
+		},
+		&cli.StringFlag{
+			Name:    "timezone",
+			Usage:   `show dates in this zone, such as "UTC" or "Europe/Paris", instead of the local one`,
+			Sources: cli.EnvVars("CLEU_TIMEZONE"),
+			Validator: func(v string) error {
+				_, err := loadLocation(v)
+				return err
+			},
+		},
+		&cli.BoolFlag{
+			Name:    "absolute-dates",
+			Usage:   `show full dates in the list instead of "2h ago" for recent emails`,
+			Sources: cli.EnvVars("CLEU_ABSOLUTE_DATES"),
+		},
+		&cli.BoolFlag{
+			Name:    "no-cache",
+			Usage:   "do not show cached envelopes on startup or update the cache",
+			Sources: cli.EnvVars("CLEU_NO_CACHE"),
+		},
+		&cli.IntFlag{
+			Name:    "cache-size",
+			Usage:   "maximum number of envelopes kept in the startup cache",
+			Value:   200,
+			Sources: cli.EnvVars("CLEU_CACHE_SIZE"),
+			Validator: func(v int) error {
+				if v < 1 {
+					return fmt.Errorf("cache-size must be a positive number, got %d", v)
+				}
+				return nil
+			},
+		},
+		&cli.BoolFlag{
+			Name:    "count-unread",
+			Usage:   "ask the server how many emails are unread in the whole inbox, not just the loaded ones",
+			Sources: cli.EnvVars("CLEU_COUNT_UNREAD"),
+		},
+		&cli.BoolFlag{
+			Name:    "read-only",
+			Usage:   "open the mailbox read-only and disable delete, mark all read and empty trash",
+			Sources: cli.EnvVars("CLEU_READ_ONLY"),
+		},
+		&cli.IntFlag{
+			Name:    "prefetch",
+			Usage:   "number of following emails whose body is fetched in the background (0 disables)",
+			Value:   1,
+			Sources: cli.EnvVars("CLEU_PREFETCH"),
+			Validator: func(v int) error {
+				if v < 0 {
+					return fmt.Errorf("prefetch must not be negative, got %d", v)
+				}
+				return nil
+			},
+		},
+	),
 	Action: func(ctx context.Context, c *cli.Command) error {
-		username := os.Getenv("IMAP_USERNAME")
-		password := os.Getenv("IMAP_PASSWORD")
-		host := os.Getenv("IMAP_HOST")
-		port := os.Getenv("IMAP_PORT")
-		if username == "" || password == "" || host == "" || port == "" {
-			return fmt.Errorf("please set IMAP_USERNAME, IMAP_PASSWORD, IMAP_HOST, and IMAP_PORT environment variables")
-		}
-		app := NewApp(username, password, host, port)
-		p := tea.NewProgram(app, tea.WithAltScreen())
-		_, err := p.Run()
+		config, err := loadIMAPConfig(c)
+		if err != nil {
+			return err
+		}
+		if err := applyTheme(c.String("theme")); err != nil {
+			return err
+		}
+		options := readOptions{
+			perPage:           c.Int("per-page"),
+			exportDir:         c.String("export-dir"),
+			hideHelp:          c.Bool("hide-help"),
+			prefetch:          c.Int("prefetch"),
+			trashFolder:       mailboxName(c.String("trash-folder")),
+			absoluteDates:     c.Bool("absolute-dates"),
+			fromFormat:        c.String("from-format"),
+			senderColors:      c.Bool("sender-colors"),
+			wrapWidth:         c.Int("wrap-width"),
+			vimKeys:           c.Bool("vim-keys"),
+			confirmDelete:     c.Bool("confirm-delete") && !c.Bool("no-confirm"),
+			readOnly:          c.Bool("read-only"),
+			countUnread:       c.Bool("count-unread"),
+			plainText:         c.Bool("plain-text"),
+			allowPlaceholders: c.Bool("allow-placeholders"),
+			imageProtocol:     imageProtocol(c.String("inline-images")),
+		}
+		// Reading works without SMTP settings, composing reports what is missing
+		options.smtp, options.smtpErr = loadSMTPConfig(c)
+		options.smtp.quiet = true
+		options.smtp.dryRun = c.Bool("dry-run")
+		if options.location, err = loadLocation(c.String("timezone")); err != nil {
+			return err
+		}
+		if c.Bool("no-cache") {
+			options.cacheSize = 0
+		} else {
+			options.cacheSize = c.Int("cache-size")
+		}
+		app := NewApp(config, options)
+		if err := logAwayFromTerminal(); err != nil {
+			return err
+		}
+		programOptions := []tea.ProgramOption{tea.WithAltScreen()}
+		if c.Bool("mouse") {
+			programOptions = append(programOptions, tea.WithMouseCellMotion())
+		}
+		p := tea.NewProgram(app, programOptions...)
+		_, err = p.Run()
 		return err
 	},
 }
 
+// imapFlags are the connection flags shared by every command that talks to
+// the IMAP server.
+func imapFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.DurationFlag{
+			Name:    "dial-timeout",
+			Usage:   "maximum time to wait when connecting to the IMAP server",
+			Value:   10 * time.Second,
+			Sources: cli.EnvVars("CLEU_DIAL_TIMEOUT"),
+		},
+		&cli.DurationFlag{
+			Name:    "timeout",
+			Usage:   "maximum time to wait for an IMAP command (0 disables)",
+			Value:   60 * time.Second,
+			Sources: cli.EnvVars("CLEU_TIMEOUT"),
+		},
+		&cli.StringFlag{
+			Name:    "inbox",
+			Usage:   `mailbox used as the inbox, for example "[Gmail]/All Mail"`,
+			Value:   "INBOX",
+			Sources: cli.EnvVars("CLEU_INBOX"),
+		},
+		proxyFlag(),
+	}
+}
+
+// imapAndSMTPFlags are the IMAP and SMTP flags together, for commands that
+// talk to both servers. --proxy is the same flag on both sides. The SMTP
+// dial timeout becomes --smtp-dial-timeout, so that CLEU_SMTP_DIAL_TIMEOUT
+// still applies; without it the SMTP server gets --dial-timeout too.
+func imapAndSMTPFlags() []cli.Flag {
+	flags := imapFlags()
+	names := make(map[string]bool)
+	for _, flag := range flags {
+		names[flag.Names()[0]] = true
+	}
+	for _, flag := range smtpFlags() {
+		if !names[flag.Names()[0]] {
+			flags = append(flags, flag)
+		}
+	}
+	return append(flags, &cli.DurationFlag{
+		Name:    "smtp-dial-timeout",
+		Usage:   "maximum time to wait when connecting to the SMTP server, instead of --dial-timeout (0 disables)",
+		Sources: cli.EnvVars("CLEU_SMTP_DIAL_TIMEOUT"),
+	})
+}
+
+// loadIMAPConfig reads the IMAP settings from the environment and the flags
+// added by imapFlags.
+func loadIMAPConfig(c *cli.Command) (imapConfig, error) {
+	config := imapConfig{
+		host:           os.Getenv("IMAP_HOST"),
+		port:           os.Getenv("IMAP_PORT"),
+		dialTimeout:    c.Duration("dial-timeout"),
+		commandTimeout: c.Duration("timeout"),
+		inbox:          mailboxName(strings.TrimSpace(c.String("inbox"))),
+	}
+	if config.inbox == "" {
+		config.inbox = "INBOX"
+	}
+	var err error
+	if config.username, err = envOrCommand("IMAP_USERNAME"); err != nil {
+		return config, configError{err}
+	}
+	if config.password, err = envOrCommand("IMAP_PASSWORD"); err != nil {
+		return config, configError{err}
+	}
+	if config.username == "" || config.password == "" || strings.TrimSpace(config.host) == "" {
+		return config, configError{fmt.Errorf("please set IMAP_USERNAME, IMAP_PASSWORD and IMAP_HOST environment variables (or IMAP_USERNAME_CMD and IMAP_PASSWORD_CMD to read them from a command)")}
+	}
+	if config.host, err = normalizeHost("IMAP_HOST", config.host); err != nil {
+		return config, configError{err}
+	}
+	if config.proxy, err = parseProxy(c.String("proxy")); err != nil {
+		return config, configError{err}
+	}
+	// IMAP is always dialed over TLS
+	if config.port, err = normalizePort("IMAP_PORT", config.port, defaultIMAPPort); err != nil {
+		return config, configError{err}
+	}
+	insecure, err := insecureFromEnv()
+	if err != nil {
+		return config, configError{err}
+	}
+	config.insecure = insecure
+	return config, nil
+}
+
+// mailboxOrInbox returns the mailbox given with --mailbox, or the configured
+// inbox when it is not set.
+func mailboxOrInbox(c *cli.Command, config imapConfig) string {
+	if mailbox := c.String("mailbox"); mailbox != "" {
+		return mailboxName(mailbox)
+	}
+	return config.inbox
+}
+
+// insecureFromEnv reports whether CLEU_INSECURE asks to skip TLS certificate
+// verification, warning on stderr when it does.
+func insecureFromEnv() (bool, error) {
+	value := os.Getenv("CLEU_INSECURE")
+	if value == "" {
+		return false, nil
+	}
+	insecure, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("CLEU_INSECURE must be true or false, got %q", value)
+	}
+	if insecure {
+		fmt.Fprintln(os.Stderr, "⚠️  WARNING: CLEU_INSECURE is set, TLS certificates are NOT verified. Only use this against test servers.")
+	}
+	return insecure, nil
+}
+
 type Email struct {
 	UID         uint32
 	Subject     string
-	From        string
+	From        string // display name, falling back to the address
+	FromName    string // decoded display name, empty when there is none
+	FromAddress string
 	To          string
 	Date        time.Time
 	Body        string
 	HTMLBody    string
 	TextBody    string
 	ContentType string
+	Raw         string // full source, fetched for cat, R and e
+	RawHeader   string // header as received
 	Seen        bool
+	Flagged     bool
+	Answered    bool
+	Draft       bool
+	Size        uint32
+	MessageID   string
+	InReplyTo   string
+	References  []string
+	Attachments []attachment
+
+	// Set on the copies shown in the list.
+	threadDepth    int
+	threadReplies  int
+	threadExpanded bool
+	marked         bool
+	absoluteDate   bool
+	fromFormat     string
+	location       *time.Location
+	senderColors   bool
+	descStyle      lipgloss.Style // set by emailDelegate
+	titleWidth     int
 }
 
 func (e Email) FilterValue() string { return e.Subject }
 
 func (e Email) Title() string {
-	if len(e.Subject) > 60 {
-		return e.Subject[:57] + "..."
+	prefix := ""
+	if e.threadDepth > 0 {
+		prefix = "  ↳ "
+	} else if e.threadReplies > 0 {
+		marker := "▸"
+		if e.threadExpanded {
+			marker = "▾"
+		}
+		prefix = fmt.Sprintf("%s (%d) ", marker, e.threadReplies+1)
+	}
+	if e.marked {
+		prefix = "✓ " + prefix
+	}
+	width := e.titleWidth
+	if width <= 0 {
+		width = 60
+	}
+	return truncate(prefix+e.Subject, width)
+}
+
+// truncate shortens s to at most width terminal cells without splitting a
+// character, ending with "..." only when something was cut.
+func truncate(s string, width int) string {
+	if ansi.StringWidth(s) <= width {
+		return s
 	}
-	return e.Subject
+	return ansi.Truncate(s, width, "...")
 }
 
 func (e Email) Description() string {
@@ -68,7 +430,93 @@ func (e Email) Description() string {
 	if e.Seen {
 		status = "⚪"
 	}
-	return fmt.Sprintf("%s %s - %s", status, e.From, e.Date.Format("Jan 2, 15:04"))
+	if e.Answered {
+		status += " ↩️"
+	}
+	date := displayTime(e.Date, e.location).Format("Jan 2, 15:04")
+	if !e.absoluteDate {
+		date = relativeDate(displayTime(e.Date, e.location), displayTime(time.Now(), e.location))
+	}
+	sender, rest := e.sender(), " - "+date
+	if e.senderColors {
+		sender = senderStyle(cmp.Or(e.FromAddress, e.From)).Render(sender)
+		rest = e.descStyle.Render(rest)
+	}
+	return fmt.Sprintf("%s %s%s", status, sender, rest)
+}
+
+// The --from-format values.
+const (
+	fromFormatName  = "name"
+	fromFormatEmail = "email"
+	fromFormatBoth  = "name <email>"
+)
+
+// sender formats the sender for the list according to fromFormat, falling
+// back to whatever is known when the name or the address is missing.
+func (e Email) sender() string {
+	switch e.fromFormat {
+	case fromFormatEmail:
+		if e.FromAddress != "" {
+			return e.FromAddress
+		}
+	case fromFormatBoth:
+		if e.FromName != "" && e.FromAddress != "" {
+			return fmt.Sprintf("%s <%s>", e.FromName, e.FromAddress)
+		}
+	}
+	return e.From
+}
+
+// loadLocation returns the zone named by --timezone, or nil for the local
+// zone when name is empty.
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return nil, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q: %w", name, err)
+	}
+	return loc, nil
+}
+
+// displayTime converts t to loc, or to the local zone when loc is nil, so
+// that dates are shown in one zone whatever zone the sender used.
+func displayTime(t time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		return t.Local()
+	}
+	return t.In(loc)
+}
+
+// relativeDate formats t relative to now for the past week ("5m ago",
+// "Yesterday", "3 days ago") and as an absolute date otherwise.
+func relativeDate(t, now time.Time) string {
+	age := now.Sub(t)
+	if age < 0 || age >= 7*24*time.Hour {
+		if t.Year() != now.Year() {
+			return t.Format("Jan 2, 2006")
+		}
+		return t.Format("Jan 2, 15:04")
+	}
+
+	t = t.In(now.Location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, now.Location())
+	days := int(today.Sub(day).Hours()/24 + 0.5)
+	switch {
+	case age < time.Minute:
+		return "Just now"
+	case age < time.Hour:
+		return fmt.Sprintf("%dm ago", int(age.Minutes()))
+	case days == 0:
+		return fmt.Sprintf("%dh ago", int(age.Hours()))
+	case days == 1:
+		return "Yesterday"
+	default:
+		return fmt.Sprintf("%d days ago", days)
+	}
 }
 
 type LoadMoreItem struct{}
@@ -77,30 +525,115 @@ func (l LoadMoreItem) FilterValue() string { return "load more emails" }
 func (l LoadMoreItem) Title() string       { return "📥 Load More Emails..." }
 func (l LoadMoreItem) Description() string { return "Press Enter to load older emails" }
 
+// EndOfMailboxItem closes the list once every email is loaded. The cursor
+// never rests on it, see App.skipEndOfMailbox.
+type EndOfMailboxItem struct{}
+
+func (e EndOfMailboxItem) FilterValue() string { return "" }
+func (e EndOfMailboxItem) Title() string       { return "— End of mailbox —" }
+func (e EndOfMailboxItem) Description() string { return "Every email is loaded" }
+
+// imapConfig holds the settings needed to open an IMAP session.
+type imapConfig struct {
+	username       string
+	password       string
+	host           string
+	port           string
+	dialTimeout    time.Duration
+	commandTimeout time.Duration
+	insecure       bool     // skip TLS certificate verification
+	inbox          string   // mailbox opened by cleu read, INBOX by default
+	proxy          *url.URL // SOCKS5 proxy to connect through, if any
+}
+
+// readOptions holds user preferences for the read TUI.
+type readOptions struct {
+	perPage           int
+	exportDir         string
+	hideHelp          bool
+	prefetch          int
+	trashFolder       string
+	cacheSize         int // 0 disables the envelope cache
+	absoluteDates     bool
+	fromFormat        string
+	senderColors      bool
+	location          *time.Location // nil shows dates in the local zone
+	wrapWidth         int            // 0 wraps at the viewport width
+	vimKeys           bool
+	confirmDelete     bool
+	readOnly          bool   // EXAMINE the mailbox and refuse destructive actions
+	countUnread       bool   // SEARCH UNSEEN for the mailbox-wide unread count
+	plainText         bool   // start with markdown rendering off
+	allowPlaceholders bool   // send composed emails even with {{ ... }} left in
+	imageProtocol     string // how I draws inline images, "" when it cannot
+	smtp              smtpConfig
+	smtpErr           error // why smtp cannot be used to compose, if it cannot
+}
+
 type App struct {
-	username             string
-	password             string
-	host                 string
-	port                 string
-	client               *client.Client
-	emails               []Email
-	list                 list.Model
-	viewport             viewport.Model
-	ready                bool
-	loading              bool
-	loadingMore          bool
-	err                  error
-	state                appState
-	totalMessages        uint32
-	emailsPerPage        int
-	currentPage          int
-	hasMore              bool
-	showDeleteConfirm    bool
-	emailToDelete        *Email
-	deleteConfirmIndex   int
-	deletingEmail        bool
-	deleteSuccess        bool
-	deleteSuccessMessage string
+	config            imapConfig
+	options           readOptions
+	client            *client.Client
+	emails            []Email
+	list              list.Model
+	viewport          viewport.Model
+	ready             bool
+	loading           bool
+	loadingMore       bool
+	syncing           bool   // the list shows cached envelopes until the server answers
+	uidValidity       uint32 // UIDVALIDITY the listed UIDs belong to
+	err               error
+	state             appState
+	totalMessages     uint32
+	emailsPerPage     int
+	currentPage       int
+	oldestUID         uint32
+	hasMore           bool
+	showDeleteConfirm bool
+	deleteUIDs        []uint32 // the emails d moves to the trash
+	confirmIndex      int
+	deletingEmail     bool
+	emptyingTrash     bool
+	markingAllRead    bool
+	showSuccess       bool
+	successMessage    string
+	reconnecting      bool
+	jumpInput         textinput.Model
+	jumping           bool
+	pendingJump       int
+	pendingJumpPage   bool
+	sortMode          sortMode
+	openUID           uint32
+	unreadOnly        bool
+	showHeaders       bool // the open email shows its raw headers
+	plainText         bool // bodies skip the markdown pass, toggled with m
+	showQuoted        bool // the open email shows its quoted reply chain
+	quota             *quotaUsage
+	unread            *int // mailbox-wide unread count, when countUnread is set
+	showHelp          bool
+	width             int
+	height            int
+	spinner           spinner.Model
+	loadingBody       bool
+	progressBar       progress.Model
+	progressCh        chan fetchProgressMsg
+	fetchProgress     fetchProgressMsg
+	imapMu            sync.Mutex
+	prefetching       map[uint32]bool
+	prefetched        map[uint32]bool
+	threaded          bool
+	expandedThreads   map[uint32]bool
+	marked            map[uint32]bool // emails selected with space, * and i
+	pendingG          bool
+	lastClick         time.Time
+	lastClickIndex    int
+	compose           composeModel
+	composeReturn     appState // where closing the compose view goes back to
+	composePristine   EmailForm
+	folderPicker      list.Model
+	moveUIDs          []uint32 // the emails the folder picker moves
+	moveReturn        appState
+	moving            bool
 }
 
 type appState int
@@ -109,229 +642,1008 @@ const (
 	listView appState = iota
 	emailView
 	deleteConfirmView
+	emptyTrashConfirmView
+	markAllReadConfirmView
+	composeView
+	folderPickerView
+)
+
+type sortMode int
+
+const (
+	sortDateDesc sortMode = iota
+	sortDateAsc
+	sortSender
+	sortSubject
+	sortModeCount
 )
 
+func (m sortMode) String() string {
+	switch m {
+	case sortDateAsc:
+		return "oldest first ↑"
+	case sortSender:
+		return "sender A–Z"
+	case sortSubject:
+		return "subject A–Z"
+	default:
+		return "newest first ↓"
+	}
+}
+
 type emailsLoadedMsg struct {
 	emails        []Email
 	totalMessages uint32
 	isLoadMore    bool
+	quota         *quotaUsage
+	unread        *int
+	fromCache     bool
+	uidValidity   uint32 // of the mailbox the emails were listed from
 }
 type errorMsg error
 type emailBodyLoadedMsg struct {
 	uid  uint32
 	body Email
 }
+type emailsPrefetchedMsg struct {
+	uids   []uint32
+	bodies map[uint32]Email // missing the bodies that could not be fetched
+}
 type emailDeletedMsg struct {
-	uid     uint32
+	uids    []uint32
 	success bool
 	message string
 }
+type allMarkedReadMsg struct {
+	count int
+}
+type trashEmptiedMsg struct {
+	folder string
+	count  uint32
+}
+type connectionLostMsg struct {
+	err     error
+	attempt int
+	retry   func() tea.Msg
+}
+type reconnectedMsg struct {
+	retry func() tea.Msg
+}
+
+const maxReconnectAttempts = 5
 
-func NewApp(username, password, host, port string) *App {
-	delegate := list.NewDefaultDelegate()
-	delegate.SetHeight(3)
+// Rows taken by each email in the list and the blank rows between them.
+const (
+	listItemHeight  = 3
+	listItemSpacing = 1
+)
+
+func NewApp(config imapConfig, options readOptions) *App {
+	delegate := emailDelegate{list.NewDefaultDelegate()}
+	delegate.SetHeight(listItemHeight)
+	delegate.SetSpacing(listItemSpacing)
 	l := list.New([]list.Item{}, delegate, 0, 0)
+	setListKeys(&l, options.vimKeys)
 	l.Title = "📧 Email Inbox (Loading...)"
 	l.SetShowStatusBar(false)
 	l.SetFilteringEnabled(true)
 
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = loadingStyle.Padding(0)
+
+	jumpInput := textinput.New()
+	jumpInput.Prompt = ":"
+	jumpInput.Placeholder = "email number, or p<N> for page N"
+
 	return &App{
-		username:      username,
-		password:      password,
-		host:          host,
-		port:          port,
-		list:          l,
-		loading:       true,
-		state:         listView,
-		emailsPerPage: 50,
-		currentPage:   1,
+		config:          config,
+		list:            l,
+		loading:         true,
+		state:           listView,
+		options:         options,
+		emailsPerPage:   options.perPage,
+		currentPage:     1,
+		jumpInput:       jumpInput,
+		spinner:         s,
+		progressBar:     progress.New(progress.WithDefaultGradient(), progress.WithoutPercentage()),
+		progressCh:      make(chan fetchProgressMsg, 16),
+		pendingJump:     -1,
+		plainText:       options.plainText,
+		prefetching:     make(map[uint32]bool),
+		expandedThreads: make(map[uint32]bool),
+		marked:          make(map[uint32]bool),
+		prefetched:      make(map[uint32]bool),
 	}
 }
 
 func (a *App) Init() tea.Cmd {
-	return a.loadEmails(1, false)
+	return tea.Batch(a.loadCachedEmails(), a.loadEmails(1, false), a.spinner.Tick, a.waitForProgress())
 }
 
-func (a *App) loadEmails(page int, isLoadMore bool) tea.Cmd {
+// loadCachedEmails shows the envelopes cached by the previous run while the
+// first page is fetched from the server.
+func (a *App) loadCachedEmails() tea.Cmd {
+	if a.options.cacheSize == 0 {
+		return nil
+	}
 	return func() tea.Msg {
-		if a.client == nil {
-			client, err := connectToServer(a.username, a.password, a.host, a.port)
-			if err != nil {
-				return errorMsg(err)
-			}
-			a.client = client
+		cache, ok := loadEnvelopeCache(a.config, a.config.inbox)
+		if !ok {
+			return nil
 		}
+		return emailsLoadedMsg{emails: cache.Emails, totalMessages: cache.TotalMessages, fromCache: true, uidValidity: cache.UIDValidity}
+	}
+}
+
+func (a *App) loadEmails(page int, isLoadMore bool) tea.Cmd {
+	return a.loadPages(page, page, isLoadMore)
+}
 
-		emails, totalMessages, err := fetchEmails(a.client, page, a.emailsPerPage)
+// loadPages fetches pages first through last in one go, so jumping far ahead
+// doesn't need one LoadMore per page. Later pages continue below the oldest
+// UID loaded so far.
+func (a *App) loadPages(first, last int, isLoadMore bool) tea.Cmd {
+	var before uint32
+	if isLoadMore {
+		before = a.oldestUID
+	}
+	count := (last - first + 1) * a.emailsPerPage
+	return a.withConnection(func() tea.Msg {
+		emails, totalMessages, err := fetchEmails(a.client, a.config.inbox, a.options.readOnly, before, 0, count, a.reportProgress)
 		if err != nil {
-			return errorMsg(err)
+			return errorMsg(wrapTimeout(err, "fetching emails", a.config.commandTimeout))
 		}
 
-		return emailsLoadedMsg{
+		loaded := emailsLoadedMsg{
 			emails:        emails,
 			totalMessages: totalMessages,
 			isLoadMore:    isLoadMore,
 		}
-	}
+		if mailbox := a.client.Mailbox(); mailbox != nil {
+			loaded.uidValidity = mailbox.UidValidity
+		}
+		if !isLoadMore {
+			if quota, ok, err := fetchQuota(a.client, a.config.inbox); err == nil && ok {
+				loaded.quota = &quota
+			}
+			if a.options.countUnread {
+				if unseen, err := searchUnseen(a.client); err == nil {
+					unread := len(unseen)
+					loaded.unread = &unread
+				}
+			}
+		}
+		if first == 1 && a.options.cacheSize > 0 && loaded.uidValidity != 0 {
+			saveEnvelopeCache(a.config, a.config.inbox, loaded.uidValidity, totalMessages, emails, a.options.cacheSize)
+		}
+		return loaded
+	})
 }
 
 func (a *App) loadEmailBody(uid uint32) tea.Cmd {
-	return func() tea.Msg {
-		email, err := fetchEmailBodyParsed(a.client, uid)
+	return a.withReconnect(func() tea.Msg {
+		bodies, err := fetchEmailParts(a.client, []uint32{uid}, a.options.readOnly)
 		if err != nil {
-			return errorMsg(err)
+			return errorMsg(wrapTimeout(err, "fetching email body", a.config.commandTimeout))
+		}
+		email, ok := bodies[uid]
+		if !ok {
+			return errorMsg(fmt.Errorf("could not load email body"))
 		}
 		return emailBodyLoadedMsg{uid: uid, body: email}
-	}
+	})
 }
 
-func (a *App) deleteEmail(uid uint32) tea.Cmd {
-	return func() tea.Msg {
-		success, message := moveEmailToTrash(a.client, uid)
-		a.deleteConfirmIndex = 0
-		return emailDeletedMsg{
-			uid:     uid,
-			success: success,
-			message: message,
+// sourceUse is what the full source of an email is fetched for.
+type sourceUse int
+
+const (
+	sourceView   sourceUse = iota // R, in the pager
+	sourceExport                  // e, as a .eml file
+	sourceImages                  // I, for its inline images
+)
+
+// sourceLoadedMsg carries the full source of an email, fetched to show it
+// with R, export it with e or draw its images with I.
+type sourceLoadedMsg struct {
+	uid uint32
+	raw string
+	use sourceUse
+}
+
+// loadSource fetches the full source of an email, attachments included,
+// which opening it leaves out.
+func (a *App) loadSource(uid uint32, use sourceUse) tea.Cmd {
+	return a.withReconnect(func() tea.Msg {
+		email, err := fetchEmailBodyParsed(a.client, uid, true)
+		if err != nil {
+			return errorMsg(wrapTimeout(err, "fetching email source", a.config.commandTimeout))
 		}
-	}
+		return sourceLoadedMsg{uid: uid, raw: email.Raw, use: use}
+	})
 }
 
-func (a *App) updateEmailList() {
-	items := make([]list.Item, len(a.emails))
-	for i, email := range a.emails {
-		items[i] = email
+// prefetchChunk is how many bodies are prefetched per lock of the
+// connection, so an email the user opens waits for at most one chunk.
+const prefetchChunk = 2
+
+// prefetchAfter fetches, in the background, the bodies of the emails that
+// follow uid in the list so that opening them next is instant. Prefetching
+// never waits for the connection: it fetches prefetchChunk bodies at a time
+// and gives up when another command holds the connection in between. Errors
+// are ignored since the email is fetched again when opened.
+func (a *App) prefetchAfter(uid uint32) tea.Cmd {
+	if a.options.prefetch == 0 || a.client == nil {
+		return nil
 	}
 
-	if a.hasMore {
-		items = append(items, LoadMoreItem{})
+	var uids []uint32
+	found := false
+	for _, item := range a.list.Items() {
+		email, ok := item.(Email)
+		if !ok {
+			continue
+		}
+		if email.UID == uid {
+			found = true
+			continue
+		}
+		if !found {
+			continue
+		}
+		if i := a.findEmail(email.UID); i >= 0 && a.emails[i].Body == "" && !a.prefetching[email.UID] {
+			uids = append(uids, email.UID)
+			a.prefetching[email.UID] = true
+		}
+		if len(uids) == a.options.prefetch {
+			break
+		}
 	}
 
-	a.list.SetItems(items)
+	if len(uids) == 0 {
+		return nil
+	}
+	return func() tea.Msg {
+		bodies := make(map[uint32]Email)
+		for chunk := range slices.Chunk(uids, prefetchChunk) {
+			if !a.imapMu.TryLock() {
+				break
+			}
+			// A reconnect may have dropped the client since the Cmd was built
+			if a.client == nil || isClosed(a.client) {
+				a.imapMu.Unlock()
+				break
+			}
+			fetched, err := fetchEmailParts(a.client, chunk, true)
+			a.imapMu.Unlock()
+			if err != nil {
+				break
+			}
+			maps.Copy(bodies, fetched)
+		}
+		return emailsPrefetchedMsg{uids: uids, bodies: bodies}
+	}
 }
 
-func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	switch msg := msg.(type) {
-	case tea.WindowSizeMsg:
-		if !a.ready {
-			a.list.SetSize(msg.Width, msg.Height-2)
-			a.viewport = viewport.New(msg.Width-4, msg.Height-4)
-			a.viewport.Style = emailViewStyle
-			a.ready = true
-		} else {
-			a.list.SetSize(msg.Width, msg.Height-2)
-			a.viewport.Width = msg.Width - 4
-			a.viewport.Height = msg.Height - 4
+// markSeen flags an email as read on the server. It is used for bodies that
+// were prefetched with BODY.PEEK, which leaves the \Seen flag untouched.
+func (a *App) markSeen(uid uint32) tea.Cmd {
+	return a.withReconnect(func() tea.Msg {
+		seqSet := new(imap.SeqSet)
+		seqSet.AddNum(uid)
+		item := imap.FormatFlagsOp(imap.AddFlags, true)
+		if err := a.client.UidStore(seqSet, item, []interface{}{imap.SeenFlag}, nil); err != nil {
+			return errorMsg(wrapTimeout(err, "marking email as read", a.config.commandTimeout))
 		}
+		return nil
+	})
+}
 
-	case emailsLoadedMsg:
-		a.loading = false
-		a.loadingMore = false
-		a.totalMessages = msg.totalMessages
+// forwardedFlag is the keyword clients set on an email once it was forwarded.
+const forwardedFlag = "$Forwarded"
 
-		if msg.isLoadMore {
-			a.emails = append(a.emails, msg.emails...)
-		} else {
-			a.emails = msg.emails
+// markOriginal flags an email on the server once it was replied to, with
+// \Answered, or forwarded, with $Forwarded, from the reader.
+func (a *App) markOriginal(uid uint32, forward bool) tea.Cmd {
+	flag, what := imap.AnsweredFlag, "answered"
+	if forward {
+		flag, what = forwardedFlag, "forwarded"
+	}
+	return a.withReconnect(func() tea.Msg {
+		seqSet := new(imap.SeqSet)
+		seqSet.AddNum(uid)
+		item := imap.FormatFlagsOp(imap.AddFlags, true)
+		if err := a.client.UidStore(seqSet, item, []interface{}{flag}, nil); err != nil {
+			return errorMsg(wrapTimeout(err, "marking email as "+what, a.config.commandTimeout))
 		}
+		return nil
+	})
+}
 
-		loadedCount := len(a.emails)
-		a.hasMore = uint32(loadedCount) < a.totalMessages
-
-		title := fmt.Sprintf("📧 Email Inbox (%d of %d emails)", loadedCount, a.totalMessages)
-		if a.hasMore {
-			title += " • More available"
+func (a *App) deleteEmails(uids []uint32) tea.Cmd {
+	return a.withReconnect(func() tea.Msg {
+		success, message := moveEmailsToTrash(a.client, a.config.inbox, uids, a.options.trashFolder)
+		if !success && isClosed(a.client) {
+			return errorMsg(fmt.Errorf("failed to delete email: %s", message))
 		}
-		a.list.Title = title
-
-		a.updateEmailList()
-
-	case emailBodyLoadedMsg:
-		for i, email := range a.emails {
-			if email.UID == msg.uid {
-				a.emails[i].Body = msg.body.Body
-				a.emails[i].HTMLBody = msg.body.HTMLBody
-				a.emails[i].TextBody = msg.body.TextBody
-				a.emails[i].ContentType = msg.body.ContentType
-				break
-			}
+		a.confirmIndex = 0
+		return emailDeletedMsg{
+			uids:    uids,
+			success: success,
+			message: message,
 		}
-		if a.state == emailView && len(a.emails) > 0 && a.list.Index() < len(a.emails) {
-			selectedEmail := a.emails[a.list.Index()]
-			if selectedEmail.UID == msg.uid {
-				content := formatEmailForView(selectedEmail)
-				a.viewport.SetContent(content)
-			}
+	})
+}
+
+func (a *App) emptyTrash() tea.Cmd {
+	return a.withReconnect(func() tea.Msg {
+		folder, count, err := emptyTrashFolder(a.client, a.config.inbox, a.options.trashFolder)
+		if err != nil {
+			return errorMsg(err)
 		}
+		return trashEmptiedMsg{folder: folder, count: count}
+	})
+}
 
-	case emailDeletedMsg:
-		a.deletingEmail = false
-		a.showDeleteConfirm = false
-		a.state = listView
+func (a *App) markAllRead() tea.Cmd {
+	return a.withReconnect(func() tea.Msg {
+		count, err := markMailboxRead(a.client)
+		if err != nil {
+			return errorMsg(wrapTimeout(err, "marking all emails as read", a.config.commandTimeout))
+		}
+		return allMarkedReadMsg{count: count}
+	})
+}
 
-		if msg.success {
-			for i, email := range a.emails {
-				if email.UID == msg.uid {
-					a.emails = append(a.emails[:i], a.emails[i+1:]...)
-					break
-				}
-			}
-			a.updateEmailList()
+type fetchProgressMsg struct {
+	received int
+	expected int
+}
 
-			a.totalMessages--
-			title := fmt.Sprintf("📧 Email Inbox (%d of %d emails)", len(a.emails), a.totalMessages)
-			if a.hasMore {
-				title += " • More available"
-			}
-			a.list.Title = title
+// reportProgress forwards fetch progress to the UI without ever blocking the
+// fetch; Update only needs the latest value.
+func (a *App) reportProgress(received, expected int) {
+	select {
+	case a.progressCh <- fetchProgressMsg{received: received, expected: expected}:
+	default:
+	}
+}
 
-			a.deleteSuccess = true
-			a.deleteSuccessMessage = msg.message
+func (a *App) waitForProgress() tea.Cmd {
+	return func() tea.Msg {
+		return <-a.progressCh
+	}
+}
 
-			return a, tea.Tick(3*time.Second, func(t time.Time) tea.Msg {
-				return clearSuccessMsg{}
-			})
-		} else {
-			a.err = fmt.Errorf("failed to delete email: %s", msg.message)
-		}
+// withReconnect wraps an IMAP operation so that a dropped connection is
+// reported as a connectionLostMsg, letting Update reconnect and retry it.
+// Operations never run without a client: while a reconnect is in progress
+// they report the connection as lost instead.
+func (a *App) withReconnect(op func() tea.Msg) tea.Cmd {
+	return a.guardIMAP(op, false)
+}
 
-	case clearSuccessMsg:
-		a.deleteSuccess = false
-		a.deleteSuccessMessage = ""
+// withConnection is withReconnect for operations that may run before the
+// first connection, such as the initial load: a missing client is dialed
+// under the lock before op runs.
+func (a *App) withConnection(op func() tea.Msg) tea.Cmd {
+	return a.guardIMAP(op, true)
+}
 
-	case errorMsg:
-		a.err = msg
-		a.loading = false
-		a.loadingMore = false
-		a.deletingEmail = false
+var errNotConnected = errors.New("not connected to the IMAP server")
 
-	case tea.KeyMsg:
-		if a.state == deleteConfirmView {
-			switch msg.String() {
-			case "left", "h", "right", "l":
-				if a.deleteConfirmIndex == 0 {
-					a.deleteConfirmIndex = 1
-				} else {
-					a.deleteConfirmIndex = 0
-				}
-			case "enter":
-				if a.deleteConfirmIndex == 1 && a.emailToDelete != nil {
-					a.deletingEmail = true
-					return a, a.deleteEmail(a.emailToDelete.UID)
-				} else {
-					a.showDeleteConfirm = false
-					a.state = listView
-					a.emailToDelete = nil
-				}
-			case "esc", "q":
-				a.showDeleteConfirm = false
-				a.state = listView
-				a.emailToDelete = nil
+func (a *App) guardIMAP(op func() tea.Msg, dial bool) tea.Cmd {
+	var locked func() tea.Msg
+	run := func() (msg tea.Msg, closed bool) {
+		a.imapMu.Lock()
+		defer a.imapMu.Unlock()
+		if a.client == nil {
+			if !dial {
+				return connectionLostMsg{err: errNotConnected, retry: locked}, true
 			}
-			return a, nil
+			client, err := connectToServer(a.config)
+			if err != nil {
+				return errorMsg(err), false
+			}
+			a.client = client
+		}
+		msg = op()
+		return msg, isClosed(a.client)
+	}
+	locked = func() tea.Msg {
+		msg, _ := run()
+		return msg
+	}
+	return func() tea.Msg {
+		msg, closed := run()
+		if err, ok := msg.(errorMsg); ok && (isConnectionError(err) || closed) {
+			return connectionLostMsg{err: err, retry: locked}
 		}
+		return msg
+	}
+}
 
-		switch msg.String() {
+// reconnect drops the stale client and dials a new one after an exponential
+// backoff, selects the inbox again, then asks Update to run retry once more.
+// It holds imapMu throughout so no operation sees the client half-replaced.
+func (a *App) reconnect(msg connectionLostMsg) tea.Cmd {
+	return tea.Tick(reconnectBackoff(msg.attempt), func(time.Time) tea.Msg {
+		a.imapMu.Lock()
+		defer a.imapMu.Unlock()
+		if a.client != nil {
+			a.client.Terminate()
+			a.client = nil
+		}
+		c, err := connectToServer(a.config)
+		if err != nil {
+			return connectionLostMsg{err: err, attempt: msg.attempt + 1, retry: msg.retry}
+		}
+		// Operations such as flag updates expect the inbox to be selected
+		if _, err := c.Select(a.config.inbox, a.options.readOnly); err != nil {
+			c.Terminate()
+			return connectionLostMsg{err: err, attempt: msg.attempt + 1, retry: msg.retry}
+		}
+		a.client = c
+		return reconnectedMsg{retry: msg.retry}
+	})
+}
+
+func reconnectBackoff(attempt int) time.Duration {
+	backoff := 500 * time.Millisecond << attempt
+	if backoff > 8*time.Second {
+		backoff = 8 * time.Second
+	}
+	return backoff
+}
+
+// isConnectionError reports whether err means the IMAP connection is gone
+// rather than the server rejecting a command.
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) || errors.Is(err, client.ErrNotLoggedIn) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"connection closed", "broken pipe", "connection reset", "use of closed network connection"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func isClosed(c *client.Client) bool {
+	if c == nil {
+		return false
+	}
+	select {
+	case <-c.LoggedOut():
+		return true
+	default:
+		return false
+	}
+}
+
+func (a *App) updateTitle() {
+	unread := 0
+	if a.unread != nil {
+		unread = *a.unread
+	} else {
+		for _, email := range a.emails {
+			if !email.Seen {
+				unread++
+			}
+		}
+	}
+	title := fmt.Sprintf("📧 Email Inbox (%d unread, %d of %d emails)", unread, len(a.emails), a.totalMessages)
+	if a.hasMore {
+		title += " • More available"
+	}
+	if a.unreadOnly {
+		title += " • Unread only"
+	}
+	if a.threaded {
+		title += " • Threaded"
+	}
+	title += " • Sort: " + a.sortMode.String()
+	if a.config.insecure {
+		title += " • ⚠️ TLS not verified"
+	}
+	a.list.Title = title
+}
+
+// visibleEmails returns the loaded emails that pass the unread filter.
+func (a *App) visibleEmails() []Email {
+	visible := make([]Email, 0, len(a.emails))
+	for _, email := range a.emails {
+		if a.unreadOnly && email.Seen {
+			continue
+		}
+		visible = append(visible, email)
+	}
+	return visible
+}
+
+func (a *App) updateEmailList() {
+	items := make([]list.Item, 0, len(a.emails))
+	a.pruneMarked()
+	visible := a.visibleEmails()
+	for i := range visible {
+		visible[i].marked = a.marked[visible[i].UID]
+		visible[i].absoluteDate = a.options.absoluteDates
+		visible[i].fromFormat = a.options.fromFormat
+		visible[i].senderColors = a.options.senderColors
+		visible[i].location = a.options.location
+		// Leave room for the delegate's padding and selection border
+		visible[i].titleWidth = a.list.Width() - 4
+	}
+
+	if a.threaded {
+		for _, thread := range groupThreads(visible) {
+			root := thread[0]
+			root.threadReplies = len(thread) - 1
+			root.threadExpanded = a.expandedThreads[root.UID]
+			items = append(items, root)
+			if !root.threadExpanded {
+				continue
+			}
+			for _, reply := range thread[1:] {
+				reply.threadDepth = 1
+				items = append(items, reply)
+			}
+		}
+	} else {
+		for _, email := range visible {
+			items = append(items, email)
+		}
+	}
+
+	if a.hasMore {
+		items = append(items, LoadMoreItem{})
+	} else if len(items) > 0 {
+		items = append(items, EndOfMailboxItem{})
+	}
+
+	a.list.SetItems(items)
+	a.skipEndOfMailbox()
+}
+
+// skipEndOfMailbox moves the cursor off the end of mailbox item onto the
+// last email.
+func (a *App) skipEndOfMailbox() {
+	if _, ok := a.list.SelectedItem().(EndOfMailboxItem); ok && a.list.Index() > 0 {
+		a.list.Select(a.list.Index() - 1)
+	}
+}
+
+func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.MouseMsg:
+		if a.state == listView && !a.showHelp && !a.jumping {
+			return a.handleMouse(msg)
+		}
+
+	case tea.WindowSizeMsg:
+		a.width = msg.Width
+		a.height = msg.Height
+		if !a.ready {
+			a.list.SetSize(msg.Width, msg.Height-3)
+			a.viewport = viewport.New(msg.Width-4, msg.Height-5)
+			a.viewport.Style = emailViewStyle
+			setViewportKeys(&a.viewport, a.options.vimKeys)
+			a.ready = true
+		} else {
+			a.list.SetSize(msg.Width, msg.Height-3)
+			a.viewport.Width = msg.Width - 4
+			a.viewport.Height = msg.Height - 5
+		}
+		if len(a.emails) > 0 {
+			a.updateEmailList()
+		}
+		if a.state == emailView {
+			if i := a.findEmail(a.openUID); i >= 0 {
+				a.viewport.SetContent(a.renderEmail(a.emails[i]))
+			}
+		}
+		if a.state == composeView {
+			a.compose.setSize(a.width, a.height)
+		}
+		if a.state == folderPickerView {
+			a.setFolderPickerSize()
+		}
+
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		a.spinner, cmd = a.spinner.Update(msg)
+		return a, cmd
+
+	case fetchProgressMsg:
+		if a.loading || a.loadingMore || a.syncing {
+			a.fetchProgress = msg
+		}
+		return a, a.waitForProgress()
+
+	case emailsLoadedMsg:
+		if msg.fromCache && !a.loading {
+			// The server answered first
+			return a, nil
+		}
+		a.syncing = msg.fromCache
+		a.loading = false
+		a.fetchProgress = fetchProgressMsg{}
+		a.loadingMore = false
+		a.totalMessages = msg.totalMessages
+
+		// Remember the selection so a refresh does not move the cursor
+		selectedIndex := a.list.Index()
+		var selectedUID uint32
+		if email, ok := a.list.SelectedItem().(Email); ok {
+			selectedUID = email.UID
+		}
+
+		// The UIDs listed so far belong to another UIDVALIDITY, such as a
+		// cache from before the mailbox was recreated: nothing loaded for
+		// them applies to the emails now behind those UIDs
+		if msg.uidValidity != 0 && a.uidValidity != 0 && msg.uidValidity != a.uidValidity {
+			a.emails = nil
+			a.marked = make(map[uint32]bool)
+			a.prefetched = make(map[uint32]bool)
+			msg.isLoadMore = false
+			selectedUID = 0
+		}
+		if msg.uidValidity != 0 {
+			a.uidValidity = msg.uidValidity
+		}
+
+		if !msg.isLoadMore {
+			a.oldestUID = 0
+		}
+		for _, email := range msg.emails {
+			if a.oldestUID == 0 || email.UID < a.oldestUID {
+				a.oldestUID = email.UID
+			}
+		}
+
+		if msg.isLoadMore {
+			a.emails = appendNewEmails(a.emails, msg.emails)
+		} else {
+			// Keep bodies already fetched for emails that are still listed
+			for i, email := range msg.emails {
+				if j := a.findEmail(email.UID); j >= 0 && a.emails[j].Body != "" {
+					msg.emails[i].Body = a.emails[j].Body
+					msg.emails[i].HTMLBody = a.emails[j].HTMLBody
+					msg.emails[i].TextBody = a.emails[j].TextBody
+					msg.emails[i].ContentType = a.emails[j].ContentType
+					msg.emails[i].Raw = a.emails[j].Raw
+					msg.emails[i].RawHeader = a.emails[j].RawHeader
+					msg.emails[i].Attachments = a.emails[j].Attachments
+				}
+			}
+			a.emails = msg.emails
+			a.quota = msg.quota
+			a.unread = msg.unread
+		}
+
+		sortEmails(a.emails, a.sortMode)
+
+		a.hasMore = uint32(len(a.emails)) < a.totalMessages
+		if msg.isLoadMore && len(msg.emails) == 0 {
+			// Nothing older is left, even if the count says otherwise
+			a.hasMore = false
+		}
+		a.updateTitle()
+		a.updateEmailList()
+
+		if a.pendingJump >= 0 && len(a.emails) > 0 {
+			if !a.selectJump(a.pendingJump, a.pendingJumpPage) {
+				a.list.Select(max(a.listedEmails()-1, 0))
+			}
+		} else if !msg.isLoadMore {
+			a.restoreSelection(selectedUID, selectedIndex)
+		}
+		a.pendingJump = -1
+
+	case emailBodyLoadedMsg:
+		if msg.uid == a.openUID {
+			a.loadingBody = false
+		}
+		a.setBody(msg.uid, msg.body)
+		delete(a.prefetched, msg.uid)
+		if !a.options.readOnly {
+			// Fetching the body without PEEK marked it as read
+			a.noteSeen(msg.uid)
+		}
+		if a.state == emailView && a.openUID == msg.uid {
+			if i := a.findEmail(msg.uid); i >= 0 {
+				a.viewport.SetContent(a.renderEmail(a.emails[i]))
+			}
+			return a, a.prefetchAfter(msg.uid)
+		}
+
+	case sourceLoadedMsg:
+		i := a.findEmail(msg.uid)
+		if i < 0 {
+			break
+		}
+		a.emails[i].Raw = msg.raw
+		if msg.use == sourceExport {
+			return a, a.exportEmail(a.emails[i])
+		}
+		if a.state == emailView && a.openUID == msg.uid {
+			if msg.use == sourceImages {
+				return a, a.showInlineImages(msg.raw)
+			}
+			return a, viewInPager(msg.raw)
+		}
+
+	case emailsPrefetchedMsg:
+		for _, uid := range msg.uids {
+			delete(a.prefetching, uid)
+			body, ok := msg.bodies[uid]
+			if i := a.findEmail(uid); ok && i >= 0 && a.emails[i].Body == "" {
+				a.setBody(uid, body)
+				a.prefetched[uid] = true
+			}
+		}
+
+	case emailDeletedMsg:
+		a.deletingEmail = false
+		a.showDeleteConfirm = false
+		a.state = listView
+
+		if msg.success {
+			a.removeEmails(msg.uids)
+			return a, a.showToast(msg.message)
+		} else {
+			a.err = fmt.Errorf("failed to delete email: %s", msg.message)
+		}
+
+	case trashEmptiedMsg:
+		a.emptyingTrash = false
+		a.confirmIndex = 0
+		a.state = listView
+		return a, a.showToast(fmt.Sprintf("Purged %d message(s) from %s", msg.count, msg.folder))
+
+	case allMarkedReadMsg:
+		a.markingAllRead = false
+		a.confirmIndex = 0
+		a.state = listView
+		for i := range a.emails {
+			a.emails[i].Seen = true
+		}
+		if a.unread != nil {
+			a.unread = new(int)
+		}
+		a.updateTitle()
+		uid, hasSelection := a.selectedUID()
+		a.updateEmailList()
+		if hasSelection {
+			a.selectUID(uid)
+		}
+		return a, a.showToast(fmt.Sprintf("Marked %d email(s) as read", msg.count))
+
+	case clearSuccessMsg:
+		a.showSuccess = false
+		a.successMessage = ""
+
+	case editorClosedMsg:
+		if a.state != composeView {
+			return a, nil
+		}
+		if msg.err != nil {
+			a.compose.err = msg.err
+			return a, nil
+		}
+		a.compose.body.SetValue(msg.body)
+		a.compose.err = nil
+		return a, a.compose.focusField(composeBody)
+
+	case pagerClosedMsg:
+		if msg.err != nil {
+			return a, a.showToast(msg.err.Error())
+		}
+
+	case emailExportedMsg:
+		return a, a.showToast("Saved to " + msg.path)
+
+	case copiedMsg:
+		if msg.err != nil {
+			a.err = fmt.Errorf("failed to copy to clipboard: %w", msg.err)
+			return a, nil
+		}
+		return a, a.showToast(fmt.Sprintf("Copied %s to clipboard", msg.what))
+
+	case connectionLostMsg:
+		if msg.attempt >= maxReconnectAttempts {
+			a.reconnecting = false
+			a.err = msg.err
+			a.loading = false
+			a.loadingMore = false
+			a.syncing = false
+			a.deletingEmail = false
+			a.emptyingTrash = false
+			a.markingAllRead = false
+			return a, nil
+		}
+		a.reconnecting = true
+		return a, a.reconnect(msg)
+
+	case reconnectedMsg:
+		a.reconnecting = false
+		return a, msg.retry
+
+	case errorMsg:
+		a.err = msg
+		a.loading = false
+		a.loadingMore = false
+		a.deletingEmail = false
+		a.emptyingTrash = false
+		a.markingAllRead = false
+		a.reconnecting = false
+		a.pendingJump = -1
+		a.loadingBody = false
+		a.syncing = false
+		if a.moving {
+			a.moving = false
+			a.state = a.moveReturn
+		}
+
+	case foldersLoadedMsg:
+		if len(a.moveUIDs) == 0 || (a.state != listView && a.state != emailView) {
+			break
+		}
+		if len(msg.folders) == 0 {
+			return a, a.showToast("No other folder to move to")
+		}
+		a.openFolderPicker(msg.folders)
+
+	case emailsMovedMsg:
+		a.moving = false
+		a.state = listView
+		a.removeEmails(msg.uids)
+		return a, a.showToast(fmt.Sprintf("Moved %d email(s) to %s", len(msg.uids), msg.folder))
+
+	case composeSentMsg:
+		a.compose.sending = false
+		if msg.err != nil {
+			a.compose.err = msg.err
+			return a, nil
+		}
+		a.state = listView
+		if msg.dryRun != "" {
+			return a, tea.Batch(viewInPager(msg.dryRun), a.showToast("Dry run, nothing sent"))
+		}
+		var markOriginal tea.Cmd
+		if i := a.findEmail(msg.original); msg.original != 0 && i >= 0 && !a.options.readOnly {
+			if !msg.forward {
+				a.emails[i].Answered = true
+				uid, hasSelection := a.selectedUID()
+				a.updateEmailList()
+				if hasSelection {
+					a.selectUID(uid)
+				}
+			}
+			markOriginal = a.markOriginal(msg.original, msg.forward)
+		}
+		return a, tea.Batch(markOriginal, a.showToast("Email sent"))
+
+	case tea.KeyMsg:
+		if a.state == composeView {
+			return a.updateCompose(msg)
+		}
+		if a.state == folderPickerView {
+			return a.updateFolderPicker(msg)
+		}
+		if a.showHelp {
+			a.showHelp = false
+			return a, nil
+		}
+		if msg.String() == "?" && !a.jumping && a.list.FilterState() != list.Filtering {
+			a.showHelp = true
+			return a, nil
+		}
+
+		if a.jumping {
+			switch msg.String() {
+			case "enter":
+				a.jumping = false
+				a.jumpInput.Blur()
+				return a, a.jumpTo(a.jumpInput.Value())
+			case "esc", "ctrl+c":
+				a.jumping = false
+				a.jumpInput.Blur()
+				return a, nil
+			}
+			var cmd tea.Cmd
+			a.jumpInput, cmd = a.jumpInput.Update(msg)
+			return a, cmd
+		}
+
+		if a.state == deleteConfirmView {
+			switch msg.String() {
+			case "left", "h", "right", "l":
+				if a.confirmIndex == 0 {
+					a.confirmIndex = 1
+				} else {
+					a.confirmIndex = 0
+				}
+			case "enter":
+				if a.confirmIndex == 1 && len(a.deleteUIDs) > 0 {
+					a.deletingEmail = true
+					return a, a.deleteEmails(a.deleteUIDs)
+				} else {
+					a.showDeleteConfirm = false
+					a.state = listView
+					a.deleteUIDs = nil
+				}
+			case "esc", "q":
+				a.showDeleteConfirm = false
+				a.state = listView
+				a.deleteUIDs = nil
+			}
+			return a, nil
+		}
+
+		if a.state == emptyTrashConfirmView {
+			switch msg.String() {
+			case "left", "h", "right", "l":
+				a.confirmIndex = 1 - a.confirmIndex
+			case "enter":
+				if a.confirmIndex == 1 && !a.emptyingTrash {
+					a.emptyingTrash = true
+					return a, a.emptyTrash()
+				} else if !a.emptyingTrash {
+					a.state = listView
+				}
+			case "esc", "q":
+				if !a.emptyingTrash {
+					a.confirmIndex = 0
+					a.state = listView
+				}
+			}
+			return a, nil
+		}
+
+		if a.state == markAllReadConfirmView {
+			switch msg.String() {
+			case "left", "h", "right", "l":
+				a.confirmIndex = 1 - a.confirmIndex
+			case "enter":
+				if a.confirmIndex == 1 && !a.markingAllRead {
+					a.markingAllRead = true
+					return a, a.markAllRead()
+				} else if !a.markingAllRead {
+					a.state = listView
+				}
+			case "esc", "q":
+				if !a.markingAllRead {
+					a.confirmIndex = 0
+					a.state = listView
+				}
+			}
+			return a, nil
+		}
+
+		if a.handleMotion(msg) {
+			a.skipEndOfMailbox()
+			return a, nil
+		}
+
+		if action, ok := readOnlyActions[msg.String()]; ok && a.options.readOnly {
+			inList := a.state == listView && a.list.FilterState() != list.Filtering
+			if inList || (a.state == emailView && msg.String() == "d") {
+				return a, a.showToast("Read-only mode: " + action + " is disabled")
+			}
+		}
+
+		switch msg.String() {
 		case "ctrl+c", "q":
 			if a.client != nil {
 				a.client.Logout()
@@ -339,7 +1651,9 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return a, tea.Quit
 
 		case "enter":
-			if a.state == listView && a.list.Index() < len(a.list.Items()) {
+			// Resolve the item itself: with a filter applied, the list
+			// index no longer lines up with a.emails
+			if a.state == listView && a.list.FilterState() != list.Filtering && a.list.SelectedItem() != nil {
 				selectedItem := a.list.SelectedItem()
 
 				if _, isLoadMore := selectedItem.(LoadMoreItem); isLoadMore {
@@ -351,16 +1665,30 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return a, nil
 				}
 
-				if a.list.Index() < len(a.emails) {
-					selectedEmail := a.emails[a.list.Index()]
+				if email, ok := selectedItem.(Email); ok && a.findEmail(email.UID) >= 0 {
+					if a.syncing {
+						return a, a.waitForSync()
+					}
+					selectedEmail := a.emails[a.findEmail(email.UID)]
+					a.openUID = selectedEmail.UID
 					a.state = emailView
+					a.showHeaders = false
+					a.showQuoted = false
 					if selectedEmail.Body == "" {
-						a.viewport.SetContent(formatEmailForView(selectedEmail))
+						a.loadingBody = true
+						a.viewport.SetContent(a.renderEmail(selectedEmail))
 						return a, a.loadEmailBody(selectedEmail.UID)
-					} else {
-						content := formatEmailForView(selectedEmail)
-						a.viewport.SetContent(content)
 					}
+					a.viewport.SetContent(a.renderEmail(selectedEmail))
+					var markSeen tea.Cmd
+					if a.prefetched[selectedEmail.UID] {
+						delete(a.prefetched, selectedEmail.UID)
+						if !selectedEmail.Seen && !a.options.readOnly {
+							markSeen = a.markSeen(selectedEmail.UID)
+							a.noteSeen(selectedEmail.UID)
+						}
+					}
+					return a, tea.Sequence(markSeen, a.prefetchAfter(selectedEmail.UID))
 				}
 			}
 
@@ -370,25 +1698,243 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 		case "d":
-			if (a.state == listView || a.state == emailView) && len(a.emails) > 0 {
-				var emailToDelete *Email
-
-				if a.state == emailView && a.list.Index() < len(a.emails) {
-					emailToDelete = &a.emails[a.list.Index()]
-				} else if a.state == listView && a.list.Index() < len(a.emails) {
-					selectedItem := a.list.SelectedItem()
-					if email, ok := selectedItem.(Email); ok {
-						emailToDelete = &email
+			if a.syncing && (a.state == listView || a.state == emailView) {
+				return a, a.waitForSync()
+			}
+			if (a.state == listView || a.state == emailView) && len(a.emails) > 0 && !a.deletingEmail {
+				if uids := a.actionTargets(); len(uids) > 0 {
+					a.deleteUIDs = uids
+					if !a.options.confirmDelete {
+						a.deletingEmail = true
+						return a, a.deleteEmails(uids)
 					}
-				}
-
-				if emailToDelete != nil {
-					a.emailToDelete = emailToDelete
 					a.showDeleteConfirm = true
 					a.state = deleteConfirmView
 				}
 			}
 
+		case "s":
+			if a.state == listView && a.list.FilterState() != list.Filtering && len(a.emails) > 0 {
+				uid, hasSelection := a.selectedUID()
+				a.sortMode = (a.sortMode + 1) % sortModeCount
+				sortEmails(a.emails, a.sortMode)
+				a.updateTitle()
+				a.updateEmailList()
+				if hasSelection {
+					a.selectUID(uid)
+				}
+				return a, nil
+			}
+
+		case " ":
+			if a.state == listView && a.list.FilterState() != list.Filtering {
+				if email, ok := a.currentEmail(); ok {
+					a.toggleMarked(email.UID)
+					a.updateEmailList()
+					return a, nil
+				}
+			}
+
+		case "*", "i":
+			if a.state == listView && a.list.FilterState() != list.Filtering && len(a.emails) > 0 {
+				if msg.String() == "*" {
+					a.markAll()
+				} else {
+					a.invertMarked()
+				}
+				a.updateEmailList()
+				return a, nil
+			}
+
+		case "M":
+			if a.syncing && (a.state == listView || a.state == emailView) {
+				return a, a.waitForSync()
+			}
+			if a.state == emailView || (a.state == listView && a.list.FilterState() != list.Filtering) {
+				if uids := a.actionTargets(); len(uids) > 0 {
+					a.moveUIDs = uids
+					return a, a.loadFolders()
+				}
+			}
+
+		case "o":
+			if a.state == listView && a.list.FilterState() != list.Filtering && len(a.emails) > 0 {
+				uid, hasSelection := a.selectedUID()
+				if a.sortMode == sortDateDesc || a.sortMode == sortDateAsc {
+					// Already in date order, flip it without sorting again
+					slices.Reverse(a.emails)
+					if a.sortMode == sortDateDesc {
+						a.sortMode = sortDateAsc
+					} else {
+						a.sortMode = sortDateDesc
+					}
+				} else {
+					a.sortMode = sortDateDesc
+					sortEmails(a.emails, a.sortMode)
+				}
+				a.updateTitle()
+				a.updateEmailList()
+				if hasSelection {
+					a.selectUID(uid)
+				}
+				return a, nil
+			}
+
+		case "U":
+			if a.state == listView && a.list.FilterState() != list.Filtering {
+				uid, hasSelection := a.selectedUID()
+				a.unreadOnly = !a.unreadOnly
+				a.updateTitle()
+				a.updateEmailList()
+				if !hasSelection || !a.selectUID(uid) {
+					a.list.Select(0)
+				}
+				return a, nil
+			}
+
+		case "t":
+			if a.state == listView && a.list.FilterState() != list.Filtering {
+				uid, hasSelection := a.selectedUID()
+				a.threaded = !a.threaded
+				a.updateTitle()
+				a.updateEmailList()
+				if !hasSelection || !a.selectUID(uid) {
+					a.selectThreadOf(uid)
+				}
+				return a, nil
+			}
+
+		case "tab":
+			if a.state == listView && a.threaded && a.list.FilterState() != list.Filtering {
+				if uid, ok := a.selectedUID(); ok {
+					root := a.threadRoot(uid)
+					a.expandedThreads[root] = !a.expandedThreads[root]
+					a.updateEmailList()
+					a.selectUID(root)
+				}
+				return a, nil
+			}
+
+		case "E":
+			if a.state == listView && a.list.FilterState() != list.Filtering {
+				a.confirmIndex = 0
+				a.state = emptyTrashConfirmView
+				return a, nil
+			}
+
+		case "A":
+			if a.state == listView && a.list.FilterState() != list.Filtering {
+				a.confirmIndex = 0
+				a.state = markAllReadConfirmView
+				return a, nil
+			}
+
+		case ":":
+			if a.state == listView && a.list.FilterState() != list.Filtering && len(a.emails) > 0 {
+				a.jumping = true
+				a.jumpInput.Reset()
+				return a, a.jumpInput.Focus()
+			}
+
+		case "y":
+			if email, ok := a.currentEmail(); ok {
+				address := email.FromAddress
+				if address == "" {
+					address = email.From
+				}
+				return a, copyCmd("sender address", address)
+			}
+
+		case "e":
+			if a.state == emailView {
+				if email, ok := a.currentEmail(); ok {
+					if email.Raw == "" && email.Body != "" {
+						return a, a.loadSource(email.UID, sourceExport)
+					}
+					return a, a.exportEmail(email)
+				}
+			}
+
+		case "m":
+			if a.state == emailView {
+				if email, ok := a.currentEmail(); ok {
+					a.plainText = !a.plainText
+					a.viewport.SetContent(a.renderEmail(email))
+					if a.plainText {
+						return a, a.showToast("Showing plain text")
+					}
+					return a, a.showToast("Rendering markdown")
+				}
+			}
+
+		case "z":
+			if a.state == emailView {
+				if email, ok := a.currentEmail(); ok {
+					a.showQuoted = !a.showQuoted
+					a.viewport.SetContent(a.renderEmail(email))
+				}
+			}
+
+		case "H":
+			if a.state == emailView {
+				if email, ok := a.currentEmail(); ok {
+					a.showHeaders = !a.showHeaders
+					a.viewport.SetContent(a.renderEmail(email))
+					a.viewport.GotoTop()
+				}
+			}
+
+		case "R":
+			if a.state == emailView {
+				email, ok := a.currentEmail()
+				if !ok || email.Body == "" {
+					return a, a.showToast("The email is still loading")
+				}
+				if email.Raw == "" {
+					return a, a.loadSource(email.UID, sourceView)
+				}
+				return a, viewInPager(email.Raw)
+			}
+
+		case "I":
+			if a.state == emailView {
+				email, ok := a.currentEmail()
+				if !ok || email.Body == "" {
+					return a, a.showToast("The email is still loading")
+				}
+				if a.options.imageProtocol == "" {
+					return a, a.showToast("Images are not drawn in this terminal, see --inline-images")
+				}
+				if email.Raw == "" {
+					return a, a.loadSource(email.UID, sourceImages)
+				}
+				return a, a.showInlineImages(email.Raw)
+			}
+
+		case "Y":
+			if a.state == emailView {
+				if email, ok := a.currentEmail(); ok && email.Body != "" {
+					return a, copyCmd("email body", email.Body)
+				}
+			}
+
+		case "c":
+			if a.state == emailView || (a.state == listView && a.list.FilterState() != list.Filtering) {
+				return a, a.startCompose(newCompose("", "", "", nil))
+			}
+
+		case "a", "f":
+			if a.state == emailView {
+				email, ok := a.currentEmail()
+				if !ok || (email.Body == "" && a.loadingBody) {
+					return a, a.showToast("The email is still loading")
+				}
+				if msg.String() == "a" {
+					return a, a.startCompose(replyCompose(email))
+				}
+				return a, a.startCompose(forwardCompose(email))
+			}
+
 		case "r":
 			if a.state == listView && !a.loading {
 				a.loading = true
@@ -399,16 +1945,340 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	}
 
-	var cmd tea.Cmd
-	if a.state == listView {
-		a.list, cmd = a.list.Update(msg)
-	} else if a.state == emailView {
-		a.viewport, cmd = a.viewport.Update(msg)
+	var cmd tea.Cmd
+	if a.state == listView {
+		a.list, cmd = a.list.Update(msg)
+		a.skipEndOfMailbox()
+	} else if a.state == emailView {
+		a.viewport, cmd = a.viewport.Update(msg)
+	} else if a.state == composeView {
+		a.compose, cmd = a.compose.update(msg)
+	} else if a.state == folderPickerView {
+		a.folderPicker, cmd = a.folderPicker.Update(msg)
+	}
+	return a, cmd
+}
+
+// startCompose opens the compose view with m, unless the SMTP settings are
+// missing.
+func (a *App) startCompose(m composeModel) tea.Cmd {
+	if a.options.smtpErr != nil {
+		return a.showToast("Cannot compose: " + a.options.smtpErr.Error())
+	}
+	a.compose = m
+	a.compose.setSize(a.width, a.height)
+	a.composePristine = *a.compose.email()
+	a.composeReturn = a.state
+	a.state = composeView
+	return textinput.Blink
+}
+
+// updateCompose handles keys in the compose view. Leaving it keeps what was
+// typed as a draft, unless nothing changed since it opened.
+func (a *App) updateCompose(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if a.compose.sending {
+		return a, nil
+	}
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		a.state = a.composeReturn
+		email := a.compose.email()
+		// Every field counts, not only the ones the view lets you type in
+		if reflect.DeepEqual(*email, a.composePristine) {
+			return a, nil
+		}
+		email.Confirm = false
+		if _, err := saveDraft(email, ""); err != nil {
+			return a, a.showToast(err.Error())
+		}
+		return a, a.showToast("Draft saved, resume it with cleu drafts")
+	case "tab":
+		return a, a.compose.focusField((a.compose.focus + 1) % composeFields)
+	case "shift+tab":
+		return a, a.compose.focusField((a.compose.focus + composeFields - 1) % composeFields)
+	case "ctrl+e":
+		return a, editInEditorCmd(a.compose.body.Value())
+	case "ctrl+s":
+		email := a.compose.email()
+		email.AllowPlaceholders = a.options.allowPlaceholders
+		if err := validateComposed(email, a.options.smtp.maxRecipients); err != nil {
+			a.compose.err = err
+			return a, nil
+		}
+		a.compose.sending, a.compose.err = true, nil
+		return a, sendComposed(email, a.options.smtp, a.compose.original, a.compose.forward)
+	}
+	var cmd tea.Cmd
+	a.compose, cmd = a.compose.update(msg)
+	return a, cmd
+}
+
+type clearSuccessMsg struct{}
+
+// jumpTo handles the ":" prompt. A number selects that email of the list
+// (1-based) and "p<N>" selects the first listed email of page N, loading
+// pages as needed.
+func (a *App) jumpTo(input string) tea.Cmd {
+	input = strings.ToLower(strings.TrimSpace(input))
+	isPage := false
+	for _, prefix := range []string{"page", "p"} {
+		if strings.HasPrefix(input, prefix) {
+			input = strings.TrimSpace(strings.TrimPrefix(input, prefix))
+			isPage = true
+			break
+		}
+	}
+
+	n, err := strconv.Atoi(input)
+	if err != nil || n < 1 {
+		return nil
+	}
+	index := n - 1
+	if isPage {
+		index = (n - 1) * a.emailsPerPage
+	}
+
+	a.list.ResetFilter()
+	if a.selectJump(index, isPage) {
+		return nil
+	}
+	if !a.hasMore {
+		a.list.Select(max(a.listedEmails()-1, 0))
+		return nil
+	}
+	if a.loadingMore {
+		return nil
+	}
+
+	// Hidden emails may leave the target past the pages it would be on
+	firstPage := a.currentPage + 1
+	targetPage := max(index/a.emailsPerPage+1, firstPage)
+	a.loadingMore = true
+	a.pendingJump = index
+	a.pendingJumpPage = isPage
+	a.currentPage = targetPage
+	return a.loadPages(firstPage, targetPage, true)
+}
+
+// selectJump selects the email at index in the list or, for a page, the
+// first listed email from a.emails[index] on. It reports false when that
+// email is not loaded.
+func (a *App) selectJump(index int, page bool) bool {
+	if !page {
+		if index >= a.listedEmails() {
+			return false
+		}
+		a.list.Select(index)
+		return true
+	}
+	for _, email := range a.emails[min(index, len(a.emails)):] {
+		if a.selectUID(email.UID) || (a.threaded && a.selectUID(a.threadRoot(email.UID))) {
+			return true
+		}
+	}
+	return false
+}
+
+// listedEmails counts the emails in the list, leaving out the load more and
+// end of mailbox rows.
+func (a *App) listedEmails() int {
+	n := 0
+	for _, item := range a.list.Items() {
+		if _, ok := item.(Email); ok {
+			n++
+		}
+	}
+	return n
+}
+
+type emailExportedMsg struct {
+	path string
+}
+
+// exportEmail saves the source of email under --export-dir.
+func (a *App) exportEmail(email Email) tea.Cmd {
+	if email.Raw == "" {
+		return a.showToast("The email is still loading, try again in a moment")
+	}
+	dir := a.options.exportDir
+	return func() tea.Msg {
+		path, err := saveEML(dir, email)
+		if err != nil {
+			return errorMsg(fmt.Errorf("failed to export email: %w", err))
+		}
+		return emailExportedMsg{path: path}
+	}
+}
+
+type copiedMsg struct {
+	what string
+	err  error
+}
+
+func copyCmd(what, text string) tea.Cmd {
+	return func() tea.Msg {
+		return copiedMsg{what: what, err: copyToClipboard(text)}
+	}
+}
+
+// waitForSync refuses an action on the UIDs of cached envelopes: they may
+// not match the server's until the sync confirms the UIDVALIDITY.
+func (a *App) waitForSync() tea.Cmd {
+	return a.showToast("Still syncing with the server, try again in a moment")
+}
+
+// showToast displays message under the current view for a few seconds.
+func (a *App) showToast(message string) tea.Cmd {
+	a.showSuccess = true
+	a.successMessage = message
+	return tea.Tick(3*time.Second, func(t time.Time) tea.Msg {
+		return clearSuccessMsg{}
+	})
+}
+
+func (a *App) selectedUID() (uint32, bool) {
+	email, ok := a.list.SelectedItem().(Email)
+	return email.UID, ok
+}
+
+// selectUID moves the list cursor to the email with the given UID, if it is
+// still visible.
+func (a *App) selectUID(uid uint32) bool {
+	for i, item := range a.list.VisibleItems() {
+		if email, ok := item.(Email); ok && email.UID == uid {
+			a.list.Select(i)
+			return true
+		}
+	}
+	return false
+}
+
+// currentEmail returns the email open in emailView, or the one selected in
+// the list.
+func (a *App) currentEmail() (Email, bool) {
+	if a.state != listView && a.state != emailView {
+		return Email{}, false
+	}
+	if a.list.FilterState() == list.Filtering {
+		return Email{}, false
+	}
+	uid := a.openUID
+	if a.state == listView {
+		email, ok := a.list.SelectedItem().(Email)
+		if !ok {
+			return Email{}, false
+		}
+		uid = email.UID
+	}
+	if i := a.findEmail(uid); i >= 0 {
+		return a.emails[i], true
+	}
+	return Email{}, false
+}
+
+// setBody stores a fetched body on the loaded email with the given UID.
+func (a *App) setBody(uid uint32, body Email) {
+	if i := a.findEmail(uid); i >= 0 {
+		a.emails[i].Body = body.Body
+		a.emails[i].HTMLBody = body.HTMLBody
+		a.emails[i].TextBody = body.TextBody
+		a.emails[i].ContentType = body.ContentType
+		a.emails[i].Raw = body.Raw
+		a.emails[i].RawHeader = body.RawHeader
+		a.emails[i].Attachments = body.Attachments
+	}
+}
+
+// noteSeen records that uid is now read on the server, updating the unread
+// count and the list.
+func (a *App) noteSeen(uid uint32) {
+	i := a.findEmail(uid)
+	if i < 0 || a.emails[i].Seen {
+		return
+	}
+	a.emails[i].Seen = true
+	if a.unread != nil && *a.unread > 0 {
+		unread := *a.unread - 1
+		a.unread = &unread
+	}
+	a.updateTitle()
+	selected, hasSelection := a.selectedUID()
+	a.updateEmailList()
+	if hasSelection {
+		a.selectUID(selected)
+	}
+}
+
+// threadRoot returns the UID of the first email in the thread containing uid.
+func (a *App) threadRoot(uid uint32) uint32 {
+	for _, thread := range groupThreads(a.visibleEmails()) {
+		for _, email := range thread {
+			if email.UID == uid {
+				return thread[0].UID
+			}
+		}
+	}
+	return uid
+}
+
+// restoreSelection selects uid again after the list was reloaded, or the
+// item now at index when that email is gone.
+func (a *App) restoreSelection(uid uint32, index int) {
+	if uid != 0 && (a.selectUID(uid) || (a.threaded && a.selectUID(a.threadRoot(uid)))) {
+		return
+	}
+	if items := len(a.list.VisibleItems()); items > 0 {
+		a.list.Select(min(index, items-1))
 	}
-	return a, cmd
 }
 
-type clearSuccessMsg struct{}
+// selectThreadOf selects the thread containing uid when that email itself is
+// hidden in a collapsed thread, falling back to the first item.
+func (a *App) selectThreadOf(uid uint32) {
+	if !a.selectUID(a.threadRoot(uid)) {
+		a.list.Select(0)
+	}
+}
+
+// wrapWidth is the column at which the email view wraps: the viewport's
+// inner width, or the configured width when that is narrower.
+func (a *App) wrapWidth() int {
+	width := 80
+	if a.ready {
+		width = max(a.viewport.Width-emailViewStyle.GetHorizontalFrameSize(), 10)
+	}
+	if a.options.wrapWidth > 0 {
+		return min(width, a.options.wrapWidth)
+	}
+	return width
+}
+
+// findEmail returns the index of the email with the given UID in a.emails,
+// or -1 if it isn't loaded.
+func (a *App) findEmail(uid uint32) int {
+	for i, email := range a.emails {
+		if email.UID == uid {
+			return i
+		}
+	}
+	return -1
+}
+
+// appendNewEmails appends the emails of more whose UID is not already in
+// emails, so a page can never list the same message twice.
+func appendNewEmails(emails, more []Email) []Email {
+	seen := make(map[uint32]bool, len(emails))
+	for _, email := range emails {
+		seen[email.UID] = true
+	}
+	for _, email := range more {
+		if !seen[email.UID] {
+			seen[email.UID] = true
+			emails = append(emails, email)
+		}
+	}
+	return emails
+}
 
 func (a *App) View() string {
 	if a.err != nil {
@@ -420,43 +2290,103 @@ func (a *App) View() string {
 	}
 
 	if a.loading {
-		return loadingStyle.Render("Loading emails...\n\nPress 'q' to quit")
+		if a.reconnecting {
+			return loadingStyle.Render(a.spinner.View() + " Reconnecting...\n\nPress 'q' to quit")
+		}
+		return loadingStyle.Render(a.spinner.View() + " Loading emails..." + a.renderFetchProgress() + "\n\nPress 'q' to quit")
+	}
+
+	if a.showHelp {
+		return a.renderHelpOverlay()
 	}
 
-	if a.state == deleteConfirmView && a.emailToDelete != nil {
+	if a.state == deleteConfirmView && len(a.deleteUIDs) > 0 {
 		return a.renderDeleteConfirmation()
 	}
 
+	if a.state == emptyTrashConfirmView {
+		return a.renderEmptyTrashConfirmation()
+	}
+
+	if a.state == markAllReadConfirmView {
+		return a.renderMarkAllReadConfirmation()
+	}
+
 	switch a.state {
 	case listView:
 		view := a.list.View()
 		if len(a.emails) == 0 {
 			view = emptyStyle.Render("No emails found.\n\nPress 'q' to quit")
 		} else {
-			helpText := "↑/↓: navigate • enter: read • d: delete • /: search • r: refresh • q: quit"
+			helpText := helpLine(a.available(listShortcuts))
 			if a.loadingMore {
-				helpText = "Loading more emails... • " + helpText
+				helpText = a.spinner.View() + " Loading more emails..." + a.renderFetchProgress() + " • " + helpText
+			} else if a.syncing {
+				helpText = a.spinner.View() + " Syncing with the server..." + a.renderFetchProgress() + " • " + helpText
+			}
+			if a.quota != nil {
+				helpText = a.quota.String() + " • " + helpText
 			}
-			if a.deleteSuccess {
-				successMsg := successStyle.Render("✓ " + a.deleteSuccessMessage)
+			if a.showSuccess {
+				successMsg := successStyle.Render("✓ " + a.successMessage)
 				view += "\n" + successMsg
 			}
-			view += "\n" + helpStyle.Render(helpText)
+			view += "\n" + a.renderStatusBar()
+			if a.jumping {
+				view += "\n" + a.jumpInput.View()
+			} else if !a.options.hideHelp {
+				view += "\n" + helpStyle.Render(helpText)
+			}
+		}
+		return view
+
+	case folderPickerView:
+		view := a.folderPicker.View()
+		if a.moving {
+			view += "\n" + a.spinner.View() + " Moving..."
+		} else if !a.options.hideHelp {
+			view += "\n" + helpStyle.Render(helpLine(folderShortcuts))
+		}
+		return view
+
+	case composeView:
+		view := a.compose.view(a.options.smtp.from)
+		if !a.options.hideHelp {
+			view += "\n" + helpStyle.Render(helpLine(composeShortcuts))
 		}
 		return view
 
 	case emailView:
-		helpText := "↑/↓: scroll • d: delete • esc: back • q: quit"
-		if a.deleteSuccess {
-			successMsg := successStyle.Render("✓ " + a.deleteSuccessMessage)
-			return a.viewport.View() + "\n" + successMsg + "\n" + helpStyle.Render(helpText)
+		helpText := helpLine(a.available(emailShortcuts))
+		if a.loadingBody {
+			helpText = a.spinner.View() + " Loading email content... • " + helpText
+		}
+		view := a.viewport.View()
+		if a.showSuccess {
+			view += "\n" + successStyle.Render("✓ "+a.successMessage)
+		}
+		view += "\n" + a.renderStatusBar()
+		if !a.options.hideHelp {
+			view += "\n" + helpStyle.Render(helpText)
 		}
-		return a.viewport.View() + "\n" + helpStyle.Render(helpText)
+		return view
 	}
 
 	return ""
 }
 
+// renderFetchProgress renders "Loaded 23/50" with a bar while envelopes are
+// arriving, or nothing before the first one.
+func (a *App) renderFetchProgress() string {
+	p := a.fetchProgress
+	if p.expected == 0 {
+		return ""
+	}
+	a.progressBar.Width = 20
+	percent := float64(p.received) / float64(p.expected)
+	return fmt.Sprintf(" %s Loaded %d/%d", a.progressBar.ViewAs(percent), p.received, p.expected)
+}
+
 func (a *App) renderDeleteConfirmation() string {
 	if a.deletingEmail {
 		return loadingStyle.Render("Deleting email...\n\nPlease wait...")
@@ -464,18 +2394,64 @@ func (a *App) renderDeleteConfirmation() string {
 
 	var content strings.Builder
 
+	i := a.findEmail(a.deleteUIDs[0])
+	if len(a.deleteUIDs) > 1 || i < 0 {
+		content.WriteString(warningStyle.Render("🗑️  Delete Emails") + "\n\n")
+		content.WriteString(fmt.Sprintf("Are you sure you want to delete these %d emails?\n\n", len(a.deleteUIDs)))
+		content.WriteString("This will move them to Trash.\n\n")
+		content.WriteString(a.renderConfirmButtons())
+		return dialogStyle.Render(content.String())
+	}
+
+	email := a.emails[i]
 	content.WriteString(warningStyle.Render("🗑️  Delete Email") + "\n\n")
 	content.WriteString("Are you sure you want to delete this email?\n\n")
-	content.WriteString(emailInfoStyle.Render(fmt.Sprintf("Subject: %s", a.emailToDelete.Subject)) + "\n")
-	content.WriteString(emailInfoStyle.Render(fmt.Sprintf("From: %s", a.emailToDelete.From)) + "\n")
-	content.WriteString(emailInfoStyle.Render(fmt.Sprintf("Date: %s", a.emailToDelete.Date.Format("Jan 2, 2006 15:04"))) + "\n\n")
+	content.WriteString(emailInfoStyle.Render(fmt.Sprintf("Subject: %s", truncate(email.Subject, 60))) + "\n")
+	content.WriteString(emailInfoStyle.Render(fmt.Sprintf("From: %s", truncate(email.From, 60))) + "\n")
+	content.WriteString(emailInfoStyle.Render(fmt.Sprintf("Date: %s", displayTime(email.Date, a.options.location).Format("Jan 2, 2006 15:04"))) + "\n\n")
 
 	content.WriteString("This will move the email to Trash.\n\n")
+	content.WriteString(a.renderConfirmButtons())
+
+	return dialogStyle.Render(content.String())
+}
+
+func (a *App) renderEmptyTrashConfirmation() string {
+	if a.emptyingTrash {
+		return loadingStyle.Render("Emptying Trash...\n\nPlease wait...")
+	}
+
+	var content strings.Builder
+
+	content.WriteString(warningStyle.Render("🗑️  Empty Trash") + "\n\n")
+	content.WriteString("Are you sure you want to permanently delete every message in Trash?\n\n")
+	content.WriteString("This cannot be undone.\n\n")
+	content.WriteString(a.renderConfirmButtons())
+
+	return dialogStyle.Render(content.String())
+}
+
+func (a *App) renderMarkAllReadConfirmation() string {
+	if a.markingAllRead {
+		return loadingStyle.Render("Marking all emails as read...\n\nPlease wait...")
+	}
+
+	var content strings.Builder
+
+	content.WriteString(warningStyle.Render("📭 Mark All as Read") + "\n\n")
+	content.WriteString("Mark every message in the inbox as read, including ones not loaded yet?\n\n")
+	content.WriteString(a.renderConfirmButtons())
+
+	return dialogStyle.Render(content.String())
+}
+
+func (a *App) renderConfirmButtons() string {
+	var content strings.Builder
 
 	noButton := "[ No ]"
 	yesButton := "[ Yes ]"
 
-	if a.deleteConfirmIndex == 0 {
+	if a.confirmIndex == 0 {
 		noButton = confirmButtonSelectedStyle.Render("[ No ]")
 		yesButton = confirmButtonStyle.Render("[ Yes ]")
 	} else {
@@ -485,100 +2461,73 @@ func (a *App) renderDeleteConfirmation() string {
 
 	buttonsLine := lipgloss.JoinHorizontal(lipgloss.Center, noButton, "  ", yesButton)
 	content.WriteString(buttonsLine + "\n\n")
-	content.WriteString(helpStyle.Render("←/→: select • enter: confirm • esc: cancel"))
+	content.WriteString(helpStyle.Render(helpLine(confirmShortcuts)))
 
-	return dialogStyle.Render(content.String())
+	return content.String()
 }
 
-var (
-	helpStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("241")).
-			Padding(0, 1)
-	loadingStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("205")).
-			Bold(true).
-			Padding(1, 2)
-	errorStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("196")).
-			Bold(true).
-			Padding(1, 2)
-	emptyStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("243")).
-			Padding(1, 2)
-	emailViewStyle = lipgloss.NewStyle().
-			BorderStyle(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("238")).
-			Padding(1, 2)
-	subjectStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("205"))
-	fromStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("220"))
-	dateStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("242"))
-	bodyStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("252"))
-	successStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("46")).
-			Bold(true).
-			Padding(0, 1)
-	warningStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("208")).
-			Bold(true)
-	dialogStyle = lipgloss.NewStyle().
-			BorderStyle(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("208")).
-			Padding(2, 4).
-			MarginTop(2).
-			MarginLeft(4)
-	emailInfoStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("252"))
-	confirmButtonStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("241")).
-				BorderStyle(lipgloss.RoundedBorder()).
-				BorderForeground(lipgloss.Color("241")).
-				Padding(0, 1)
-	confirmButtonSelectedStyle = lipgloss.NewStyle().
-					Foreground(lipgloss.Color("15")).
-					Background(lipgloss.Color("196")).
-					BorderStyle(lipgloss.RoundedBorder()).
-					BorderForeground(lipgloss.Color("196")).
-					Padding(0, 1).
-					Bold(true)
-)
+var trashFolders = []string{"Trash", "INBOX.Trash", "Deleted Messages", "INBOX.Deleted Messages"}
 
-func moveEmailToTrash(imapClient *client.Client, uid uint32) (bool, string) {
-	seqSet := new(imap.SeqSet)
-	seqSet.AddNum(uid)
+// findTrashFolder selects the configured trash folder, or when none is
+// configured the one the server marks as \Trash, then the first candidate
+// the server lets us select. The folder is left selected.
+func findTrashFolder(imapClient *client.Client, configured string) (string, error) {
+	if configured != "" {
+		if _, err := imapClient.Select(configured, false); err != nil {
+			return "", fmt.Errorf("could not select trash folder %s: %w", configured, err)
+		}
+		return configured, nil
+	}
+	// Localized trash folders, like "Éléments supprimés", are only found this way
+	if trashFolder, err := specialUseMailbox(imapClient, imap.TrashAttr); err == nil && trashFolder != "" {
+		if _, err := imapClient.Select(trashFolder, false); err == nil {
+			return trashFolder, nil
+		}
+	}
+	for _, trashFolder := range trashFolders {
+		if _, err := imapClient.Select(trashFolder, false); err == nil {
+			return trashFolder, nil
+		}
+	}
+	return "", fmt.Errorf("could not find a Trash folder")
+}
 
-	trashFolders := []string{"Trash", "INBOX.Trash", "Deleted Messages", "INBOX.Deleted Messages"}
+// moveEmailsToTrash moves the emails from inbox to the trash folder, leaving
+// inbox selected. If no trash folder was configured and none can be found,
+// the emails are deleted permanently.
+func moveEmailsToTrash(imapClient *client.Client, inbox string, uids []uint32, configuredTrash string) (bool, string) {
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(uids...)
+	what := "Email"
+	if len(uids) > 1 {
+		what = fmt.Sprintf("%d emails", len(uids))
+	}
 
-	for _, trashFolder := range trashFolders {
-		_, err := imapClient.Select(trashFolder, false)
+	trashFolder, err := findTrashFolder(imapClient, configuredTrash)
+	if err == nil {
+		_, err = imapClient.Select(inbox, false)
 		if err == nil {
-			_, err = imapClient.Select("INBOX", false)
-			if err != nil {
-				continue
-			}
-
-			err = imapClient.UidMove(seqSet, trashFolder)
+			err = moveByUID(imapClient, uids, trashFolder)
 			if err == nil {
-				return true, fmt.Sprintf("Email moved to %s", trashFolder)
+				return true, fmt.Sprintf("%s moved to %s", what, trashFolder)
 			}
 		}
 	}
+	if configuredTrash != "" {
+		imapClient.Select(inbox, false)
+		return false, fmt.Sprintf("Failed to move %s to %s: %v", strings.ToLower(what), configuredTrash, err)
+	}
 
-	_, err := imapClient.Select("INBOX", false)
+	_, err = imapClient.Select(inbox, false)
 	if err != nil {
-		return false, fmt.Sprintf("Failed to select INBOX: %v", err)
+		return false, fmt.Sprintf("Failed to select %s: %v", inbox, err)
 	}
 
 	item := imap.FormatFlagsOp(imap.AddFlags, true)
 	flags := []interface{}{imap.DeletedFlag}
 	err = imapClient.UidStore(seqSet, item, flags, nil)
 	if err != nil {
-		return false, fmt.Sprintf("Failed to mark email as deleted: %v", err)
+		return false, fmt.Sprintf("Failed to mark %s as deleted: %v", strings.ToLower(what), err)
 	}
 
 	err = imapClient.Expunge(nil)
@@ -586,7 +2535,110 @@ func moveEmailToTrash(imapClient *client.Client, uid uint32) (bool, string) {
 		return false, fmt.Sprintf("Failed to expunge: %v", err)
 	}
 
-	return true, "Email deleted permanently"
+	return true, what + " deleted permanently"
+}
+
+// emptyTrashFolder permanently deletes everything in the trash folder and
+// returns its name and the number of messages purged. inbox is selected
+// again afterwards.
+func emptyTrashFolder(imapClient *client.Client, inbox, configuredTrash string) (string, uint32, error) {
+	trashFolder, err := findTrashFolder(imapClient, configuredTrash)
+	if err != nil {
+		imapClient.Select(inbox, false)
+		return "", 0, err
+	}
+	defer imapClient.Select(inbox, false)
+
+	mailbox := imapClient.Mailbox()
+	if mailbox == nil || mailbox.Messages == 0 {
+		return trashFolder, 0, nil
+	}
+
+	seqSet := new(imap.SeqSet)
+	seqSet.AddRange(1, 0)
+	item := imap.FormatFlagsOp(imap.AddFlags, true)
+	flags := []interface{}{imap.DeletedFlag}
+	if err := imapClient.Store(seqSet, item, flags, nil); err != nil {
+		return trashFolder, 0, fmt.Errorf("failed to mark messages in %s as deleted: %w", trashFolder, err)
+	}
+	if err := imapClient.Expunge(nil); err != nil {
+		return trashFolder, 0, fmt.Errorf("failed to expunge %s: %w", trashFolder, err)
+	}
+
+	return trashFolder, mailbox.Messages, nil
+}
+
+// searchUnseen returns the UIDs of the unread messages in the selected
+// mailbox.
+func searchUnseen(imapClient *client.Client) ([]uint32, error) {
+	criteria := imap.NewSearchCriteria()
+	criteria.WithoutFlags = []string{imap.SeenFlag}
+	unseen, err := imapClient.UidSearch(criteria)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search unread emails: %w", err)
+	}
+	return unseen, nil
+}
+
+// markMailboxRead sets \Seen on every message in the selected mailbox with a
+// single UID STORE and returns how many messages were unread before.
+func markMailboxRead(imapClient *client.Client) (int, error) {
+	unseen, err := searchUnseen(imapClient)
+	if err != nil {
+		return 0, err
+	}
+	if len(unseen) == 0 {
+		return 0, nil
+	}
+
+	seqSet := new(imap.SeqSet)
+	seqSet.AddRange(1, 0)
+	item := imap.FormatFlagsOp(imap.AddFlags, true)
+	if err := imapClient.UidStore(seqSet, item, []interface{}{imap.SeenFlag}, nil); err != nil {
+		return 0, fmt.Errorf("failed to mark emails as read: %w", err)
+	}
+	return len(unseen), nil
+}
+
+// envelopeSender returns the display name and bare address of the first
+// mailbox in addrs. Group markers (RFC 3501 sends these with no host) and
+// empty entries are skipped; if only a group is present its name is used.
+// The name is empty when the mailbox has no display name.
+func envelopeSender(addrs []*imap.Address) (name, address string) {
+	group := ""
+	for _, addr := range addrs {
+		if addr == nil {
+			continue
+		}
+		mailbox := strings.TrimSpace(addr.MailboxName)
+		host := strings.TrimSpace(addr.HostName)
+		if host == "" {
+			// Start of a group, or the end marker when the name is empty too
+			if group == "" && mailbox != "" {
+				group = decodeHeaderWords(mailbox)
+			}
+			continue
+		}
+
+		if mailbox != "" {
+			address = mailbox + "@" + host
+		}
+		name = strings.Trim(decodeHeaderWords(addr.PersonalName), " \t\"'")
+		if name != "" || address != "" {
+			return name, address
+		}
+	}
+	return group, ""
+}
+
+// decodeHeaderWords decodes RFC 2047 encoded words, keeping the original
+// text when it cannot be decoded.
+func decodeHeaderWords(s string) string {
+	decoded, err := new(mime.WordDecoder).DecodeHeader(s)
+	if err != nil {
+		return strings.TrimSpace(s)
+	}
+	return strings.TrimSpace(decoded)
 }
 
 func cleanupWhitespace(text string) string {
@@ -604,8 +2656,14 @@ func cleanupWhitespace(text string) string {
 	return text
 }
 
-func fetchEmails(imapClient *client.Client, page int, perPage int) ([]Email, uint32, error) {
-	mailbox, err := imapClient.Select("INBOX", false)
+// fetchEmails fetches, newest first, the envelopes of up to count messages of
+// mailboxName whose UID is below before, skipping the newest skip of them. A
+// before of 0 starts from the newest message. With readOnly the mailbox is
+// opened with EXAMINE, which leaves flags such as \Recent alone. Paging by UID keeps pages
+// stable when mail arrives or is expunged between fetches. If progress is not
+// nil it is called as each envelope arrives.
+func fetchEmails(imapClient *client.Client, mailboxName string, readOnly bool, before uint32, skip, count int, progress func(received, expected int)) ([]Email, uint32, error) {
+	mailbox, err := imapClient.Select(mailboxName, readOnly)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -615,62 +2673,85 @@ func fetchEmails(imapClient *client.Client, page int, perPage int) ([]Email, uin
 	}
 
 	totalMessages := mailbox.Messages
-	end := totalMessages - uint32((page-1)*perPage)
-	start := end - uint32(perPage) + 1
-
-	if start < 1 {
-		start = 1
+	if before == 1 {
+		return []Email{}, totalMessages, nil
 	}
-
-	if end > totalMessages {
-		end = totalMessages
+	criteria := imap.NewSearchCriteria()
+	if before > 1 {
+		criteria.Uid = new(imap.SeqSet)
+		criteria.Uid.AddRange(1, before-1)
+	}
+	uids, err := imapClient.UidSearch(criteria)
+	if err != nil {
+		return nil, totalMessages, fmt.Errorf("failed to search messages: %w", err)
+	}
+	uids = uidPage(uids, before, skip, count)
+	if len(uids) == 0 {
+		return []Email{}, totalMessages, nil
 	}
 
 	seqSet := new(imap.SeqSet)
-	seqSet.AddRange(start, end)
+	seqSet.AddNum(uids...)
 
 	items := []imap.FetchItem{
 		imap.FetchEnvelope,
+		imap.FetchInternalDate,
 		imap.FetchFlags,
 		imap.FetchUid,
+		imap.FetchRFC822Size,
+		referencesSection.FetchItem(),
 	}
 
 	messages := make(chan *imap.Message, 10)
+	done := make(chan error, 1)
 	go func() {
-		if err := imapClient.Fetch(seqSet, items, messages); err != nil {
-			log.Printf("Error fetching messages: %v", err)
-		}
+		done <- imapClient.UidFetch(seqSet, items, messages)
 	}()
 
+	expected := len(uids)
+	received := 0
+
 	var emails []Email
 	for msg := range messages {
+		received++
+		if progress != nil {
+			progress(received, expected)
+		}
 		if msg.Envelope == nil {
 			continue
 		}
 
-		from := "Unknown"
-		if len(msg.Envelope.From) > 0 && msg.Envelope.From[0] != nil {
-			if msg.Envelope.From[0].PersonalName != "" {
-				from = msg.Envelope.From[0].PersonalName
-			} else {
-				from = msg.Envelope.From[0].MailboxName + "@" + msg.Envelope.From[0].HostName
-			}
+		fromName, fromAddress := envelopeSender(msg.Envelope.From)
+		from := cmp.Or(fromName, fromAddress, "Unknown sender")
+		toName, toAddress := envelopeSender(msg.Envelope.To)
+		to := cmp.Or(toName, toAddress)
+
+		email := Email{
+			UID:         msg.Uid,
+			From:        from,
+			FromName:    fromName,
+			FromAddress: fromAddress,
+			To:          to,
+			Date:        msg.Envelope.Date,
+			Size:        msg.Size,
+			MessageID:   msg.Envelope.MessageId,
+			InReplyTo:   msg.Envelope.InReplyTo,
+			References:  parseReferences(msg.GetBody(referencesSection)),
 		}
-
-		to := ""
-		if len(msg.Envelope.To) > 0 && msg.Envelope.To[0] != nil {
-			if msg.Envelope.To[0].PersonalName != "" {
-				to = msg.Envelope.To[0].PersonalName
-			} else {
-				to = msg.Envelope.To[0].MailboxName + "@" + msg.Envelope.To[0].HostName
-			}
+		if email.Date.IsZero() {
+			// No usable Date header, use when the server received it
+			email.Date = msg.InternalDate
 		}
-
-		seen := false
 		for _, flag := range msg.Flags {
-			if flag == imap.SeenFlag {
-				seen = true
-				break
+			switch flag {
+			case imap.SeenFlag:
+				email.Seen = true
+			case imap.FlaggedFlag:
+				email.Flagged = true
+			case imap.AnsweredFlag:
+				email.Answered = true
+			case imap.DraftFlag:
+				email.Draft = true
 			}
 		}
 
@@ -679,54 +2760,120 @@ func fetchEmails(imapClient *client.Client, page int, perPage int) ([]Email, uin
 			subject = "(No Subject)"
 		}
 
-		emails = append(emails, Email{
-			UID:     msg.Uid,
-			Subject: subject,
-			From:    from,
-			To:      to,
-			Date:    msg.Envelope.Date,
-			Seen:    seen,
-		})
+		email.Subject = subject
+		emails = append(emails, email)
+	}
+	if err := <-done; err != nil {
+		logger.Printf("Error fetching messages: %v", err)
+		return nil, totalMessages, fmt.Errorf("failed to fetch messages: %w", err)
 	}
 
-	sort.Slice(emails, func(i, j int) bool {
-		return emails[i].Date.After(emails[j].Date)
-	})
+	sortEmails(emails, sortDateDesc)
 
 	return emails, totalMessages, nil
 }
 
-func fetchEmailBodyParsed(imapClient *client.Client, uid uint32) (Email, error) {
-	seqSet := new(imap.SeqSet)
-	seqSet.AddNum(uid)
-	section := &imap.BodySectionName{}
-	items := []imap.FetchItem{section.FetchItem()}
-	messages := make(chan *imap.Message, 1)
-	go func() {
-		if err := imapClient.UidFetch(seqSet, items, messages); err != nil {
-			log.Printf("Error fetching message body: %v", err)
+func sortEmails(emails []Email, mode sortMode) {
+	sort.SliceStable(emails, func(i, j int) bool {
+		switch mode {
+		case sortDateAsc:
+			return emails[i].Date.Before(emails[j].Date)
+		case sortSender:
+			return strings.ToLower(emails[i].From) < strings.ToLower(emails[j].From)
+		case sortSubject:
+			return strings.ToLower(emails[i].Subject) < strings.ToLower(emails[j].Subject)
+		default:
+			return emails[i].Date.After(emails[j].Date)
 		}
-	}()
-	var email Email
-	for msg := range messages {
-		for _, value := range msg.Body {
-			if reader, ok := value.(io.Reader); ok {
-				rawBody, err := io.ReadAll(reader)
-				if err != nil {
-					return email, err
-				}
-				parsedEmail, err := parseEmailBody(string(rawBody))
-				if err != nil {
-					email.Body = string(rawBody)
-					email.ContentType = "text/plain"
-				} else {
-					email = parsedEmail
-				}
-				return email, nil
+	})
+}
+
+// uidPage returns up to count of uids, newest first, after skipping the
+// newest skip. UIDs not below before are left out; a before of 0 keeps all.
+func uidPage(uids []uint32, before uint32, skip, count int) []uint32 {
+	if skip < 0 || count < 1 {
+		return nil
+	}
+	sorted := make([]uint32, 0, len(uids))
+	for _, uid := range uids {
+		if before == 0 || uid < before {
+			sorted = append(sorted, uid)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] > sorted[j] })
+	if skip >= len(sorted) {
+		return nil
+	}
+	return sorted[skip:min(len(sorted), skip+count)]
+}
+
+// fetchEmailBodyParsed fetches and parses the full message with the given UID.
+// With peek set the message is fetched without marking it as read.
+func fetchEmailBodyParsed(imapClient *client.Client, uid uint32, peek bool) (Email, error) {
+	bodies, err := fetchEmailBodies(imapClient, []uint32{uid}, peek)
+	if err != nil {
+		return Email{}, fmt.Errorf("failed to fetch email %d: %w", uid, err)
+	}
+	email, ok := bodies[uid]
+	if !ok {
+		return Email{}, fmt.Errorf("could not load email body")
+	}
+	return email, nil
+}
+
+// maxBodyBatch bounds how many full messages one UID FETCH asks for, so
+// that a single command never holds the connection for too long.
+const maxBodyBatch = 10
+
+// fetchEmailBodies fetches and parses the full messages with the given UIDs,
+// with one UID FETCH per maxBodyBatch of them instead of one per message.
+// Messages the server does not return are missing from the result.
+func fetchEmailBodies(imapClient *client.Client, uids []uint32, peek bool) (map[uint32]Email, error) {
+	bodies := make(map[uint32]Email, len(uids))
+	section := &imap.BodySectionName{Peek: peek}
+	items := []imap.FetchItem{imap.FetchUid, section.FetchItem()}
+	for batch := range slices.Chunk(uids, maxBodyBatch) {
+		seqSet := new(imap.SeqSet)
+		seqSet.AddNum(batch...)
+		messages := make(chan *imap.Message, len(batch))
+		done := make(chan error, 1)
+		go func() {
+			done <- imapClient.UidFetch(seqSet, items, messages)
+		}()
+		var readErr error
+		for msg := range messages {
+			literal := msg.GetBody(section)
+			if literal == nil || readErr != nil {
+				continue
+			}
+			rawBody, err := io.ReadAll(literal)
+			if err != nil {
+				readErr = err
+				continue
 			}
+			bodies[msg.Uid] = parseFetchedBody(rawBody)
 		}
+		if err := <-done; err != nil {
+			logger.Printf("Error fetching message bodies: %v", err)
+			return bodies, err
+		}
+		if readErr != nil {
+			return bodies, readErr
+		}
+	}
+	return bodies, nil
+}
+
+// parseFetchedBody parses a full message, keeping it as plain text when it
+// cannot be parsed.
+func parseFetchedBody(rawBody []byte) Email {
+	email, err := parseEmailBody(string(rawBody))
+	if err != nil {
+		email = Email{Body: string(rawBody), ContentType: "text/plain"}
 	}
-	return email, fmt.Errorf("could not load email body")
+	email.Raw = string(rawBody)
+	email.RawHeader, _, _ = strings.Cut(strings.ReplaceAll(email.Raw, "\r\n", "\n"), "\n\n")
+	return email
 }
 
 func parseEmailBody(rawBody string) (Email, error) {
@@ -742,29 +2889,7 @@ func parseEmailBody(rawBody string) (Email, error) {
 	}
 	email.ContentType = mediaType
 	if strings.HasPrefix(mediaType, "multipart/") {
-		boundary := params["boundary"]
-		reader := multipart.NewReader(msg.Body, boundary)
-		for {
-			part, err := reader.NextPart()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				continue
-			}
-			partBody, err := io.ReadAll(part)
-			if err != nil {
-				continue
-			}
-			partContentType := part.Header.Get("Content-Type")
-			partMediaType, _, _ := mime.ParseMediaType(partContentType)
-			switch {
-			case strings.HasPrefix(partMediaType, "text/html"):
-				email.HTMLBody = string(partBody)
-			case strings.HasPrefix(partMediaType, "text/plain"):
-				email.TextBody = string(partBody)
-			}
-		}
+		parseParts(&email, msg.Body, params["boundary"])
 	} else {
 		body, err := io.ReadAll(msg.Body)
 		if err != nil {
@@ -776,6 +2901,7 @@ func parseEmailBody(rawBody string) (Email, error) {
 			email.TextBody = string(body)
 		}
 	}
+	email.HTMLBody = replaceInlineImages(email.HTMLBody, email.Attachments)
 	if email.TextBody != "" {
 		email.Body = email.TextBody
 	} else {
@@ -786,28 +2912,176 @@ func parseEmailBody(rawBody string) (Email, error) {
 	return email, nil
 }
 
-func formatEmailForView(email Email) string {
+// parseParts reads the parts of a multipart body into email, descending into
+// nested multiparts such as multipart/related inside multipart/alternative.
+// The first text and HTML parts found are the bodies; files are attachments.
+func parseParts(email *Email, body io.Reader, boundary string) {
+	reader := multipart.NewReader(body, boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			return
+		}
+		partMediaType, partParams, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if strings.HasPrefix(partMediaType, "multipart/") {
+			parseParts(email, part, partParams["boundary"])
+			continue
+		}
+		partBody, err := io.ReadAll(part)
+		if err != nil {
+			continue
+		}
+		if isAttachment(part.Header) {
+			name := attachmentFileName(part.Header)
+			if name == "" {
+				name = "attachment"
+			}
+			email.Attachments = append(email.Attachments, attachment{
+				Name:        name,
+				ContentType: partMediaType,
+				ContentID:   strings.Trim(part.Header.Get("Content-ID"), "<> "),
+			})
+			continue
+		}
+		switch {
+		case strings.HasPrefix(partMediaType, "text/html") && email.HTMLBody == "":
+			email.HTMLBody = string(partBody)
+		case strings.HasPrefix(partMediaType, "text/plain") && email.TextBody == "":
+			email.TextBody = string(partBody)
+		}
+	}
+}
+
+// emailMetadata describes the IMAP flags and size of an email, for example
+// "Flags: Seen, Answered • 12.4 KB".
+func emailMetadata(email Email) string {
+	var flags []string
+	if email.Seen {
+		flags = append(flags, "Seen")
+	}
+	if email.Flagged {
+		flags = append(flags, "Flagged")
+	}
+	if email.Answered {
+		flags = append(flags, "Answered")
+	}
+	if email.Draft {
+		flags = append(flags, "Draft")
+	}
+	line := "Flags: none"
+	if len(flags) > 0 {
+		line = "Flags: " + strings.Join(flags, ", ")
+	}
+	if email.Size > 0 {
+		line += " • " + formatBytes(uint64(email.Size))
+	}
+	return line
+}
+
+// renderEmail returns what the viewport shows for the open email: its raw
+// headers when toggled with H, the formatted email otherwise.
+func (a *App) renderEmail(email Email) string {
+	if a.showHeaders {
+		return formatRawHeaders(email, a.wrapWidth())
+	}
+	return formatEmailForView(email, a.wrapWidth(), emailViewOptions{markdown: !a.plainText, showQuoted: a.showQuoted, location: a.options.location})
+}
+
+// formatRawHeaders lists every header of the raw message as received, in
+// order and without decoding, wrapped at width.
+func formatRawHeaders(email Email, width int) string {
+	var content strings.Builder
+	content.WriteString(subjectStyle.Render("📋 Headers of ") + subjectStyle.Render(email.Subject) + "\n\n")
+	if email.RawHeader == "" {
+		content.WriteString(loadingStyle.Render("Loading email content..."))
+		return content.String()
+	}
+
+	header := strings.TrimRight(strings.ReplaceAll(email.RawHeader, "\r\n", "\n"), "\n")
+	wrap := lipgloss.NewStyle().Width(width)
+	for _, line := range strings.Split(header, "\n") {
+		// Folded continuation lines keep their indentation
+		if name, value, ok := strings.Cut(line, ":"); ok && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			content.WriteString(wrap.Render(fromStyle.Render(name+":")+value) + "\n")
+		} else {
+			content.WriteString(wrap.Render(line) + "\n")
+		}
+	}
+	content.WriteString("\n" + helpStyle.Render("Press H to show the email again"))
+	return content.String()
+}
+
+// plainURL matches http and https URLs in plain text, leaving out trailing
+// punctuation such as the period ending a sentence.
+var plainURL = regexp.MustCompile(`https?://[^\s<>"]*[^\s<>".,;:!?'()\[\]]`)
+
+// styleURLs renders text with bodyStyle and the URLs in it with urlStyle.
+// Lines are styled one by one so that lipgloss does not pad the segments.
+func styleURLs(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		var styled strings.Builder
+		last := 0
+		for _, match := range plainURL.FindAllStringIndex(line, -1) {
+			styled.WriteString(bodyStyle.Render(line[last:match[0]]))
+			styled.WriteString(urlStyle.Render(line[match[0]:match[1]]))
+			last = match[1]
+		}
+		styled.WriteString(bodyStyle.Render(line[last:]))
+		lines[i] = styled.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// emailViewOptions are the choices of how the open email is shown.
+type emailViewOptions struct {
+	markdown   bool           // render the body with glamour, or only clean up its whitespace
+	showQuoted bool           // show the quoted reply chain instead of collapsing it
+	location   *time.Location // zone the date is shown in, nil for the local one
+}
+
+// formatEmailForView renders email for the viewport, wrapping the body and
+// the separator at width columns.
+func formatEmailForView(email Email, width int, opts emailViewOptions) string {
 	var content strings.Builder
 	content.WriteString(subjectStyle.Render("📧 ") + subjectStyle.Render(email.Subject) + "\n\n")
 	content.WriteString(fromStyle.Render("From: ") + email.From + "\n")
 	if email.To != "" {
 		content.WriteString(fromStyle.Render("To: ") + email.To + "\n")
 	}
-	content.WriteString(dateStyle.Render("Date: ") + email.Date.Format("Monday, January 2, 2006 at 3:04 PM") + "\n\n")
-	content.WriteString(strings.Repeat("─", 60) + "\n\n")
-	if email.Body != "" {
-		body := strings.TrimSpace(email.Body)
-		body = cleanupWhitespace(body)
+	content.WriteString(dateStyle.Render("Date: ") + displayTime(email.Date, opts.location).Format("Monday, January 2, 2006 at 3:04 PM MST") + "\n")
+	content.WriteString(dateStyle.Render(emailMetadata(email)) + "\n")
+	if len(email.Attachments) > 0 {
+		names := make([]string, len(email.Attachments))
+		for i, a := range email.Attachments {
+			names[i] = a.Name
+		}
+		content.WriteString(fromStyle.Render("Attachments: ") + strings.Join(names, ", ") + "\n")
+	}
+	content.WriteString("\n")
+	content.WriteString(strings.Repeat("─", width) + "\n\n")
+	body := cleanupWhitespace(email.Body)
+	visible, quoted := splitQuoted(body)
+	if !opts.showQuoted {
+		body = visible
+	}
+	if email.Body != "" && !opts.markdown {
+		content.WriteString(styleURLs(lipgloss.NewStyle().Width(width).Render(body)))
+	} else if email.Body != "" {
 		r, err := glamour.NewTermRenderer(
-			glamour.WithAutoStyle(),
-			glamour.WithWordWrap(80),
+			glamourStyle(),
+			glamour.WithWordWrap(width),
 		)
 		if err != nil {
-			content.WriteString(bodyStyle.Render(body))
+			content.WriteString(styleURLs(body))
 		} else {
+			// glamour styles links itself
 			rendered, err := r.Render(body)
 			if err != nil {
-				content.WriteString(bodyStyle.Render(body))
+				content.WriteString(styleURLs(body))
 			} else {
 				rendered = cleanupWhitespace(rendered)
 				rendered = regexp.MustCompile(`\n{3,}\n`).ReplaceAllString(rendered, "\n\n```\n")
@@ -818,16 +3092,60 @@ func formatEmailForView(email Email) string {
 	} else {
 		content.WriteString(loadingStyle.Render("Loading email content..."))
 	}
+	if quoted != "" && opts.showQuoted {
+		content.WriteString("\n\n" + helpStyle.Render("[-] hide quoted text (z)"))
+	} else if quoted != "" {
+		content.WriteString("\n\n" + helpStyle.Render("[+] show quoted text (z)"))
+	}
 	return content.String()
 }
 
-func connectToServer(username, password, host, port string) (*client.Client, error) {
-	c, err := client.DialTLS(fmt.Sprintf("%s:%s", host, port), nil)
+func connectToServer(config imapConfig) (*client.Client, error) {
+	addr := net.JoinHostPort(config.host, config.port)
+	dialer, err := newDialer(config.proxy, config.dialTimeout)
 	if err != nil {
 		return nil, err
 	}
-	if err := c.Login(username, password); err != nil {
-		return nil, err
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.insecure,
+		ServerName:         config.host,
+	}
+	// go-imap only bounds the greeting for a plain net.Dialer, so the TLS
+	// handshake and the greeting are bounded here for the proxy too
+	conn, err := dialTLS(dialer, addr, tlsConfig, config.dialTimeout)
+	if err != nil {
+		return nil, wrapTimeout(err, "connecting to "+addr, config.dialTimeout)
+	}
+	c, err := client.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, wrapTimeout(err, "connecting to "+addr, config.dialTimeout)
+	}
+	conn.SetDeadline(time.Time{})
+	c.Timeout = config.commandTimeout
+	// With a command timeout the reader goroutine reports expired deadlines
+	// while idle; keep that noise off the TUI, reconnecting handles it.
+	c.ErrorLog = logger
+	logger.Printf("IMAP: connected to %s", addr)
+	if err := c.Login(config.username, config.password); err != nil {
+		c.Terminate()
+		logger.Printf("IMAP: login as %s failed: %v", config.username, err)
+		return nil, wrapTimeout(err, "logging in", config.commandTimeout)
+	}
+	logger.Printf("IMAP: logged in as %s", config.username)
+	// Trace the protocol only after LOGIN so the password stays out of it
+	if logOutput != nil {
+		c.SetDebug(logOutput)
 	}
 	return c, nil
 }
+
+// wrapTimeout turns a network timeout into a readable error while keeping the
+// original error in the chain.
+func wrapTimeout(err error, op string, timeout time.Duration) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Errorf("%s timed out after %s: %w", op, timeout, err)
+	}
+	return err
+}
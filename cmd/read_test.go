@@ -0,0 +1,1081 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/backend/memory"
+	"github.com/emersion/go-imap/server"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("no zone data for %s: %v", name, err)
+	}
+	return loc
+}
+
+func TestLoadLocation(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "", wantNil: true},
+		{name: "UTC"},
+		{name: "Europe/Paris"},
+		{name: "Mars/Olympus_Mons", wantNil: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		loc, err := loadLocation(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("loadLocation(%q) error = %v, want an error: %v", tt.name, err, tt.wantErr)
+		}
+		if (loc == nil) != tt.wantNil {
+			t.Errorf("loadLocation(%q) = %v", tt.name, loc)
+		}
+	}
+}
+
+func TestDatesAcrossZones(t *testing.T) {
+	// Sent at 23:30 in New York, which is already the next day elsewhere
+	sent := time.Date(2026, 3, 10, 23, 30, 0, 0, mustLoadLocation(t, "America/New_York"))
+	tests := []struct {
+		zone     string
+		wantList string
+		wantView string
+	}{
+		{zone: "UTC", wantList: "Mar 11, 03:30", wantView: "Wednesday, March 11, 2026 at 3:30 AM UTC"},
+		{zone: "Asia/Tokyo", wantList: "Mar 11, 12:30", wantView: "Wednesday, March 11, 2026 at 12:30 PM JST"},
+		{zone: "America/Los_Angeles", wantList: "Mar 10, 20:30", wantView: "Tuesday, March 10, 2026 at 8:30 PM PDT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.zone, func(t *testing.T) {
+			loc := mustLoadLocation(t, tt.zone)
+			if got := displayTime(sent, loc).Format("Jan 2, 15:04"); got != tt.wantList {
+				t.Errorf("displayTime = %s, want %s", got, tt.wantList)
+			}
+
+			email := Email{From: "Alice", Subject: "Late", Date: sent, location: loc, absoluteDate: true}
+			if got := email.Description(); !strings.Contains(got, tt.wantList) {
+				t.Errorf("Description() = %q, want the date %s", got, tt.wantList)
+			}
+			view := formatEmailForView(email, 80, emailViewOptions{location: loc})
+			if !strings.Contains(view, tt.wantView) {
+				t.Errorf("the email view does not show %q:\n%s", tt.wantView, view)
+			}
+		})
+	}
+}
+
+func TestRelativeDateUsesTheDisplayZone(t *testing.T) {
+	tokyo := mustLoadLocation(t, "Asia/Tokyo")
+	// 01:00 in Tokyo is still the previous day in UTC
+	now := time.Date(2026, 3, 11, 1, 0, 0, 0, tokyo)
+	tests := []struct {
+		name string
+		t    time.Time
+		want string
+	}{
+		{name: "minutes", t: now.Add(-5 * time.Minute), want: "5m ago"},
+		{name: "before midnight in Tokyo", t: time.Date(2026, 3, 10, 14, 0, 0, 0, time.UTC), want: "Yesterday"},
+		{name: "after midnight in Tokyo", t: time.Date(2026, 3, 10, 15, 30, 0, 0, time.UTC), want: "30m ago"},
+		{name: "three days", t: now.Add(-72 * time.Hour), want: "3 days ago"},
+		{name: "older", t: time.Date(2026, 1, 2, 3, 4, 0, 0, tokyo), want: "Jan 2, 03:04"},
+		{name: "last year", t: time.Date(2025, 1, 2, 3, 4, 0, 0, tokyo), want: "Jan 2, 2025"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := relativeDate(displayTime(tt.t, tokyo), now); got != tt.want {
+				t.Errorf("relativeDate = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    uint64
+		want string
+	}{
+		{0, "0 B"},
+		{512, "512 B"},
+		{1024, "1.0 KB"},
+		{12700, "12 KB"},
+		{1536 * 1024, "1.5 MB"},
+		{5 << 30, "5.0 GB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.n); got != tt.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestEmailMetadata(t *testing.T) {
+	tests := []struct {
+		name  string
+		email Email
+		want  string
+	}{
+		{name: "no flags", email: Email{}, want: "Flags: none"},
+		{name: "small", email: Email{Seen: true, Size: 800}, want: "Flags: Seen • 800 B"},
+		{name: "kilobytes", email: Email{Seen: true, Answered: true, Size: 2560}, want: "Flags: Seen, Answered • 2.5 KB"},
+		{name: "megabytes", email: Email{Flagged: true, Size: 3 << 20}, want: "Flags: Flagged • 3.0 MB"},
+	}
+	for _, tt := range tests {
+		if got := emailMetadata(tt.email); got != tt.want {
+			t.Errorf("%s: emailMetadata = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExportEmail(t *testing.T) {
+	tests := []struct {
+		name      string
+		email     Email
+		wantToast string
+	}{
+		{name: "still loading", email: Email{UID: 7, Subject: "Hello"}, wantToast: "The email is still loading, try again in a moment"},
+		{name: "loaded", email: Email{UID: 7, Subject: "Hello", Raw: "Subject: Hello\r\n\r\nHi\r\n"}, wantToast: "Saved to "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewApp(imapConfig{inbox: "INBOX"}, readOptions{exportDir: t.TempDir()})
+			runCmd(t, a, a.exportEmail(tt.email))
+			if a.err != nil {
+				t.Fatalf("export showed an error: %v", a.err)
+			}
+			if !strings.HasPrefix(a.successMessage, tt.wantToast) {
+				t.Errorf("toast = %q, want %q", a.successMessage, tt.wantToast)
+			}
+		})
+	}
+}
+
+func TestJumpToUsesTheVisibleList(t *testing.T) {
+	emails := []Email{
+		{UID: 1, Subject: "One", Seen: true},
+		{UID: 2, Subject: "Two"},
+		{UID: 3, Subject: "Three", Seen: true},
+		{UID: 4, Subject: "Four"},
+		{UID: 5, Subject: "Five"},
+	}
+	tests := []struct {
+		input      string
+		unreadOnly bool
+		want       uint32
+	}{
+		{input: "2", want: 2},
+		{input: "p2", want: 3},
+		{input: "1", unreadOnly: true, want: 2},
+		{input: "2", unreadOnly: true, want: 4},
+		{input: "3", unreadOnly: true, want: 5},
+		{input: "9", unreadOnly: true, want: 5},
+		{input: "p1", unreadOnly: true, want: 2},
+		{input: "p2", unreadOnly: true, want: 4},
+		{input: "p3", unreadOnly: true, want: 5},
+	}
+	for _, tt := range tests {
+		a := NewApp(imapConfig{inbox: "INBOX"}, readOptions{perPage: 2})
+		a.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+		a.emails = slices.Clone(emails)
+		a.unreadOnly = tt.unreadOnly
+		a.updateEmailList()
+		if cmd := a.jumpTo(tt.input); cmd != nil {
+			t.Fatalf("jumpTo(%q) loads more with every email loaded", tt.input)
+		}
+		if got, _ := a.selectedUID(); got != tt.want {
+			t.Errorf("jumpTo(%q) with unread only %v selected UID %d, want %d", tt.input, tt.unreadOnly, got, tt.want)
+		}
+	}
+}
+
+func TestJumpToLoadsMorePages(t *testing.T) {
+	c, _ := newMockIMAP(t,
+		testMessage("Two", "text/plain", "Hi\r\n"),
+		testMessage("Three", "text/plain", "Hi\r\n"),
+		testMessage("Four", "text/plain", "Hi\r\n"),
+	)
+	a := newTestApp(t, c, readOptions{perPage: 2})
+	if got := a.listedEmails(); got != 2 {
+		t.Fatalf("first page lists %d emails, want 2", got)
+	}
+	runCmd(t, a, a.jumpTo("4"))
+	if got := a.listedEmails(); got != 4 {
+		t.Fatalf("%d emails listed after the jump, want 4", got)
+	}
+	if got := a.list.Index(); got != 3 {
+		t.Errorf("selected index %d, want 3", got)
+	}
+}
+
+// timeoutError is a net.Error for an expired deadline.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestWrapTimeout(t *testing.T) {
+	refused := errors.New("connection refused")
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "timeout", err: timeoutError{}, want: "fetching emails timed out after 5s: i/o timeout"},
+		{name: "wrapped timeout", err: fmt.Errorf("read: %w", timeoutError{}), want: "fetching emails timed out after 5s: read: i/o timeout"},
+		{name: "other error", err: refused, want: "connection refused"},
+	}
+	for _, tt := range tests {
+		got := wrapTimeout(tt.err, "fetching emails", 5*time.Second)
+		if got.Error() != tt.want {
+			t.Errorf("%s: wrapTimeout = %q, want %q", tt.name, got, tt.want)
+		}
+		if !errors.Is(got, tt.err) {
+			t.Errorf("%s: the original error is not kept", tt.name)
+		}
+	}
+	if wrapTimeout(nil, "fetching emails", time.Second) != nil {
+		t.Error("wrapTimeout(nil) is not nil")
+	}
+}
+
+func TestConnectToServerTimeouts(t *testing.T) {
+	tests := []struct {
+		name string
+		// serve handles one connection to the server
+		serve func(net.Conn)
+	}{
+		{name: "no TLS handshake", serve: func(conn net.Conn) {}},
+		{name: "no greeting", serve: func(conn net.Conn) {
+			tls.Server(conn, &tls.Config{Certificates: testCertificates()}).Handshake()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+			go func() {
+				for {
+					conn, err := ln.Accept()
+					if err != nil {
+						return
+					}
+					// Keep the connection open and silent after serve
+					tt.serve(conn)
+					defer conn.Close()
+				}
+			}()
+
+			_, port, _ := net.SplitHostPort(ln.Addr().String())
+			config := imapConfig{host: "127.0.0.1", port: port, insecure: true, dialTimeout: 100 * time.Millisecond}
+			start := time.Now()
+			_, err = connectToServer(config)
+			if err == nil || !strings.Contains(err.Error(), "timed out after 100ms") {
+				t.Errorf("error = %v, want a timeout", err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("gave up after %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestConnectToServerCommandTimeout(t *testing.T) {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: testCertificates()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := server.New(memory.New())
+	go s.Serve(ln)
+	defer s.Close()
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	config := imapConfig{
+		host:           "127.0.0.1",
+		port:           port,
+		username:       "username",
+		password:       "password",
+		insecure:       true,
+		dialTimeout:    time.Second,
+		commandTimeout: 3 * time.Second,
+	}
+	c, err := connectToServer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Logout()
+	if c.Timeout != config.commandTimeout {
+		t.Errorf("command timeout = %s, want %s", c.Timeout, config.commandTimeout)
+	}
+
+	config.password = "wrong"
+	if _, err := connectToServer(config); err == nil {
+		t.Error("logged in with a wrong password")
+	}
+}
+
+func TestOperationsWaitForAReconnect(t *testing.T) {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: testCertificates()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := server.New(memory.New())
+	go s.Serve(ln)
+	defer s.Close()
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	config := imapConfig{
+		host:           "127.0.0.1",
+		port:           port,
+		username:       "username",
+		password:       "password",
+		inbox:          "INBOX",
+		insecure:       true,
+		dialTimeout:    time.Second,
+		commandTimeout: 3 * time.Second,
+	}
+	a := NewApp(config, readOptions{perPage: 20})
+	defer func() {
+		if a.client != nil {
+			a.client.Logout()
+		}
+	}()
+
+	reconnected := make(chan tea.Msg, 1)
+	go func() {
+		reconnected <- a.reconnect(connectionLostMsg{err: errors.New("connection reset"), retry: func() tea.Msg { return nil }})()
+	}()
+
+	// Refreshes and flag updates keep running while the client is swapped
+	// out; none of them may see it missing or half-replaced
+	errs := make(chan string, 2)
+	stop := make(chan struct{})
+	ops := []func() tea.Cmd{
+		func() tea.Cmd { return a.loadEmails(1, false) },
+		func() tea.Cmd { return a.markSeen(6) },
+	}
+	for _, op := range ops {
+		go func() {
+			for {
+				select {
+				case <-stop:
+					errs <- ""
+					return
+				default:
+				}
+				switch msg := op()().(type) {
+				case emailsLoadedMsg, nil:
+				case connectionLostMsg:
+					// Only before the first load dials
+					if !errors.Is(msg.err, errNotConnected) {
+						errs <- fmt.Sprintf("lost the connection: %v", msg.err)
+						return
+					}
+				default:
+					errs <- fmt.Sprintf("got %#v", msg)
+					return
+				}
+			}
+		}()
+	}
+
+	msg := <-reconnected
+	close(stop)
+	for range ops {
+		if err := <-errs; err != "" {
+			t.Error(err)
+		}
+	}
+	if _, ok := msg.(reconnectedMsg); !ok {
+		t.Fatalf("reconnect returned %#v", msg)
+	}
+	if a.client == nil || isClosed(a.client) {
+		t.Error("no working client after the reconnect")
+	}
+}
+
+func TestIMAPTimeoutFlags(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		args        []string
+		wantDial    time.Duration
+		wantCommand time.Duration
+	}{
+		{name: "defaults", wantDial: 10 * time.Second, wantCommand: 60 * time.Second},
+		{name: "env", env: map[string]string{"CLEU_DIAL_TIMEOUT": "2s", "CLEU_TIMEOUT": "30s"}, wantDial: 2 * time.Second, wantCommand: 30 * time.Second},
+		{name: "flags", args: []string{"--dial-timeout", "3s", "--timeout", "0"}, wantDial: 3 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setServerEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			config, _ := loadConfigsWith(t, imapAndSMTPFlags(), tt.args...)
+			if config.dialTimeout != tt.wantDial || config.commandTimeout != tt.wantCommand {
+				t.Errorf("timeouts = %s and %s, want %s and %s", config.dialTimeout, config.commandTimeout, tt.wantDial, tt.wantCommand)
+			}
+		})
+	}
+}
+
+func TestRelativeDate(t *testing.T) {
+	now := time.Date(2026, 3, 11, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		t    time.Time
+		want string
+	}{
+		{name: "seconds", t: now.Add(-30 * time.Second), want: "Just now"},
+		{name: "minutes", t: now.Add(-59 * time.Minute), want: "59m ago"},
+		{name: "hours today", t: now.Add(-14 * time.Hour), want: "14h ago"},
+		{name: "yesterday evening", t: time.Date(2026, 3, 10, 23, 0, 0, 0, time.UTC), want: "Yesterday"},
+		{name: "yesterday morning", t: time.Date(2026, 3, 10, 1, 0, 0, 0, time.UTC), want: "Yesterday"},
+		{name: "six days", t: now.Add(-6 * 24 * time.Hour), want: "6 days ago"},
+		{name: "a week", t: now.Add(-7 * 24 * time.Hour), want: "Mar 4, 15:00"},
+		{name: "in the future", t: now.Add(time.Hour), want: "Mar 11, 16:00"},
+		{name: "last year", t: time.Date(2025, 12, 31, 9, 0, 0, 0, time.UTC), want: "Dec 31, 2025"},
+	}
+	for _, tt := range tests {
+		if got := relativeDate(tt.t, now); got != tt.want {
+			t.Errorf("%s: relativeDate = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRelativeDateAcrossDaylightSaving(t *testing.T) {
+	paris := mustLoadLocation(t, "Europe/Paris")
+	// Clocks went forward on March 29, 2026, so that day has 23 hours
+	now := time.Date(2026, 3, 31, 10, 0, 0, 0, paris)
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{t: time.Date(2026, 3, 30, 10, 0, 0, 0, paris), want: "Yesterday"},
+		{t: time.Date(2026, 3, 29, 1, 0, 0, 0, paris), want: "2 days ago"},
+		{t: time.Date(2026, 3, 28, 23, 0, 0, 0, paris), want: "3 days ago"},
+	}
+	for _, tt := range tests {
+		if got := relativeDate(tt.t, now); got != tt.want {
+			t.Errorf("relativeDate(%s) = %q, want %q", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestDescriptionDates(t *testing.T) {
+	// Minutes, unlike hours, do not turn into "Yesterday" after midnight
+	sent := time.Now().Add(-5 * time.Minute)
+	tests := []struct {
+		name     string
+		absolute bool
+		want     string
+	}{
+		{name: "relative", want: " - 5m ago"},
+		{name: "absolute", absolute: true, want: " - " + sent.Format("Jan 2, 15:04")},
+	}
+	for _, tt := range tests {
+		email := Email{From: "Alice", Date: sent, absoluteDate: tt.absolute}
+		if got := email.Description(); !strings.HasSuffix(got, tt.want) {
+			t.Errorf("%s: Description() = %q, want it to end with %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{s: "Hello", width: 10, want: "Hello"},
+		{s: "Hello", width: 5, want: "Hello"},
+		{s: "Hello, world", width: 8, want: "Hello..."},
+		{s: "Réunion d'équipe", width: 10, want: "Réunion..."},
+		// Wide characters take two cells each
+		{s: "日本語の件名です", width: 9, want: "日本語..."},
+		{s: "🎉🎉🎉🎉🎉", width: 8, want: "🎉🎉..."},
+	}
+	for _, tt := range tests {
+		got := truncate(tt.s, tt.width)
+		if got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("truncate(%q, %d) split a character", tt.s, tt.width)
+		}
+		if w := ansi.StringWidth(got); w > tt.width {
+			t.Errorf("truncate(%q, %d) is %d cells wide", tt.s, tt.width, w)
+		}
+	}
+}
+
+func TestTitleFitsTheList(t *testing.T) {
+	subject := strings.Repeat("A very long subject line ", 10)
+	tests := []struct {
+		name  string
+		email Email
+		want  string
+	}{
+		{name: "default width", email: Email{Subject: subject}, want: subject[:57] + "..."},
+		{name: "list width", email: Email{Subject: subject, titleWidth: 30}, want: subject[:27] + "..."},
+		{name: "short", email: Email{Subject: "Hi", titleWidth: 30}, want: "Hi"},
+		{name: "marked thread", email: Email{Subject: subject, titleWidth: 30, marked: true, threadReplies: 2}, want: "✓ ▸ (3) " + subject[:19] + "..."},
+	}
+	for _, tt := range tests {
+		if got := tt.email.Title(); got != tt.want {
+			t.Errorf("%s: Title() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTitlesFollowTheWindowWidth(t *testing.T) {
+	c, _ := newMockIMAP(t, testMessage(strings.Repeat("Quarterly report ", 20), "text/plain", "Hi\r\n"))
+	a := newTestApp(t, c, readOptions{})
+	for _, width := range []int{120, 50} {
+		a.Update(tea.WindowSizeMsg{Width: width, Height: 40})
+		for _, item := range a.list.Items() {
+			if email, ok := item.(Email); ok {
+				if w := ansi.StringWidth(email.Title()); w > a.list.Width()-4 {
+					t.Errorf("at width %d the title of %d is %d cells wide", width, email.UID, w)
+				}
+			}
+		}
+	}
+}
+
+func TestDeleteDialogTruncatesOnCharacters(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject string
+		from    string
+	}{
+		{name: "accents", subject: strings.Repeat("é", 100), from: "Élodie Dupré"},
+		{name: "wide", subject: strings.Repeat("件名", 50), from: strings.Repeat("山田", 40)},
+		{name: "emoji", subject: strings.Repeat("🎉 party ", 20), from: "Zoë"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewApp(imapConfig{inbox: "INBOX"}, readOptions{})
+			a.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+			a.emails = []Email{{UID: 7, Subject: tt.subject, From: tt.from, Date: time.Now()}}
+			a.deleteUIDs = []uint32{7}
+			dialog := a.renderDeleteConfirmation()
+			if !utf8.ValidString(dialog) {
+				t.Fatal("the dialog holds a split character")
+			}
+			if !strings.Contains(ansi.Strip(dialog), "...") {
+				t.Errorf("the subject was not shortened:\n%s", dialog)
+			}
+			for _, line := range strings.Split(ansi.Strip(dialog), "\n") {
+				for _, field := range []string{"Subject: ", "From: "} {
+					if _, value, ok := strings.Cut(line, field); ok {
+						if w := ansi.StringWidth(strings.TrimRight(value, " │|")); w > 60 {
+							t.Errorf("%s is %d cells wide", field, w)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEnvelopeSender(t *testing.T) {
+	tests := []struct {
+		name        string
+		addrs       []*imap.Address
+		wantName    string
+		wantAddress string
+	}{
+		{name: "none"},
+		{name: "nil entry", addrs: []*imap.Address{nil}},
+		{name: "name and address", addrs: []*imap.Address{{PersonalName: "Alice", MailboxName: "alice", HostName: "example.com"}}, wantName: "Alice", wantAddress: "alice@example.com"},
+		{name: "address only", addrs: []*imap.Address{{MailboxName: "alice", HostName: "example.com"}}, wantAddress: "alice@example.com"},
+		{name: "blank name", addrs: []*imap.Address{{PersonalName: `  "" `, MailboxName: "alice", HostName: "example.com"}}, wantAddress: "alice@example.com"},
+		{name: "encoded name", addrs: []*imap.Address{{PersonalName: "=?UTF-8?q?Zo=C3=AB?=", MailboxName: "zoe", HostName: "example.com"}}, wantName: "Zoë", wantAddress: "zoe@example.com"},
+		{name: "bad encoding kept", addrs: []*imap.Address{{PersonalName: "=?x-unknown?q?abc?=", MailboxName: "a", HostName: "example.com"}}, wantName: "=?x-unknown?q?abc?=", wantAddress: "a@example.com"},
+		{
+			name: "group then mailbox",
+			addrs: []*imap.Address{
+				{MailboxName: "team"},
+				{PersonalName: "Bob", MailboxName: "bob", HostName: "example.com"},
+				{},
+			},
+			wantName: "Bob", wantAddress: "bob@example.com",
+		},
+		{name: "empty group", addrs: []*imap.Address{{MailboxName: "undisclosed-recipients"}, {}}, wantName: "undisclosed-recipients"},
+		{name: "whitespace only", addrs: []*imap.Address{{PersonalName: " ", MailboxName: " ", HostName: " "}}},
+	}
+	for _, tt := range tests {
+		name, address := envelopeSender(tt.addrs)
+		if name != tt.wantName || address != tt.wantAddress {
+			t.Errorf("%s: envelopeSender = %q, %q, want %q, %q", tt.name, name, address, tt.wantName, tt.wantAddress)
+		}
+	}
+}
+
+func TestListedSenders(t *testing.T) {
+	message := func(from string) string {
+		return "From: " + from + "\r\nTo: me@example.com\r\nSubject: Hi\r\nDate: " + time.Now().Format(time.RFC1123Z) + "\r\n\r\nHi\r\n"
+	}
+	tests := []struct {
+		name     string
+		from     string
+		wantFrom string
+	}{
+		{name: "encoded name", from: "=?UTF-8?q?Zo=C3=AB?= <zoe@example.com>", wantFrom: "Zoë"},
+		{name: "group", from: "Team: bob@example.com;", wantFrom: "bob@example.com"},
+		{name: "empty", from: "", wantFrom: "Unknown sender"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newMockIMAP(t, message(tt.from))
+			a := newTestApp(t, c, readOptions{})
+			i := a.findEmail(7)
+			if i < 0 {
+				t.Fatal("email 7 is not listed")
+			}
+			if got := a.emails[i].From; got != tt.wantFrom {
+				t.Errorf("From = %q, want %q", got, tt.wantFrom)
+			}
+		})
+	}
+}
+
+func TestFetchErrorsAreReturned(t *testing.T) {
+	c, fail := newFailingFetchIMAP(t, testMessage("Hello", "text/plain", "Hi"))
+	fail.Store(true)
+
+	emails, total, err := fetchEmails(c, "INBOX", true, 0, 0, 20, nil)
+	if err == nil || !strings.Contains(err.Error(), "failed to fetch messages") {
+		t.Errorf("fetchEmails error = %v", err)
+	}
+	if emails != nil || total != 2 {
+		t.Errorf("fetchEmails = %d emails of %d, want none of 2", len(emails), total)
+	}
+	if _, err := fetchEmailBodyParsed(c, 7, true); err == nil || !strings.Contains(err.Error(), "failed to fetch email 7") {
+		t.Errorf("fetchEmailBodyParsed error = %v", err)
+	}
+}
+
+func TestFetchErrorsReachTheUI(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  func(t *testing.T, a *App)
+		wantErr string
+	}{
+		{name: "list", action: func(t *testing.T, a *App) { runCmd(t, a, a.loadEmails(1, false)) }, wantErr: "failed to fetch messages"},
+		{name: "body", action: func(t *testing.T, a *App) { runCmd(t, a, a.loadEmailBody(7)) }, wantErr: "mailbox store unavailable"},
+		{name: "source", action: func(t *testing.T, a *App) { runCmd(t, a, a.loadSource(7, sourceView)) }, wantErr: "failed to fetch email 7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, fail := newFailingFetchIMAP(t, testMessage("Hello", "text/plain", "Hi"))
+			a := newTestApp(t, c, readOptions{})
+			if a.err != nil || len(a.emails) != 2 {
+				t.Fatalf("before failing: %d emails, error %v", len(a.emails), a.err)
+			}
+			fail.Store(true)
+			tt.action(t, a)
+			if a.err == nil || !strings.Contains(a.err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want one containing %q", a.err, tt.wantErr)
+			}
+			if a.loading || a.loadingBody {
+				t.Error("still loading after the error")
+			}
+		})
+	}
+}
+
+func TestPaginationEdges(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages int // besides the backend's own, unless empty
+		empty    bool
+		perPage  int
+		loads    int    // LoadMore presses after the first page
+		want     []int  // emails listed after the first page and each press
+		wantMore []bool // hasMore at each step
+		extra    bool   // load once more past the oldest message at the end
+	}{
+		{name: "empty mailbox", empty: true, perPage: 5, want: []int{0}, wantMore: []bool{false}, extra: true},
+		{name: "smaller than a page", messages: 1, perPage: 5, want: []int{2}, wantMore: []bool{false}, extra: true},
+		{name: "exactly one page", messages: 3, perPage: 4, want: []int{4}, wantMore: []bool{false}, extra: true},
+		{name: "final partial page", messages: 4, perPage: 2, loads: 2, want: []int{2, 4, 5}, wantMore: []bool{true, true, false}, extra: true},
+		{name: "one per page", messages: 2, perPage: 1, loads: 2, want: []int{1, 2, 3}, wantMore: []bool{true, true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var messages []string
+			for i := range tt.messages {
+				messages = append(messages, testMessage(fmt.Sprintf("Message %d", i), "text/plain", "Hi"))
+			}
+			c, user := newMockIMAP(t, messages...)
+			if tt.empty {
+				mailbox, err := user.GetMailbox("INBOX")
+				if err != nil {
+					t.Fatal(err)
+				}
+				mailbox.(*memory.Mailbox).Messages = nil
+			}
+			a := newTestApp(t, c, readOptions{perPage: tt.perPage})
+			check := func(step int) {
+				t.Helper()
+				if a.err != nil {
+					t.Fatalf("step %d: %v", step, a.err)
+				}
+				if len(a.emails) != tt.want[step] || a.hasMore != tt.wantMore[step] {
+					t.Errorf("step %d: %d emails, hasMore %v; want %d, %v", step, len(a.emails), a.hasMore, tt.want[step], tt.wantMore[step])
+				}
+				shown := false
+				for _, item := range a.list.Items() {
+					if _, ok := item.(LoadMoreItem); ok {
+						shown = true
+					}
+				}
+				if shown != a.hasMore {
+					t.Errorf("step %d: Load More shown = %v with hasMore %v", step, shown, a.hasMore)
+				}
+			}
+			check(0)
+			for step := 1; step <= tt.loads; step++ {
+				a.currentPage++
+				runCmd(t, a, a.loadEmails(a.currentPage, true))
+				check(step)
+			}
+			if tt.extra {
+				// A page past the oldest message is a no-op
+				a.currentPage++
+				runCmd(t, a, a.loadEmails(a.currentPage, true))
+				check(tt.loads)
+			}
+		})
+	}
+}
+
+func TestEnterWithAFilterOpensTheSelectedItem(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   string
+		pick     string // title of the visible item to select
+		wantOpen string // subject of the email opened, "" for Load More
+	}{
+		{name: "second match", filter: "report", pick: "Gamma report", wantOpen: "Gamma report"},
+		{name: "first match", filter: "report", pick: "Alpha report", wantOpen: "Alpha report"},
+		{name: "load more", filter: "load more", pick: LoadMoreItem{}.Title()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newMockIMAP(t,
+				testMessage("Alpha report", "text/plain", "A"),
+				testMessage("Beta notes", "text/plain", "B"),
+				testMessage("Gamma report", "text/plain", "C"),
+				testMessage("Delta notes", "text/plain", "D"),
+			)
+			a := newTestApp(t, c, readOptions{perPage: 4})
+			if !a.hasMore {
+				t.Fatal("want a Load More item")
+			}
+			a.list.SetFilterText(tt.filter)
+			picked := false
+			for i, item := range a.list.VisibleItems() {
+				if item.(interface{ Title() string }).Title() == tt.pick {
+					a.list.Select(i)
+					picked = true
+				}
+			}
+			if !picked {
+				t.Fatalf("%q is not among the filtered items", tt.pick)
+			}
+
+			listed := len(a.emails)
+			pressKey(t, a, "enter")
+			if tt.wantOpen == "" {
+				if a.state != listView || len(a.emails) <= listed {
+					t.Errorf("Load More: state %v, %d emails, want more than %d in the list", a.state, len(a.emails), listed)
+				}
+				return
+			}
+			email, ok := a.currentEmail()
+			if a.state != emailView || !ok || email.Subject != tt.wantOpen {
+				t.Errorf("opened %q (state %v), want %q", email.Subject, a.state, tt.wantOpen)
+			}
+		})
+	}
+}
+
+func TestAppendNewEmails(t *testing.T) {
+	emails := func(uids ...uint32) []Email {
+		var list []Email
+		for _, uid := range uids {
+			list = append(list, Email{UID: uid})
+		}
+		return list
+	}
+	tests := []struct {
+		name    string
+		listed  []Email
+		more    []Email
+		wantUID []uint32
+	}{
+		{name: "disjoint", listed: emails(10, 9), more: emails(8, 7), wantUID: []uint32{10, 9, 8, 7}},
+		{name: "overlap", listed: emails(10, 9, 8), more: emails(8, 7, 6), wantUID: []uint32{10, 9, 8, 7, 6}},
+		{name: "all listed", listed: emails(10, 9), more: emails(10, 9), wantUID: []uint32{10, 9}},
+		{name: "repeated in the page", listed: emails(10), more: emails(9, 9, 8), wantUID: []uint32{10, 9, 8}},
+		{name: "nothing listed", more: emails(3, 2), wantUID: []uint32{3, 2}},
+	}
+	for _, tt := range tests {
+		var got []uint32
+		for _, email := range appendNewEmails(tt.listed, tt.more) {
+			got = append(got, email.UID)
+		}
+		if !slices.Equal(got, tt.wantUID) {
+			t.Errorf("%s: UIDs = %v, want %v", tt.name, got, tt.wantUID)
+		}
+	}
+}
+
+func TestOverlappingPagesListEachEmailOnce(t *testing.T) {
+	c, _ := newMockIMAP(t,
+		testMessage("One", "text/plain", "1"),
+		testMessage("Two", "text/plain", "2"),
+		testMessage("Three", "text/plain", "3"),
+	)
+	a := newTestApp(t, c, readOptions{perPage: 2})
+	if len(a.emails) != 2 {
+		t.Fatalf("%d emails on the first page, want 2", len(a.emails))
+	}
+	// A page fetched after new mail shifted the mailbox repeats the newest
+	// of the previous one
+	overlap := append([]Email{a.emails[len(a.emails)-1]}, Email{UID: 7, Subject: "One"}, Email{UID: 6, Subject: "Older"})
+	a.Update(emailsLoadedMsg{emails: overlap, totalMessages: 4, isLoadMore: true})
+
+	count := map[uint32]int{}
+	for _, email := range a.emails {
+		count[email.UID]++
+	}
+	for uid, n := range count {
+		if n != 1 {
+			t.Errorf("UID %d listed %d times", uid, n)
+		}
+	}
+	if len(a.emails) != 4 || a.hasMore {
+		t.Errorf("%d emails, hasMore %v; want 4, false", len(a.emails), a.hasMore)
+	}
+	items := 0
+	for _, item := range a.list.Items() {
+		if _, ok := item.(Email); ok {
+			items++
+		}
+	}
+	if items != 4 {
+		t.Errorf("%d emails in the list, want 4", items)
+	}
+}
+
+func TestUIDPage(t *testing.T) {
+	uids := []uint32{3, 9, 5, 12, 7}
+	tests := []struct {
+		name   string
+		before uint32
+		skip   int
+		count  int
+		want   []uint32
+	}{
+		{name: "newest first", count: 3, want: []uint32{12, 9, 7}},
+		{name: "all", count: 10, want: []uint32{12, 9, 7, 5, 3}},
+		{name: "below a UID", before: 9, count: 2, want: []uint32{7, 5}},
+		{name: "before a missing UID", before: 8, count: 10, want: []uint32{7, 5, 3}},
+		{name: "skip", skip: 1, count: 2, want: []uint32{9, 7}},
+		{name: "skip past the end", skip: 5, count: 2},
+		{name: "nothing below", before: 3, count: 2},
+		{name: "no count", count: 0},
+		{name: "negative skip", skip: -1, count: 2},
+	}
+	for _, tt := range tests {
+		if got := uidPage(uids, tt.before, tt.skip, tt.count); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: uidPage = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPaginationIsStableUnderExpunge(t *testing.T) {
+	var messages []string
+	for i := range 5 {
+		messages = append(messages, testMessage(fmt.Sprintf("Message %d", i), "text/plain", "Hi"))
+	}
+	c, user := newMockIMAP(t, messages...) // UIDs 6 to 11
+	a := newTestApp(t, c, readOptions{perPage: 2})
+	// By UID, newest first: the test messages share a date, so the list
+	// itself is not in UID order
+	uids := func() []uint32 {
+		var list []uint32
+		for _, email := range a.emails {
+			list = append(list, email.UID)
+		}
+		slices.Sort(list)
+		slices.Reverse(list)
+		return list
+	}
+	if got := uids(); !slices.Equal(got, []uint32{11, 10}) {
+		t.Fatalf("first page = %v", got)
+	}
+
+	// Mail arrives and two messages, one of them listed, are expunged
+	mailbox, err := user.GetMailbox("INBOX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mailbox.CreateMessage(nil, time.Now(), strings.NewReader(testMessage("New", "text/plain", "Hi"))); err != nil {
+		t.Fatal(err)
+	}
+	inbox := mailbox.(*memory.Mailbox)
+	inbox.Messages = slices.DeleteFunc(inbox.Messages, func(m *memory.Message) bool { return m.Uid == 10 || m.Uid == 7 })
+
+	steps := []struct {
+		want     []uint32
+		wantMore bool
+	}{
+		{want: []uint32{11, 10, 9, 8}, wantMore: true},
+		{want: []uint32{11, 10, 9, 8, 6}, wantMore: false},
+	}
+	for i, step := range steps {
+		a.currentPage++
+		runCmd(t, a, a.loadEmails(a.currentPage, true))
+		if a.err != nil {
+			t.Fatal(a.err)
+		}
+		if got := uids(); !slices.Equal(got, step.want) || a.hasMore != step.wantMore {
+			t.Errorf("load %d: %v, hasMore %v; want %v, %v", i+1, got, a.hasMore, step.want, step.wantMore)
+		}
+	}
+	if a.totalMessages != 5 {
+		t.Errorf("totalMessages = %d, want 5", a.totalMessages)
+	}
+}
+
+func TestSenderFormat(t *testing.T) {
+	named := Email{From: "Alice", FromName: "Alice", FromAddress: "alice@example.com"}
+	bare := Email{From: "bob@example.com", FromAddress: "bob@example.com"}
+	unknown := Email{From: "Unknown sender"}
+	tests := []struct {
+		format string
+		email  Email
+		want   string
+	}{
+		{format: fromFormatName, email: named, want: "Alice"},
+		{format: fromFormatName, email: bare, want: "bob@example.com"},
+		{format: fromFormatEmail, email: named, want: "alice@example.com"},
+		{format: fromFormatEmail, email: bare, want: "bob@example.com"},
+		{format: fromFormatBoth, email: named, want: "Alice <alice@example.com>"},
+		{format: fromFormatBoth, email: bare, want: "bob@example.com"},
+		{format: "", email: named, want: "Alice"},
+		{format: fromFormatEmail, email: unknown, want: "Unknown sender"},
+		{format: fromFormatBoth, email: unknown, want: "Unknown sender"},
+	}
+	for _, tt := range tests {
+		tt.email.fromFormat = tt.format
+		if got := tt.email.sender(); got != tt.want {
+			t.Errorf("%q with %+v: sender = %q, want %q", tt.format, tt.email, got, tt.want)
+		}
+	}
+}
+
+func TestFromFormatInTheList(t *testing.T) {
+	message := func(from string) string {
+		return "From: " + from + "\r\nTo: me@example.com\r\nSubject: Hi\r\nDate: " + time.Now().Format(time.RFC1123Z) + "\r\n\r\nHi\r\n"
+	}
+	tests := []struct {
+		format string
+		from   string
+		want   string
+	}{
+		{format: fromFormatName, from: "Alice <alice@example.com>", want: " Alice - "},
+		{format: fromFormatEmail, from: "Alice <alice@example.com>", want: " alice@example.com - "},
+		{format: fromFormatBoth, from: "Alice <alice@example.com>", want: " Alice <alice@example.com> - "},
+		{format: fromFormatBoth, from: "=?UTF-8?q?Zo=C3=AB?= <zoe@example.com>", want: " Zoë <zoe@example.com> - "},
+		{format: fromFormatName, from: "bob@example.com", want: " bob@example.com - "},
+		{format: fromFormatBoth, from: "bob@example.com", want: " bob@example.com - "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format+" "+tt.from, func(t *testing.T) {
+			c, _ := newMockIMAP(t, message(tt.from))
+			a := newTestApp(t, c, readOptions{fromFormat: tt.format})
+			for _, item := range a.list.Items() {
+				if email, ok := item.(Email); ok && email.UID == 7 {
+					if got := email.Description(); !strings.Contains(got, tt.want) {
+						t.Errorf("description %q does not contain %q", got, tt.want)
+					}
+					return
+				}
+			}
+			t.Fatal("email 7 is not listed")
+		})
+	}
+}
+
+func TestMissingDateFallsBackToInternalDate(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	message := func(subject, date string) string {
+		header := "From: alice@example.com\r\nTo: me@example.com\r\nSubject: " + subject + "\r\n"
+		if date != "" {
+			header += "Date: " + date + "\r\n"
+		}
+		return header + "\r\nHi\r\n"
+	}
+	c, user := newMockIMAP(t)
+	inbox, err := user.GetMailbox("INBOX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The backend's own message is dated 2016, older than all of these
+	messages := []struct {
+		raw      string
+		received time.Time
+	}{
+		{raw: message("Old", now.Add(-3*time.Hour).Format(time.RFC1123Z)), received: now.Add(-3 * time.Hour)},
+		{raw: message("No date", ""), received: now.Add(-2 * time.Hour)},
+		{raw: message("Bad date", "someday"), received: now.Add(-90 * time.Minute)},
+		{raw: message("New", now.Add(-time.Hour).Format(time.RFC1123Z)), received: now.Add(-time.Hour)},
+	}
+	for _, m := range messages {
+		if err := inbox.CreateMessage(nil, m.received, strings.NewReader(m.raw)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a := newTestApp(t, c, readOptions{})
+	var subjects []string
+	for _, email := range a.emails {
+		subjects = append(subjects, email.Subject)
+		if email.Date.IsZero() {
+			t.Errorf("%q has no date", email.Subject)
+		}
+	}
+	if want := []string{"New", "Bad date", "No date", "Old"}; !slices.Equal(subjects[:4], want) {
+		t.Errorf("order = %q, want %q first", subjects, want)
+	}
+	for _, email := range a.emails {
+		if email.Subject == "No date" && !email.Date.Equal(now.Add(-2*time.Hour)) {
+			t.Errorf("No date is dated %v, want when it was received, %v", email.Date, now.Add(-2*time.Hour))
+		}
+	}
+}
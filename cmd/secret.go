@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// secretCommandTimeout bounds how long a *_CMD command may run, so a
+// password manager waiting for input cannot hang cleu forever.
+const secretCommandTimeout = 30 * time.Second
+
+// envOrCommand returns the value of the environment variable name or, when
+// it is empty, the output of the shell command in name+"_CMD" with trailing
+// newlines removed. This lets secrets come from pass, gpg or a secrets
+// manager instead of living in the environment.
+func envOrCommand(name string) (string, error) {
+	if value := os.Getenv(name); value != "" {
+		return value, nil
+	}
+	command := os.Getenv(name + "_CMD")
+	if command == "" {
+		return "", nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("%s_CMD did not finish within %s", name, secretCommandTimeout)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s_CMD failed: %w", name, err)
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import "slices"
+
+// The emails marked for bulk actions are kept as UIDs, not list indexes, so
+// that marks stay on the same emails when the list is sorted, filtered or
+// reloaded.
+
+// toggleMarked marks the email with uid, or unmarks it if it already is.
+func (a *App) toggleMarked(uid uint32) {
+	if a.marked[uid] {
+		delete(a.marked, uid)
+	} else {
+		a.marked[uid] = true
+	}
+}
+
+// markAll marks every loaded email the list shows.
+func (a *App) markAll() {
+	for _, email := range a.visibleEmails() {
+		a.marked[email.UID] = true
+	}
+}
+
+// invertMarked unmarks the marked emails the list shows and marks the others.
+func (a *App) invertMarked() {
+	for _, email := range a.visibleEmails() {
+		a.toggleMarked(email.UID)
+	}
+}
+
+// markedUIDs returns the marked emails that are still loaded, in UID order.
+func (a *App) markedUIDs() []uint32 {
+	var uids []uint32
+	for uid := range a.marked {
+		if a.findEmail(uid) >= 0 {
+			uids = append(uids, uid)
+		}
+	}
+	slices.Sort(uids)
+	return uids
+}
+
+// pruneMarked drops the marks of emails that are no longer loaded, such as
+// deleted ones.
+func (a *App) pruneMarked() {
+	for uid := range a.marked {
+		if a.findEmail(uid) < 0 {
+			delete(a.marked, uid)
+		}
+	}
+}
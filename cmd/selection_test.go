@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-imap/backend/memory"
+)
+
+// inboxUIDs returns the UIDs the server has in INBOX.
+func inboxUIDs(t *testing.T, user *memory.User) []uint32 {
+	t.Helper()
+	mailbox, err := user.GetMailbox("INBOX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uids []uint32
+	for _, message := range mailbox.(*memory.Mailbox).Messages {
+		uids = append(uids, message.Uid)
+	}
+	return uids
+}
+
+func newSelectionApp(t *testing.T, options readOptions) (*App, *memory.User) {
+	t.Helper()
+	var messages []string
+	for i := range 3 {
+		messages = append(messages, testMessage(fmt.Sprintf("Email %d", i), "text/plain", "Body\r\n"))
+	}
+	c, user := newMockIMAP(t, messages...)
+	a := newTestApp(t, c, options)
+	if !a.selectUID(9) {
+		t.Fatal("email 9 is not listed")
+	}
+	return a, user
+}
+
+func TestMarkAllAndInvert(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   []string
+		marked []uint32
+	}{
+		{name: "select all", keys: []string{"*"}, marked: []uint32{6, 7, 8, 9}},
+		{name: "invert nothing", keys: []string{"i"}, marked: []uint32{6, 7, 8, 9}},
+		{name: "invert all", keys: []string{"*", "i"}},
+		{name: "toggle then invert", keys: []string{" ", "i"}, marked: []uint32{6, 7, 8}},
+		{name: "toggle twice", keys: []string{" ", " "}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := newSelectionApp(t, readOptions{})
+			// The newest email, UID 9, is highlighted
+			for _, key := range tt.keys {
+				pressKey(t, a, key)
+			}
+			if got := a.markedUIDs(); !slices.Equal(got, tt.marked) {
+				t.Errorf("marked = %v, want %v", got, tt.marked)
+			}
+			if len(a.marked) != len(tt.marked) {
+				t.Errorf("%d marks kept, want %d", len(a.marked), len(tt.marked))
+			}
+		})
+	}
+}
+
+func TestMarkAllOnlyMarksVisibleEmails(t *testing.T) {
+	a, _ := newSelectionApp(t, readOptions{})
+	// The memory backend's own email, UID 6, is already read
+	a.emails[a.findEmail(8)].Seen = true
+	a.updateEmailList()
+	pressKey(t, a, "U")
+	pressKey(t, a, "*")
+	if got := a.markedUIDs(); !slices.Equal(got, []uint32{7, 9}) {
+		t.Errorf("marked = %v, want the unread 7 and 9", got)
+	}
+}
+
+func TestDeleteMarkedEmails(t *testing.T) {
+	tests := []struct {
+		name    string
+		marked  []uint32
+		confirm bool
+		want    []uint32 // left on the server
+	}{
+		{name: "no selection deletes the highlighted email", want: []uint32{6, 7, 8}},
+		{name: "selection", marked: []uint32{6, 8}, want: []uint32{7, 9}},
+		{name: "selection after confirming", marked: []uint32{7, 8, 9}, confirm: true, want: []uint32{6}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, user := newSelectionApp(t, readOptions{confirmDelete: tt.confirm})
+			for _, uid := range tt.marked {
+				a.toggleMarked(uid)
+			}
+			pressKey(t, a, "d")
+			if tt.confirm {
+				if a.state != deleteConfirmView {
+					t.Fatalf("state = %v, want the confirmation", a.state)
+				}
+				pressKey(t, a, "left")
+				pressKey(t, a, "enter")
+			}
+
+			if got := inboxUIDs(t, user); !slices.Equal(got, tt.want) {
+				t.Errorf("server has %v, want %v", got, tt.want)
+			}
+			for _, uid := range tt.marked {
+				if a.findEmail(uid) >= 0 {
+					t.Errorf("email %d is still listed", uid)
+				}
+			}
+			if len(a.marked) != 0 {
+				t.Errorf("marks left: %v", a.marked)
+			}
+			if a.totalMessages != uint32(len(tt.want)) {
+				t.Errorf("totalMessages = %d, want %d", a.totalMessages, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestStatusBarCountsMarks(t *testing.T) {
+	a, _ := newSelectionApp(t, readOptions{})
+	pressKey(t, a, "*")
+	if bar := a.renderStatusBar(); !strings.Contains(bar, "4 selected") {
+		t.Errorf("status bar = %q, want 4 selected", bar)
+	}
+}
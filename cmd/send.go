@@ -1,66 +1,565 @@
 package cmd
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"crypto/rand"
 	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
 	"net/smtp"
+	"net/textproto"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"syscall"
+	"text/template"
 	"time"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/huh"
 	"github.com/urfave/cli/v3"
+	"github.com/yuin/goldmark"
+	"golang.org/x/term"
 )
 
 var Send = &cli.Command{
 	Name:  "send",
-	Usage: "Send an email interactively",
+	Usage: "Send an email interactively, or from flags and stdin in scripts",
+	// Lets --header and --var values contain commas
+	DisableSliceFlagSeparator: true,
+	Flags: append(smtpFlags(),
+		&cli.StringSliceFlag{
+			Name:  "to",
+			Usage: "recipient address(es), separated by commas or by repeating the flag",
+		},
+		&cli.StringSliceFlag{
+			Name:  "cc",
+			Usage: "carbon copy address(es), separated by commas or by repeating the flag",
+		},
+		&cli.StringSliceFlag{
+			Name:  "bcc",
+			Usage: "blind carbon copy address(es), separated by commas or by repeating the flag",
+		},
+		&cli.StringSliceFlag{
+			Name:      "attach",
+			Usage:     "attach this file, repeat for each file",
+			TakesFile: true,
+		},
+		&cli.StringFlag{
+			Name:  "reply-to",
+			Usage: "address(es) replies should go to instead of the sender",
+		},
+		&cli.StringFlag{
+			Name:  "subject",
+			Usage: "subject line",
+		},
+		&cli.StringFlag{
+			Name:  "body",
+			Usage: "email body; without it the body is read from --body-file or a piped stdin",
+		},
+		&cli.StringFlag{
+			Name:      "body-file",
+			Usage:     "read the email body from this file",
+			TakesFile: true,
+		},
+		&cli.BoolFlag{
+			Name:  "html",
+			Usage: "also send an HTML version of the body rendered from markdown",
+		},
+		&cli.StringFlag{
+			Name:      "send-at",
+			Usage:     `queue the email in the outbox until this local time ("2006-01-02 15:04"), see flush-outbox`,
+			Validator: validateSendAt,
+		},
+		&cli.StringFlag{
+			Name:  "template",
+			Usage: "start from ~/.config/cleu/templates/<name>.tmpl; the form is pre-filled, or the email is sent directly when stdin is not a terminal",
+		},
+		&cli.StringSliceFlag{
+			Name:  "var",
+			Usage: "template variable as name=value, repeat for each variable",
+		},
+		&cli.BoolFlag{
+			Name:  "individual",
+			Usage: "send a separate email to each To recipient, filling {{.Name}} and {{.Email}} in the subject and body",
+		},
+		&cli.BoolFlag{
+			Name:  "read-receipt",
+			Usage: "ask the recipient's mail client to confirm when the email is read",
+		},
+		&cli.StringSliceFlag{
+			Name:  "header",
+			Usage: `extra header as "Name: Value", repeat for each header`,
+		},
+		&cli.BoolFlag{
+			Name:  "allow-header-override",
+			Usage: "let --header replace addressing headers such as From, To or Subject",
+		},
+		&cli.BoolFlag{
+			Name:  "allow-placeholders",
+			Usage: "send even when the subject or body still contains a {{ ... }} placeholder",
+		},
+		&cli.BoolFlag{
+			Name:  "no-signature",
+			Usage: "send this email without the signature",
+		},
+		&cli.BoolFlag{
+			Name:  "yes",
+			Usage: "send without a terminal even when there are more than --max-recipients recipients",
+		},
+		&cli.BoolFlag{
+			Name:    "verify-mx",
+			Usage:   "warn about recipient domains without MX records before sending",
+			Sources: cli.EnvVars("CLEU_VERIFY_MX"),
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "print the message and its recipients instead of sending it",
+		},
+	),
 	Action: func(ctx context.Context, c *cli.Command) error {
-		// Get SMTP configuration from environment
-		smtpHost := os.Getenv("SMTP_HOST")
-		smtpPort := os.Getenv("SMTP_PORT")
-		smtpUsername := os.Getenv("SMTP_USERNAME")
-		smtpPassword := os.Getenv("SMTP_PASSWORD")
-		fromEmail := os.Getenv("FROM_EMAIL")
+		config, err := loadSMTPConfig(c)
+		if err != nil {
+			return err
+		}
+		config.dryRun = c.Bool("dry-run")
+		config.verifyMX = c.Bool("verify-mx")
 
-		if smtpHost == "" || smtpPort == "" || smtpUsername == "" || smtpPassword == "" {
-			return fmt.Errorf("please set SMTP_HOST, SMTP_PORT, SMTP_USERNAME, and SMTP_PASSWORD environment variables")
+		email := &EmailForm{
+			To:                  mergeRecipients(c.StringSlice("to")),
+			Cc:                  mergeRecipients(c.StringSlice("cc")),
+			Bcc:                 mergeRecipients(c.StringSlice("bcc")),
+			Attachments:         mergePaths(c.StringSlice("attach")),
+			ReplyTo:             c.String("reply-to"),
+			Subject:             c.String("subject"),
+			HTML:                c.Bool("html"),
+			SendAt:              c.String("send-at"),
+			ReadReceipt:         c.Bool("read-receipt"),
+			Individual:          c.Bool("individual"),
+			NoSignature:         c.Bool("no-signature"),
+			Headers:             c.StringSlice("header"),
+			AllowHeaderOverride: c.Bool("allow-header-override"),
+			AllowPlaceholders:   c.Bool("allow-placeholders"),
+		}
+		var body string
+		var ok bool
+		if name := c.String("template"); name != "" {
+			if err := applyTemplate(email, name, c.StringSlice("var")); err != nil {
+				return err
+			}
+		}
+		if c.String("template") != "" && !c.IsSet("body") && c.String("body-file") == "" {
+			body, ok = email.Body, !term.IsTerminal(int(os.Stdin.Fd()))
+		} else if body, ok, err = bodyFromFlagsOrStdin(c, os.Stdin); err != nil {
+			return err
+		}
+		if !ok {
+			return composeAndSend(email, config, "")
 		}
 
-		if fromEmail == "" {
-			fromEmail = smtpUsername // Default to SMTP username if FROM_EMAIL not set
+		// Without a terminal to prompt on, everything comes from the flags
+		email.Body = body
+		if err := validateFlagEmail(email); err != nil {
+			return configError{err}
+		}
+		if count := recipientCount(email); exceedsRecipientLimit(count, config.maxRecipients) && !c.Bool("yes") && !config.dryRun {
+			return fmt.Errorf("this email has %d recipients, more than --max-recipients %d; pass --yes to send it anyway", count, config.maxRecipients)
 		}
+		if config.verifyMX {
+			for _, warning := range newMXVerifier().warnings(email) {
+				fmt.Fprintln(os.Stderr, "⚠️  "+warning)
+			}
+		}
+		email.Confirm = true
+		return deliver(email, config)
+	},
+}
 
-		// Create the email form
-		email := &EmailForm{}
-		form := createEmailForm(email, fromEmail)
+// mergeRecipients joins the values of a repeated address flag, each of which
+// may itself be a comma-separated list, dropping repeated addresses. Values
+// that do not parse are kept as they are for validation to report.
+func mergeRecipients(values []string) string {
+	var merged []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		addresses, err := parseRecipients(value)
+		if err != nil {
+			return strings.Join(values, ", ")
+		}
+		for _, address := range addresses {
+			key := strings.ToLower(address.Address)
+			if !seen[key] {
+				seen[key] = true
+				merged = append(merged, address.String())
+			}
+		}
+	}
+	return strings.Join(merged, ", ")
+}
+
+// mergePaths drops empty and repeated paths from a repeated file flag.
+func mergePaths(values []string) []string {
+	var merged []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[filepath.Clean(value)] {
+			continue
+		}
+		seen[filepath.Clean(value)] = true
+		merged = append(merged, value)
+	}
+	return merged
+}
 
-		// Run the form
-		err := form.Run()
+// bodyFromFlagsOrStdin returns the body given with --body or --body-file, or
+// read from stdin when it is not a terminal. It reports false when none of
+// these apply and the interactive form should be used instead.
+func bodyFromFlagsOrStdin(c *cli.Command, stdin *os.File) (string, bool, error) {
+	if c.IsSet("body") {
+		return c.String("body"), true, nil
+	}
+	if path := c.String("body-file"); path != "" {
+		data, err := os.ReadFile(path)
 		if err != nil {
-			return fmt.Errorf("form error: %w", err)
+			return "", false, fmt.Errorf("could not read body file: %w", err)
 		}
+		return string(data), true, nil
+	}
+	if term.IsTerminal(int(stdin.Fd())) {
+		return "", false, nil
+	}
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return "", false, fmt.Errorf("could not read body from stdin: %w", err)
+	}
+	return string(data), true, nil
+}
 
-		// Send the email
-		return sendEmail(email, smtpHost, smtpPort, smtpUsername, smtpPassword)
-	},
+// validateFlagEmail applies the form's validation to an email built from
+// flags.
+func validateFlagEmail(email *EmailForm) error {
+	if strings.TrimSpace(email.To) == "" {
+		return fmt.Errorf("--to is required when the body is not typed in the form")
+	}
+	if strings.TrimSpace(email.Subject) == "" {
+		return fmt.Errorf("--subject is required when the body is not typed in the form")
+	}
+	if strings.TrimSpace(email.Body) == "" {
+		return fmt.Errorf("email body is required")
+	}
+	for _, check := range []error{
+		validateRecipients("To", email.To),
+		validateRecipients("Cc", email.Cc),
+		validateRecipients("Bcc", email.Bcc),
+		validateRecipients("Reply-To", email.ReplyTo),
+		validateHeaderValue("Subject", email.Subject),
+	} {
+		if check != nil {
+			return check
+		}
+	}
+	for _, path := range email.Attachments {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("could not attach %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// smtpConfig holds the settings needed to send through the SMTP server.
+type smtpConfig struct {
+	host            string
+	port            string
+	username        string
+	password        string
+	from            string
+	tlsMode         string
+	dialTimeout     time.Duration
+	signature       string
+	headers         []string // default custom headers, see loadDefaultHeaders
+	maxRecipients   int
+	insecure        bool     // skip TLS certificate verification
+	collectContacts bool     // add the recipients of sent emails to the address book
+	dryRun          bool     // print the message instead of connecting to the server
+	verifyMX        bool     // look up the MX records of recipient domains first
+	quiet           bool     // do not show sending progress
+	proxy           *url.URL // SOCKS5 proxy to connect through, if any
+}
+
+// SMTP TLS modes, set with CLEU_SMTP_TLS.
+const (
+	smtpTLSAuto     = "auto"
+	smtpTLSImplicit = "implicit"
+	smtpTLSStartTLS = "starttls"
+)
+
+// smtpFlags are the connection flags shared by every command that talks to
+// the SMTP server.
+func smtpFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.DurationFlag{
+			Name:    "dial-timeout",
+			Usage:   "maximum time to wait when connecting to the SMTP server (0 disables)",
+			Value:   10 * time.Second,
+			Sources: cli.EnvVars("CLEU_SMTP_DIAL_TIMEOUT"),
+		},
+		&cli.StringFlag{
+			Name:    "signature",
+			Usage:   "signature appended to every email",
+			Sources: cli.EnvVars("CLEU_SIGNATURE"),
+		},
+		&cli.StringFlag{
+			Name:      "signature-file",
+			Usage:     "read the signature from this file",
+			Sources:   cli.EnvVars("CLEU_SIGNATURE_FILE"),
+			TakesFile: true,
+		},
+		&cli.IntFlag{
+			Name:    "max-recipients",
+			Usage:   "ask for confirmation before sending to more recipients than this (0 disables)",
+			Value:   10,
+			Sources: cli.EnvVars("CLEU_MAX_RECIPIENTS"),
+		},
+		&cli.BoolFlag{
+			Name:    "collect-contacts",
+			Usage:   "add the recipients of sent emails to the address book",
+			Sources: cli.EnvVars("CLEU_COLLECT_CONTACTS"),
+		},
+		&cli.BoolFlag{
+			Name:    "quiet",
+			Usage:   "do not show the connecting and sending progress",
+			Sources: cli.EnvVars("CLEU_QUIET"),
+		},
+		proxyFlag(),
+	}
+}
+
+// loadSMTPConfig reads the SMTP settings from the environment and the flags
+// added by smtpFlags.
+func loadSMTPConfig(c *cli.Command) (smtpConfig, error) {
+	config := smtpConfig{
+		host:            os.Getenv("SMTP_HOST"),
+		port:            os.Getenv("SMTP_PORT"),
+		from:            os.Getenv("FROM_EMAIL"),
+		tlsMode:         strings.ToLower(os.Getenv("CLEU_SMTP_TLS")),
+		dialTimeout:     c.Duration("dial-timeout"),
+		maxRecipients:   int(c.Int("max-recipients")),
+		collectContacts: c.Bool("collect-contacts"),
+		quiet:           c.Bool("quiet"),
+	}
+	switch config.tlsMode {
+	case "":
+		config.tlsMode = smtpTLSAuto
+	case smtpTLSAuto, smtpTLSImplicit, smtpTLSStartTLS:
+	default:
+		return config, configError{fmt.Errorf("CLEU_SMTP_TLS must be %q, %q or %q, got %q", smtpTLSAuto, smtpTLSImplicit, smtpTLSStartTLS, config.tlsMode)}
+	}
+	var err error
+	if config.username, err = envOrCommand("SMTP_USERNAME"); err != nil {
+		return config, configError{err}
+	}
+	if config.password, err = envOrCommand("SMTP_PASSWORD"); err != nil {
+		return config, configError{err}
+	}
+	if strings.TrimSpace(config.host) == "" || config.username == "" || config.password == "" {
+		return config, configError{fmt.Errorf("please set SMTP_HOST, SMTP_USERNAME, and SMTP_PASSWORD environment variables (or SMTP_USERNAME_CMD and SMTP_PASSWORD_CMD to read them from a command)")}
+	}
+	if config.host, err = normalizeHost("SMTP_HOST", config.host); err != nil {
+		return config, configError{err}
+	}
+	if config.proxy, err = parseProxy(c.String("proxy")); err != nil {
+		return config, configError{err}
+	}
+	// Set by commands that also talk to the IMAP server, see imapAndSMTPFlags
+	if c.IsSet("smtp-dial-timeout") {
+		config.dialTimeout = c.Duration("smtp-dial-timeout")
+	}
+	defaultPort := ""
+	switch config.tlsMode {
+	case smtpTLSImplicit:
+		defaultPort = defaultSMTPTLSPort
+	case smtpTLSStartTLS:
+		defaultPort = defaultSMTPSTLSPort
+	}
+	if config.port, err = normalizePort("SMTP_PORT", config.port, defaultPort); err != nil {
+		return config, configError{err}
+	}
+	if config.port == "" {
+		return config, configError{fmt.Errorf("please set SMTP_PORT, or set CLEU_SMTP_TLS to %q or %q to use port %s or %s", smtpTLSImplicit, smtpTLSStartTLS, defaultSMTPTLSPort, defaultSMTPSTLSPort)}
+	}
+	if config.from == "" {
+		config.from = config.username // Default to SMTP username if FROM_EMAIL not set
+	}
+	if config.signature, err = loadSignature(c, config.from); err != nil {
+		return config, configError{err}
+	}
+	if config.headers, err = loadDefaultHeaders(); err != nil {
+		return config, configError{err}
+	}
+	insecure, err := insecureFromEnv()
+	if err != nil {
+		return config, configError{err}
+	}
+	config.insecure = insecure
+	return config, nil
+}
+
+// composeAndSend runs the compose and confirm forms for email and sends it.
+// If the user leaves without sending they are offered to save a draft; when
+// draftID is set that draft is updated, and deleted once the email is sent
+// or discarded.
+func composeAndSend(email *EmailForm, config smtpConfig, draftID string) error {
+	// Run the form
+	contacts, err := loadContacts()
+	if err != nil {
+		return err
+	}
+	err = createEmailForm(email, config.from, contacts).Run()
+	if errors.Is(err, huh.ErrUserAborted) {
+		return offerDraft(email, draftID)
+	}
+	if err != nil {
+		return fmt.Errorf("form error: %w", err)
+	}
+
+	// Write the body in $EDITOR if requested. When that fails what was typed
+	// in the form can still be kept as a draft
+	if email.UseEditor {
+		body, err := editInEditor(email.Body)
+		if err == nil && strings.TrimSpace(body) == "" {
+			err = fmt.Errorf("email body is required")
+		}
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return offerDraft(email, draftID)
+		}
+		email.Body = body
+	}
+
+	// Confirm with a summary of what was entered
+	var warnings []string
+	if config.verifyMX {
+		warnings = newMXVerifier().warnings(email)
+	}
+	action := confirmSend
+	err = createConfirmForm(email, config.from, config.maxRecipients, warnings, &action).Run()
+	if errors.Is(err, huh.ErrUserAborted) {
+		fmt.Println("Email sending cancelled.")
+		return offerDraft(email, draftID)
+	}
+	if err != nil {
+		return fmt.Errorf("form error: %w", err)
+	}
+	switch action {
+	case confirmDraft:
+		path, err := saveDraft(email, draftID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("📝 Draft saved to %s\n", path)
+		return nil
+	case confirmDiscard:
+		if draftID != "" {
+			if err := deleteDraft(draftID); err != nil {
+				return err
+			}
+		}
+		fmt.Println("Email discarded.")
+		return nil
+	}
+	email.Confirm = true
+
+	if err := deliver(email, config); err != nil {
+		return err
+	}
+	if draftID != "" && !config.dryRun {
+		return deleteDraft(draftID)
+	}
+	return nil
+}
+
+// deliver queues email in the outbox when it has a send time, and sends it
+// now otherwise.
+func deliver(email *EmailForm, config smtpConfig) error {
+	if email.SendAt != "" && !config.dryRun {
+		path, err := queueEmail(email)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("🕒 Email scheduled for %s (%s)\n", email.SendAt, path)
+		return nil
+	}
+	return sendEmail(email, config)
 }
 
 // EmailForm holds the form data
 type EmailForm struct {
-	To          string
-	Cc          string
-	Bcc         string
-	Subject     string
-	Body        string
-	Priority    string
-	Attachments string
-	Confirm     bool
+	To                  string
+	Cc                  string
+	Bcc                 string
+	ReplyTo             string
+	Subject             string
+	Body                string
+	Priority            string
+	Attachments         []string
+	HTML                bool
+	SendAt              string
+	ReadReceipt         bool
+	Individual          bool
+	NoSignature         bool
+	Headers             []string
+	AllowHeaderOverride bool
+	AllowPlaceholders   bool
+	UseEditor           bool
+	Confirm             bool
+	// Set once the message is built, for whatever references it afterwards
+	MessageID string `json:"-"`
+	// With Individual, the Message-ID of each recipient's copy by address
+	MessageIDs map[string]string `json:"-"`
+}
+
+// UnmarshalJSON also reads drafts and queued emails saved while Attachments
+// was a single comma-separated string.
+func (e *EmailForm) UnmarshalJSON(data []byte) error {
+	type plain EmailForm
+	form := struct {
+		*plain
+		Attachments json.RawMessage
+	}{plain: (*plain)(e)}
+	if err := json.Unmarshal(data, &form); err != nil {
+		return err
+	}
+	e.Attachments = nil
+	if len(form.Attachments) == 0 || string(form.Attachments) == "null" {
+		return nil
+	}
+	var paths string
+	if err := json.Unmarshal(form.Attachments, &paths); err == nil {
+		e.Attachments = mergePaths(strings.Split(paths, ","))
+		return nil
+	}
+	if err := json.Unmarshal(form.Attachments, &e.Attachments); err != nil {
+		return fmt.Errorf("attachments must be a list of paths: %w", err)
+	}
+	return nil
 }
 
 // createEmailForm creates the interactive form using huh
-func createEmailForm(email *EmailForm, fromEmail string) *huh.Form {
+func createEmailForm(email *EmailForm, fromEmail string, contacts []contact) *huh.Form {
 	return huh.NewForm(
 		// Basic email fields group
 		huh.NewGroup(
@@ -69,19 +568,12 @@ func createEmailForm(email *EmailForm, fromEmail string) *huh.Form {
 				Description("Recipient email address(es) - separate multiple with commas").
 				Placeholder("recipient@example.com, another@example.com").
 				Value(&email.To).
+				SuggestionsFunc(func() []string { return recipientSuggestions(contacts, email.To) }, &email.To).
 				Validate(func(s string) error {
 					if strings.TrimSpace(s) == "" {
 						return fmt.Errorf("recipient is required")
 					}
-					// Basic email validation for each recipient
-					recipients := strings.Split(s, ",")
-					for _, recipient := range recipients {
-						recipient = strings.TrimSpace(recipient)
-						if !strings.Contains(recipient, "@") || !strings.Contains(recipient, ".") {
-							return fmt.Errorf("invalid email format: %s", recipient)
-						}
-					}
-					return nil
+					return validateRecipients("To", s)
 				}),
 
 			huh.NewInput().
@@ -93,7 +585,7 @@ func createEmailForm(email *EmailForm, fromEmail string) *huh.Form {
 					if strings.TrimSpace(s) == "" {
 						return fmt.Errorf("subject is required")
 					}
-					return nil
+					return validateHeaderValue("Subject", s)
 				}),
 		),
 
@@ -103,13 +595,30 @@ func createEmailForm(email *EmailForm, fromEmail string) *huh.Form {
 				Title("Cc (Optional)").
 				Description("Carbon copy recipients - separate multiple with commas").
 				Placeholder("cc@example.com").
-				Value(&email.Cc),
+				Value(&email.Cc).
+				SuggestionsFunc(func() []string { return recipientSuggestions(contacts, email.Cc) }, &email.Cc).
+				Validate(func(s string) error {
+					return validateRecipients("Cc", s)
+				}),
 
 			huh.NewInput().
 				Title("Bcc (Optional)").
 				Description("Blind carbon copy recipients - separate multiple with commas").
 				Placeholder("bcc@example.com").
-				Value(&email.Bcc),
+				Value(&email.Bcc).
+				SuggestionsFunc(func() []string { return recipientSuggestions(contacts, email.Bcc) }, &email.Bcc).
+				Validate(func(s string) error {
+					return validateRecipients("Bcc", s)
+				}),
+
+			huh.NewInput().
+				Title("Reply-To (Optional)").
+				Description("Where replies should go, if not to the sender").
+				Placeholder("replies@example.com").
+				Value(&email.ReplyTo).
+				Validate(func(s string) error {
+					return validateRecipients("Reply-To", s)
+				}),
 
 			huh.NewSelect[string]().
 				Title("Priority").
@@ -120,8 +629,35 @@ func createEmailForm(email *EmailForm, fromEmail string) *huh.Form {
 					huh.NewOption("Low", "low"),
 				).
 				Value(&email.Priority),
+
+			huh.NewConfirm().
+				Title("HTML Version").
+				Description("Also send an HTML version rendered from the markdown body").
+				Value(&email.HTML),
+
+			huh.NewConfirm().
+				Title("Read Receipt").
+				Description("Ask the recipient's mail client to confirm when the email is read").
+				Value(&email.ReadReceipt),
+
+			huh.NewInput().
+				Title("Send At (Optional)").
+				Description("Queue the email until this local time, leave empty to send now").
+				Placeholder(sendAtLayout).
+				Value(&email.SendAt).
+				Validate(validateSendAt),
 		),
 
+		// Editor choice, only offered when $EDITOR is set
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title("Compose in $EDITOR").
+				Description(fmt.Sprintf("Write the email body in %s instead of inline", os.Getenv("EDITOR"))).
+				Value(&email.UseEditor),
+		).WithHideFunc(func() bool {
+			return os.Getenv("EDITOR") == ""
+		}),
+
 		// Body group
 		huh.NewGroup(
 			huh.NewText().
@@ -136,169 +672,755 @@ func createEmailForm(email *EmailForm, fromEmail string) *huh.Form {
 					}
 					return nil
 				}),
-		),
+		).WithHideFunc(func() bool {
+			return email.UseEditor
+		}),
+	).WithTheme(huh.ThemeCharm())
+}
 
-		// Confirmation group
-		huh.NewGroup(
-			huh.NewNote().
-				Title("Email Summary").
-				Description(fmt.Sprintf(
-					"From: %s\nTo: %s\nSubject: %s\nPriority: %s",
-					fromEmail,
-					email.To,
-					email.Subject,
-					email.Priority,
-				)),
+// Choices offered by createConfirmForm.
+const (
+	confirmSend    = "send"
+	confirmDraft   = "draft"
+	confirmDiscard = "discard"
+)
 
-			huh.NewConfirm().
-				Title("Send Email").
-				Description("Are you sure you want to send this email?").
-				Value(&email.Confirm),
-		),
-	).WithTheme(huh.ThemeCharm())
+// createConfirmForm asks whether to send, save or discard the email once all
+// fields are filled in, storing the choice in action, with a warning when
+// there are more than maxRecipients recipients, and any other warnings
+// about the recipients
+func createConfirmForm(email *EmailForm, fromEmail string, maxRecipients int, warnings []string, action *string) *huh.Form {
+	// Fields left empty are not listed
+	var lines []string
+	line := func(label, value string) {
+		if strings.TrimSpace(value) != "" {
+			lines = append(lines, label+": "+value)
+		}
+	}
+	line("From", fromEmail)
+	line("To", email.To)
+	line("Cc", email.Cc)
+	if note := bccNote(email.Bcc); note != "" {
+		lines = append(lines, note)
+	}
+	line("Reply-To", email.ReplyTo)
+	line("Subject", email.Subject)
+	line("Priority", email.Priority)
+	line("Send At", email.SendAt)
+	if len(email.Attachments) > 0 {
+		line("Attachments", strings.Join(attachmentNames(email.Attachments), ", "))
+	}
+	if email.ReadReceipt {
+		line("Read Receipt", "requested")
+	}
+	if email.Individual {
+		line("Individual", "one email per To recipient")
+	}
+	summary := strings.Join(lines, "\n")
+
+	fields := []huh.Field{
+		huh.NewNote().
+			Title("Email Summary").
+			Description(summary),
+	}
+	if count := recipientCount(email); exceedsRecipientLimit(count, maxRecipients) {
+		fields = append(fields, huh.NewNote().
+			Title("⚠️  Large recipient list").
+			Description(fmt.Sprintf("This email goes to %d recipients across To, Cc and Bcc.", count)))
+	}
+	if len(warnings) > 0 {
+		fields = append(fields, huh.NewNote().
+			Title("⚠️  Check the recipients").
+			Description(strings.Join(warnings, "\n")))
+	}
+	fields = append(fields, huh.NewSelect[string]().
+		Title("Send Email").
+		Description("Send it now, keep it for later with cleu drafts, or throw it away").
+		Options(
+			huh.NewOption("Send", confirmSend),
+			huh.NewOption("Save as Draft", confirmDraft),
+			huh.NewOption("Discard", confirmDiscard),
+		).
+		Value(action))
+
+	return huh.NewForm(huh.NewGroup(fields...)).WithTheme(huh.ThemeCharm())
+}
+
+// attachmentNames returns the file names of attachment paths.
+func attachmentNames(paths []string) []string {
+	names := make([]string, len(paths))
+	for i, path := range paths {
+		names[i] = filepath.Base(path)
+	}
+	return names
+}
+
+// bccNote explains who can see the Bcc recipients, or returns "" when there
+// are none. They get the email but are left out of the headers.
+func bccNote(bcc string) string {
+	recipients, err := parseRecipients(bcc)
+	if err != nil || len(recipients) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Bcc: %d recipient(s), hidden from the To and Cc recipients and from each other", len(recipients))
+}
+
+// recipientCount returns the number of To, Cc and Bcc recipients of email.
+// Lists that do not parse are not counted; they are rejected when sending.
+func recipientCount(email *EmailForm) int {
+	count := 0
+	for _, list := range []string{email.To, email.Cc, email.Bcc} {
+		if recipients, err := parseRecipients(list); err == nil {
+			count += len(recipients)
+		}
+	}
+	return count
+}
+
+// exceedsRecipientLimit reports whether count is over limit, where a limit
+// of 0 or less means no limit.
+func exceedsRecipientLimit(count, limit int) bool {
+	return limit > 0 && count > limit
 }
 
 // sendEmail sends the email using SMTP
-func sendEmail(email *EmailForm, host, port, username, password string) error {
+func sendEmail(email *EmailForm, config smtpConfig) error {
+	session := newSMTPSession(config)
+	defer session.close()
+	return session.sendEmail(email)
+}
+
+// sendEmail sends the email over the session
+func (s *smtpSession) sendEmail(email *EmailForm) error {
+	config := s.config
 	if !email.Confirm {
-		fmt.Println("Email sending cancelled.")
+		fmt.Fprintln(s.out, "Email sending cancelled.")
 		return nil
 	}
 
 	// Parse recipients
-	toRecipients := parseRecipients(email.To)
-	ccRecipients := parseRecipients(email.Cc)
-	bccRecipients := parseRecipients(email.Bcc)
+	toRecipients, err := parseRecipients(email.To)
+	if err != nil {
+		return fmt.Errorf("invalid To address: %w", err)
+	}
+	ccRecipients, err := parseRecipients(email.Cc)
+	if err != nil {
+		return fmt.Errorf("invalid Cc address: %w", err)
+	}
+	bccRecipients, err := parseRecipients(email.Bcc)
+	if err != nil {
+		return fmt.Errorf("invalid Bcc address: %w", err)
+	}
 
-	// Combine all recipients for SMTP
-	allRecipients := append(toRecipients, ccRecipients...)
-	allRecipients = append(allRecipients, bccRecipients...)
+	if email.Individual {
+		if len(ccRecipients) > 0 || len(bccRecipients) > 0 {
+			return fmt.Errorf("individual sending gives each To recipient their own email and cannot be combined with Cc or Bcc")
+		}
+		return s.sendIndividually(email, toRecipients)
+	}
+
+	// Combine all recipients for SMTP, which only wants the bare addresses
+	var allRecipients []string
+	for _, recipients := range [][]*mail.Address{toRecipients, ccRecipients, bccRecipients} {
+		for _, recipient := range recipients {
+			allRecipients = append(allRecipients, recipient.Address)
+		}
+	}
 
 	if len(allRecipients) == 0 {
 		return fmt.Errorf("no valid recipients found")
 	}
 
 	// Build the email message
-	message := buildEmailMessage(email, username, toRecipients, ccRecipients)
+	message, messageID, err := buildEmailMessage(email, config, toRecipients, ccRecipients)
+	if err != nil {
+		return err
+	}
+	email.MessageID = messageID
 
-	// Set up SMTP authentication
-	auth := smtp.PlainAuth("", username, password, host)
+	if config.dryRun {
+		fmt.Fprintf(s.out, "Recipients: %s\n", strings.Join(allRecipients, ", "))
+		if note := bccNote(email.Bcc); note != "" {
+			fmt.Fprintln(s.out, note)
+		}
+		fmt.Fprintln(s.out)
+		fmt.Fprint(s.out, message)
+		return nil
+	}
 
-	// Create TLS config
-	tlsConfig := &tls.Config{
-		InsecureSkipVerify: false,
-		ServerName:         host,
+	err = s.send(allRecipients, message)
+	var rejections *recipientsRejectedError
+	if errors.As(err, &rejections) && len(rejections.Accepted) > 0 {
+		fmt.Fprintf(s.out, "⚠️  Email sent to %d of %d recipient(s)\n", len(rejections.Accepted), len(allRecipients))
+		for _, rejected := range rejections.Rejected {
+			fmt.Fprintf(s.out, "❌ %s: %s\n", rejected.Address, rejected.Reason)
+		}
+		return err
+	}
+	if err != nil {
+		return err
 	}
 
-	// Connect to SMTP server
-	serverAddr := fmt.Sprintf("%s:%s", host, port)
+	fmt.Fprintf(s.out, "✅ Email sent successfully to %d recipient(s)!\n", len(allRecipients))
+	if config.collectContacts {
+		if err := collectContacts(toRecipients, ccRecipients, bccRecipients); err != nil {
+			// The email is out, the address book is only a convenience
+			fmt.Fprintf(s.out, "⚠️  Could not add the recipients to your contacts: %v\n", err)
+		}
+	}
+	return nil
+}
 
-	// Try TLS connection first
-	conn, err := tls.Dial("tcp", serverAddr, tlsConfig)
-	if err != nil {
-		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+// sendIndividually sends one email per To recipient over the session, so
+// that nobody sees the others. {{.Name}} and {{.Email}} in the subject and
+// body are filled in for each recipient.
+func (s *smtpSession) sendIndividually(email *EmailForm, toRecipients []*mail.Address) error {
+	config := s.config
+	if len(toRecipients) == 0 {
+		return fmt.Errorf("no valid recipients found")
+	}
+	messages := make([]string, len(toRecipients))
+	email.MessageIDs = make(map[string]string, len(toRecipients))
+	for i, recipient := range toRecipients {
+		personal, err := personalizeEmail(email, recipient)
+		if err != nil {
+			return err
+		}
+		var messageID string
+		if messages[i], messageID, err = buildEmailMessage(personal, config, []*mail.Address{recipient}, nil); err != nil {
+			return fmt.Errorf("could not build the email for %s: %w", recipient.Address, err)
+		}
+		email.MessageIDs[recipient.Address] = messageID
 	}
-	defer conn.Close()
 
-	// Create SMTP client
-	smtpClient, err := smtp.NewClient(conn, host)
-	if err != nil {
-		return fmt.Errorf("failed to create SMTP client: %w", err)
+	if config.dryRun {
+		for i, recipient := range toRecipients {
+			fmt.Fprintf(s.out, "Recipient: %s\n\n", recipient.Address)
+			fmt.Fprint(s.out, messages[i])
+			fmt.Fprintln(s.out)
+		}
+		return nil
 	}
-	defer smtpClient.Quit()
 
-	// Authenticate
-	if err := smtpClient.Auth(auth); err != nil {
-		return fmt.Errorf("SMTP authentication failed: %w", err)
+	// Each email that went out is reported as Accepted, so that callers such
+	// as the outbox do not send it again to someone who already got it
+	var delivered []*mail.Address
+	rejections := &recipientsRejectedError{}
+	for i, recipient := range toRecipients {
+		if err := s.send([]string{recipient.Address}, messages[i]); err != nil {
+			if errors.Is(err, errSMTPConnection) || errors.Is(err, errSMTPAuth) {
+				if len(delivered) == 0 {
+					return err
+				}
+				for _, unsent := range toRecipients[i:] {
+					rejections.Rejected = append(rejections.Rejected, rejectedRecipient{Address: unsent.Address, Reason: err.Error()})
+				}
+				break
+			}
+			fmt.Fprintf(s.out, "❌ %s: %v\n", recipient.Address, err)
+			rejected := rejectedRecipient{Address: recipient.Address, Reason: err.Error()}
+			var refused *recipientsRejectedError
+			if errors.As(err, &refused) && len(refused.Rejected) == 1 {
+				rejected = refused.Rejected[0]
+			}
+			rejections.Rejected = append(rejections.Rejected, rejected)
+			continue
+		}
+		fmt.Fprintf(s.out, "✅ Sent to %s\n", recipient.Address)
+		delivered = append(delivered, recipient)
+		rejections.Accepted = append(rejections.Accepted, recipient.Address)
+	}
+	if config.collectContacts && len(delivered) > 0 {
+		if err := collectContacts(delivered); err != nil {
+			fmt.Fprintf(s.out, "⚠️  Could not add the recipients to your contacts: %v\n", err)
+		}
+	}
+	if len(rejections.Rejected) == 0 {
+		return nil
+	}
+	if len(delivered) == 0 {
+		return fmt.Errorf("%d of %d emails could not be sent", len(toRecipients), len(toRecipients))
 	}
+	return rejections
+}
 
-	// Set sender
-	if err := smtpClient.Mail(username); err != nil {
-		return fmt.Errorf("failed to set sender: %w", err)
+// personalizeEmail returns a copy of email with {{.Name}} and {{.Email}} in
+// its subject and body filled in for recipient. Recipients without a name
+// get their address as Name.
+func personalizeEmail(email *EmailForm, recipient *mail.Address) (*EmailForm, error) {
+	data := struct{ Name, Email string }{recipient.Name, recipient.Address}
+	if data.Name == "" {
+		data.Name = recipient.Address
+	}
+	personal := *email
+	for _, field := range []*string{&personal.Subject, &personal.Body} {
+		if !strings.Contains(*field, "{{") {
+			continue
+		}
+		tmpl, err := template.New("email").Parse(*field)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse placeholders: %w", err)
+		}
+		var out strings.Builder
+		if err := tmpl.Execute(&out, data); err != nil {
+			return nil, fmt.Errorf("could not fill placeholders for %s, only {{.Name}} and {{.Email}} are available: %w", recipient.Address, err)
+		}
+		*field = out.String()
 	}
+	return &personal, nil
+}
+
+var leftoverPlaceholder = regexp.MustCompile(`\{\{.*?\}\}`)
 
-	// Set recipients
-	for _, recipient := range allRecipients {
-		if err := smtpClient.Rcpt(recipient); err != nil {
-			return fmt.Errorf("failed to set recipient %s: %w", recipient, err)
+// checkPlaceholders reports the first {{ ... }} left in subject or body,
+// usually a template variable that was never filled in.
+func checkPlaceholders(subject, body string) error {
+	if placeholder := leftoverPlaceholder.FindString(subject); placeholder != "" {
+		return fmt.Errorf("the subject still contains the placeholder %s; fill it in or pass --allow-placeholders", placeholder)
+	}
+	for i, line := range strings.Split(body, "\n") {
+		if placeholder := leftoverPlaceholder.FindString(line); placeholder != "" {
+			return fmt.Errorf("line %d of the body still contains the placeholder %s; fill it in or pass --allow-placeholders", i+1, placeholder)
 		}
 	}
+	return nil
+}
+
+var (
+	errSMTPConnection = errors.New("could not connect to the SMTP server")
+	errSMTPAuth       = errors.New("SMTP authentication failed")
+)
 
-	// Send message
-	dataWriter, err := smtpClient.Data()
+// dialSMTP connects to the SMTP server with implicit TLS or STARTTLS,
+// depending on config.tlsMode. In auto mode port 587 and 25 use STARTTLS,
+// other ports use implicit TLS and fall back to STARTTLS when the server
+// answers in plain text. Connection failures wrap errSMTPConnection.
+func dialSMTP(config smtpConfig) (*smtp.Client, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.insecure,
+		ServerName:         config.host,
+	}
+	serverAddr := net.JoinHostPort(config.host, config.port)
+	dialer, err := newDialer(config.proxy, config.dialTimeout)
 	if err != nil {
-		return fmt.Errorf("failed to get data writer: %w", err)
+		return nil, smtpConnectionError(err, config)
+	}
+
+	mode := config.tlsMode
+	if mode == smtpTLSAuto && (config.port == "587" || config.port == "25") {
+		mode = smtpTLSStartTLS
+	}
+
+	if mode != smtpTLSStartTLS {
+		conn, err := dialTLS(dialer, serverAddr, tlsConfig, config.dialTimeout)
+		if err == nil {
+			return newSMTPClient(conn, config, nil)
+		}
+		var recordErr tls.RecordHeaderError
+		if mode == smtpTLSImplicit || !errors.As(err, &recordErr) {
+			return nil, smtpConnectionError(err, config)
+		}
 	}
 
-	_, err = dataWriter.Write([]byte(message))
+	conn, err := dialer.Dial("tcp", serverAddr)
 	if err != nil {
-		return fmt.Errorf("failed to write message: %w", err)
+		return nil, smtpConnectionError(err, config)
 	}
+	return newSMTPClient(conn, config, tlsConfig)
+}
 
-	err = dataWriter.Close()
+// dialTLS connects to addr with dialer and completes the TLS handshake within
+// timeout.
+func dialTLS(dialer dialer, addr string, tlsConfig *tls.Config, timeout time.Duration) (net.Conn, error) {
+	conn, err := dialer.Dial("tcp", addr)
 	if err != nil {
-		return fmt.Errorf("failed to close data writer: %w", err)
+		return nil, err
+	}
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
 	}
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
 
-	fmt.Printf("✅ Email sent successfully to %d recipient(s)!\n", len(allRecipients))
-	return nil
+// newSMTPClient reads the server greeting on conn and, when startTLS is not
+// nil, upgrades the session with STARTTLS. Both must finish within the dial
+// timeout.
+func newSMTPClient(conn net.Conn, config smtpConfig, startTLS *tls.Config) (*smtp.Client, error) {
+	if config.dialTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(config.dialTimeout))
+	}
+
+	smtpClient, err := smtp.NewClient(conn, config.host)
+	if err != nil {
+		conn.Close()
+		return nil, smtpConnectionError(err, config)
+	}
+	if startTLS != nil {
+		if ok, _ := smtpClient.Extension("STARTTLS"); !ok {
+			smtpClient.Close()
+			return nil, fmt.Errorf("%w: %s does not support STARTTLS, set CLEU_SMTP_TLS=implicit if it expects TLS", errSMTPConnection, conn.RemoteAddr())
+		}
+		if err := smtpClient.StartTLS(startTLS); err != nil {
+			smtpClient.Close()
+			return nil, fmt.Errorf("%w: STARTTLS failed: %v", errSMTPConnection, err)
+		}
+	}
+
+	conn.SetDeadline(time.Time{})
+	return smtpClient, nil
+}
+
+// smtpConnectionError explains common connection failures in terms of the
+// settings that are most likely wrong.
+func smtpConnectionError(err error, config smtpConfig) error {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &dnsErr):
+		return fmt.Errorf("%w: could not resolve %q, check SMTP_HOST: %v", errSMTPConnection, config.host, err)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return fmt.Errorf("%w: %s:%s refused the connection, check SMTP_HOST and SMTP_PORT", errSMTPConnection, config.host, config.port)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return fmt.Errorf("%w: no answer from %s:%s within %s, check SMTP_HOST and SMTP_PORT", errSMTPConnection, config.host, config.port, config.dialTimeout)
+	default:
+		return fmt.Errorf("%w: %v", errSMTPConnection, err)
+	}
 }
 
-// parseRecipients parses comma-separated email addresses
-func parseRecipients(recipients string) []string {
+// parseRecipients parses a comma-separated RFC 5322 address list, so quoted
+// display names containing commas stay intact
+func parseRecipients(recipients string) ([]*mail.Address, error) {
+	recipients = strings.Trim(recipients, ", \t")
 	if recipients == "" {
-		return nil
+		return nil, nil
 	}
+	return mail.ParseAddressList(recipients)
+}
+
+// validateHeaderValue rejects values that would inject extra header lines
+func validateHeaderValue(field, value string) error {
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("%s must not contain line breaks", field)
+	}
+	return nil
+}
 
-	var parsed []string
-	for _, recipient := range strings.Split(recipients, ",") {
-		recipient = strings.TrimSpace(recipient)
-		if recipient != "" {
-			parsed = append(parsed, recipient)
+// validateRecipients checks each address in a recipient field, allowing the
+// field itself to be empty
+func validateRecipients(field, recipients string) error {
+	if err := validateHeaderValue(field, recipients); err != nil {
+		return err
+	}
+	addresses, err := parseRecipients(recipients)
+	if err != nil {
+		return fmt.Errorf("invalid %s address list: %v", field, err)
+	}
+	for _, address := range addresses {
+		if !strings.Contains(address.Address, ".") {
+			return fmt.Errorf("invalid %s address: %s", field, address.Address)
 		}
 	}
-	return parsed
+	return nil
 }
 
-// buildEmailMessage constructs the email message with proper headers
-func buildEmailMessage(email *EmailForm, fromEmail string, toRecipients, ccRecipients []string) string {
+// buildEmailMessage constructs the email message with proper headers,
+// appending signature unless the email opted out. It also returns the
+// message's Message-ID
+func buildEmailMessage(email *EmailForm, config smtpConfig, toRecipients, ccRecipients []*mail.Address) (string, string, error) {
+	fromEmail, signature := config.username, config.signature
+
+	custom, err := parseCustomHeaders(append(append([]string{}, config.headers...), email.Headers...), email.AllowHeaderOverride)
+	if err != nil {
+		return "", "", fmt.Errorf("refusing to build message: %w", err)
+	}
+
+	// Refuse anything that could inject extra headers
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{"From", fromEmail},
+		{"To", email.To},
+		{"Cc", email.Cc},
+		{"Bcc", email.Bcc},
+		{"Reply-To", email.ReplyTo},
+		{"Subject", email.Subject},
+	}
+	for _, field := range fields {
+		if err := validateHeaderValue(field.name, field.value); err != nil {
+			return "", "", fmt.Errorf("refusing to build message: %w", err)
+		}
+	}
+	if !email.AllowPlaceholders {
+		if err := checkPlaceholders(email.Subject, email.Body); err != nil {
+			return "", "", fmt.Errorf("refusing to build message: %w", err)
+		}
+	}
+
 	var message strings.Builder
+	// writeHeader adds a built-in header unless a custom one replaces it
+	writeHeader := func(name, value string) {
+		for _, header := range custom {
+			if strings.EqualFold(header.Name, name) {
+				return
+			}
+		}
+		message.WriteString(name + ": " + value + "\r\n")
+	}
 
 	// Headers
-	message.WriteString(fmt.Sprintf("From: %s\r\n", fromEmail))
-	message.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(toRecipients, ", ")))
+	writeHeader("From", encodeAddress(fromEmail))
+	writeHeader("To", encodeAddressList(toRecipients))
 
 	if len(ccRecipients) > 0 {
-		message.WriteString(fmt.Sprintf("Cc: %s\r\n", strings.Join(ccRecipients, ", ")))
+		writeHeader("Cc", encodeAddressList(ccRecipients))
 	}
 
-	message.WriteString(fmt.Sprintf("Subject: %s\r\n", email.Subject))
-	message.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123Z)))
-	message.WriteString("MIME-Version: 1.0\r\n")
-	message.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	if strings.TrimSpace(email.ReplyTo) != "" {
+		replyTo, err := parseRecipients(email.ReplyTo)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid Reply-To address: %w", err)
+		}
+		writeHeader("Reply-To", encodeAddressList(replyTo))
+	}
+
+	writeHeader("Subject", encodeHeader(email.Subject))
+	writeHeader("Date", time.Now().Format(time.RFC1123Z))
+	// On the domain of FROM_EMAIL, which may differ from the login's
+	messageID := newMessageID(cmp.Or(config.from, fromEmail))
+	for _, header := range custom {
+		if strings.EqualFold(header.Name, "Message-ID") {
+			messageID = header.Value
+		}
+	}
+	writeHeader("Message-ID", messageID)
 
 	// Priority header
 	switch email.Priority {
 	case "high":
-		message.WriteString("X-Priority: 1\r\n")
-		message.WriteString("Importance: High\r\n")
+		writeHeader("X-Priority", "1")
+		writeHeader("Importance", "High")
 	case "low":
-		message.WriteString("X-Priority: 5\r\n")
-		message.WriteString("Importance: Low\r\n")
+		writeHeader("X-Priority", "5")
+		writeHeader("Importance", "Low")
 	default:
-		message.WriteString("X-Priority: 3\r\n")
-		message.WriteString("Importance: Normal\r\n")
+		writeHeader("X-Priority", "3")
+		writeHeader("Importance", "Normal")
+	}
+
+	// Read receipt, sent back to the sender
+	if email.ReadReceipt {
+		writeHeader("Disposition-Notification-To", encodeAddress(fromEmail))
+		writeHeader("Return-Receipt-To", encodeAddress(fromEmail))
 	}
 
 	// User-Agent
-	message.WriteString("User-Agent: CLI-Email-Client\r\n")
+	writeHeader("User-Agent", "CLI-Email-Client")
+
+	// Custom headers, then the MIME structure
+	for _, header := range custom {
+		message.WriteString(header.Name + ": " + encodeHeader(header.Value) + "\r\n")
+	}
+	message.WriteString("MIME-Version: 1.0\r\n")
+
+	if email.NoSignature {
+		signature = ""
+	}
+
+	// Body, with an HTML alternative if requested
+	contentType := "text/plain; charset=UTF-8"
+	body := appendSignature(email.Body, signature) + "\r\n"
+	if email.HTML {
+		if alternative, boundary, err := buildAlternativeBody(email.Body, signature); err == nil {
+			contentType = fmt.Sprintf("multipart/alternative; boundary=\"%s\"", boundary)
+			body = alternative
+		} else {
+			logger.Printf("SMTP: could not render the body as HTML, sending plain text only: %v", err)
+		}
+	}
+
+	// Attachments wrap the body in multipart/mixed
+	if len(email.Attachments) > 0 {
+		mixed, boundary, err := buildMixedBody(contentType, body, email.Attachments)
+		if err != nil {
+			return "", "", err
+		}
+		contentType = fmt.Sprintf("multipart/mixed; boundary=\"%s\"", boundary)
+		body = mixed
+	}
+
+	message.WriteString("Content-Type: " + contentType + "\r\n")
 
 	// Empty line to separate headers from body
 	message.WriteString("\r\n")
+	message.WriteString(body)
 
-	// Body
-	message.WriteString(email.Body)
-	message.WriteString("\r\n")
+	return message.String(), messageID, nil
+}
+
+// newMessageID returns a unique RFC 5322 Message-ID on the domain of from,
+// like <1718000000.3f9a0c2b7d15e6a4@example.com>
+func newMessageID(from string) string {
+	domain := "localhost"
+	if address, err := mail.ParseAddress(from); err == nil {
+		from = address.Address
+	}
+	if i := strings.LastIndex(from, "@"); i >= 0 && i < len(from)-1 {
+		domain = from[i+1:]
+	}
+	token := make([]byte, 8)
+	rand.Read(token)
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().Unix(), hex.EncodeToString(token), domain)
+}
+
+// encodeHeader RFC 2047-encodes a header value containing non-ASCII
+// characters, leaving pure-ASCII values untouched
+func encodeHeader(value string) string {
+	nonASCII := countNonASCII(value)
+	if nonASCII == 0 {
+		return value
+	}
+	// B encoding is shorter when most of the text needs escaping
+	if nonASCII > len(value)/2 {
+		return mime.BEncoding.Encode("UTF-8", value)
+	}
+	return mime.QEncoding.Encode("UTF-8", value)
+}
+
+// countNonASCII returns the number of non-ASCII bytes in s
+func countNonASCII(s string) int {
+	count := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			count++
+		}
+	}
+	return count
+}
+
+// encodeAddress encodes a non-ASCII display name in an address
+func encodeAddress(address string) string {
+	if countNonASCII(address) == 0 {
+		return address
+	}
+	parsed, err := mail.ParseAddress(address)
+	if err != nil || parsed.Name == "" {
+		return address
+	}
+	return (&mail.Address{Name: parsed.Name, Address: parsed.Address}).String()
+}
+
+// encodeAddressList formats parsed addresses for a header, keeping display
+// names (quoted and encoded as needed)
+func encodeAddressList(addresses []*mail.Address) string {
+	encoded := make([]string, len(addresses))
+	for i, address := range addresses {
+		if address.Name == "" {
+			encoded[i] = address.Address
+		} else {
+			encoded[i] = address.String()
+		}
+	}
+	return strings.Join(encoded, ", ")
+}
+
+// buildMixedBody returns a multipart/mixed body holding body, of the given
+// content type, followed by the files at paths as base64 attachments, along
+// with its boundary.
+func buildMixedBody(contentType, body string, paths []string) (string, string, error) {
+	var parts bytes.Buffer
+	writer := multipart.NewWriter(&parts)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType)
+	partWriter, err := writer.CreatePart(header)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := partWriter.Write([]byte(body)); err != nil {
+		return "", "", err
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", "", fmt.Errorf("could not read attachment: %w", err)
+		}
+		name := filepath.Base(path)
+		fileType := mime.TypeByExtension(filepath.Ext(name))
+		if fileType == "" {
+			fileType = "application/octet-stream"
+		}
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", fileType)
+		header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+		header.Set("Content-Transfer-Encoding", "base64")
+		partWriter, err := writer.CreatePart(header)
+		if err != nil {
+			return "", "", err
+		}
+		// Base64 lines are kept to 76 characters
+		encoded := base64.StdEncoding.EncodeToString(data)
+		var lines strings.Builder
+		for len(encoded) > 76 {
+			lines.WriteString(encoded[:76] + "\r\n")
+			encoded = encoded[76:]
+		}
+		lines.WriteString(encoded + "\r\n")
+		if _, err := partWriter.Write([]byte(lines.String())); err != nil {
+			return "", "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return "", "", err
+	}
+	return parts.String(), writer.Boundary(), nil
+}
+
+// buildAlternativeBody renders the markdown body to HTML and returns a
+// multipart/alternative body holding both versions, along with its boundary.
+// The signature is added to each version after rendering
+func buildAlternativeBody(body, signature string) (string, string, error) {
+	var html bytes.Buffer
+	html.WriteString("<html><body>\n")
+	if err := goldmark.Convert([]byte(body), &html); err != nil {
+		return "", "", err
+	}
+	html.WriteString(htmlSignature(signature))
+	html.WriteString("</body></html>\n")
+	body = appendSignature(body, signature)
+
+	var parts bytes.Buffer
+	writer := multipart.NewWriter(&parts)
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", body},
+		{"text/html; charset=UTF-8", html.String()},
+	} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		partWriter, err := writer.CreatePart(header)
+		if err != nil {
+			return "", "", err
+		}
+		encoder := quotedprintable.NewWriter(partWriter)
+		if _, err := encoder.Write([]byte(part.content)); err != nil {
+			return "", "", err
+		}
+		if err := encoder.Close(); err != nil {
+			return "", "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return "", "", err
+	}
 
-	return message.String()
+	return parts.String(), writer.Boundary(), nil
 }
@@ -0,0 +1,1077 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/urfave/cli/v3"
+	"golang.org/x/term"
+)
+
+func TestCheckPlaceholders(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject string
+		body    string
+		wantErr string
+	}{
+		{name: "none", subject: "Hello", body: "Hi Bob,\nsee you"},
+		{name: "in the subject", subject: "Hello {{.Name}}", body: "Hi", wantErr: "the subject still contains the placeholder {{.Name}}"},
+		{name: "in the body", subject: "Hello", body: "Hi,\n\nyour code is {{ code }}", wantErr: "line 3 of the body still contains the placeholder {{ code }}"},
+		{name: "single braces", subject: "{x}", body: "a { b } c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPlaceholders(tt.subject, tt.body)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildEmailMessageAllowPlaceholders(t *testing.T) {
+	config := smtpConfig{username: "me@example.com", from: "me@example.com"}
+	recipients := []*mail.Address{{Address: "bob@example.com"}}
+	tests := []struct {
+		name    string
+		allow   bool
+		wantErr bool
+	}{
+		{name: "refused by default", wantErr: true},
+		{name: "allowed", allow: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := &EmailForm{To: "bob@example.com", Subject: "Hi {{.Name}}", Body: "Hello", AllowPlaceholders: tt.allow}
+			_, _, err := buildEmailMessage(email, config, recipients, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want an error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSendIndividuallyNamesRecipientOnBuildError(t *testing.T) {
+	config := smtpConfig{username: "me@example.com", from: "me@example.com", dryRun: true, quiet: true}
+	session := newSMTPSession(config)
+	session.out = io.Discard
+	defer session.close()
+
+	// The template writes out a placeholder that survives personalizing
+	email := &EmailForm{
+		To:         "alice@example.com",
+		Subject:    "Hello",
+		Body:       `Hi {{"{{"}}code}}`,
+		Individual: true,
+		Confirm:    true,
+	}
+	err := session.sendEmail(email)
+	if err == nil || !strings.Contains(err.Error(), "alice@example.com") {
+		t.Errorf("error = %v, want it to name alice@example.com", err)
+	}
+}
+
+func TestEmailFormUnmarshalAttachments(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want []string
+	}{
+		{name: "list", json: `{"To":"a@example.com","Attachments":["a.pdf","b.png"]}`, want: []string{"a.pdf", "b.png"}},
+		{name: "old comma-separated string", json: `{"To":"a@example.com","Attachments":"a.pdf, b.png,,a.pdf"}`, want: []string{"a.pdf", "b.png"}},
+		{name: "old empty string", json: `{"To":"a@example.com","Attachments":""}`},
+		{name: "null", json: `{"To":"a@example.com","Attachments":null}`},
+		{name: "missing", json: `{"To":"a@example.com"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var email EmailForm
+			if err := json.Unmarshal([]byte(tt.json), &email); err != nil {
+				t.Fatal(err)
+			}
+			if email.To != "a@example.com" {
+				t.Errorf("To = %q, the other fields were not read", email.To)
+			}
+			if !slices.Equal(email.Attachments, tt.want) {
+				t.Errorf("Attachments = %q, want %q", email.Attachments, tt.want)
+			}
+		})
+	}
+
+	var email EmailForm
+	if err := json.Unmarshal([]byte(`{"Attachments":42}`), &email); err == nil {
+		t.Error("a number was accepted as attachments")
+	}
+}
+
+func TestOldDraftLoads(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir, err := draftsDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	old := `{"id":"old","saved":"2026-01-02T15:04:05Z","email":{"To":"a@example.com","Subject":"Old","Attachments":"/tmp/a.pdf"}}`
+	if err := os.WriteFile(filepath.Join(dir, "old.json"), []byte(old), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	drafts, err := loadDrafts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drafts) != 1 || !slices.Equal(drafts[0].Email.Attachments, []string{"/tmp/a.pdf"}) {
+		t.Errorf("drafts = %+v", drafts)
+	}
+}
+
+func TestMergeRecipients(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   string
+	}{
+		{name: "repeated flags", values: []string{"a@example.com", "b@example.com"}, want: "<a@example.com>, <b@example.com>"},
+		{name: "comma-separated and repeated", values: []string{"a@example.com, b@example.com", "c@example.com"}, want: "<a@example.com>, <b@example.com>, <c@example.com>"},
+		{name: "duplicates in any case", values: []string{"a@example.com", "A@Example.com", "Ann <a@example.com>"}, want: "<a@example.com>"},
+		{name: "names are kept", values: []string{"Ann <a@example.com>"}, want: `"Ann" <a@example.com>`},
+		{name: "empty", values: nil, want: ""},
+		{name: "invalid is left for validation", values: []string{"not an address", "b@example.com"}, want: "not an address, b@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeRecipients(tt.values); got != tt.want {
+				t.Errorf("mergeRecipients(%q) = %q, want %q", tt.values, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergePaths(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   []string
+	}{
+		{values: []string{"a.pdf", "b.pdf"}, want: []string{"a.pdf", "b.pdf"}},
+		{values: []string{"a.pdf", "./a.pdf", " ", "dir/../a.pdf"}, want: []string{"a.pdf"}},
+		{values: nil, want: nil},
+	}
+	for _, tt := range tests {
+		if got := mergePaths(tt.values); !slices.Equal(got, tt.want) {
+			t.Errorf("mergePaths(%q) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
+
+var messageIDSyntax = regexp.MustCompile(`^<[A-Za-z0-9.!#$%&'*+/=?^_{|}~-]+@[A-Za-z0-9.-]+>$`)
+
+func TestBuildEmailMessageMessageID(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     smtpConfig
+		wantDomain string
+	}{
+		{name: "FROM_EMAIL domain", config: smtpConfig{username: "login@provider.example", from: "me@example.org"}, wantDomain: "example.org"},
+		{name: "login without FROM_EMAIL", config: smtpConfig{username: "login@provider.example"}, wantDomain: "provider.example"},
+		{name: "login without a domain", config: smtpConfig{username: "login"}, wantDomain: "localhost"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := &EmailForm{To: "bob@example.com", Subject: "Hi", Body: "Hello"}
+			message, messageID, err := buildEmailMessage(email, tt.config, []*mail.Address{{Address: "bob@example.com"}}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !messageIDSyntax.MatchString(messageID) {
+				t.Errorf("Message-ID %q is not valid", messageID)
+			}
+			if !strings.HasSuffix(messageID, "@"+tt.wantDomain+">") {
+				t.Errorf("Message-ID %q is not on %s", messageID, tt.wantDomain)
+			}
+			parsed, err := mail.ReadMessage(strings.NewReader(message))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := parsed.Header.Get("Message-ID"); got != messageID {
+				t.Errorf("header Message-ID = %q, want the returned %q", got, messageID)
+			}
+		})
+	}
+}
+
+func TestSendIndividuallyRecordsMessageIDs(t *testing.T) {
+	server, config := newMockSMTP(t)
+	session := newSMTPSession(config)
+	session.out = io.Discard
+	defer session.close()
+
+	email := &EmailForm{
+		To:         "alice@example.com, bob@example.com",
+		Subject:    "Hi {{.Name}}",
+		Body:       "Hello",
+		Individual: true,
+		Confirm:    true,
+	}
+	if err := session.sendEmail(email); err != nil {
+		t.Fatal(err)
+	}
+	if len(email.MessageIDs) != 2 {
+		t.Fatalf("MessageIDs = %v, want one per recipient", email.MessageIDs)
+	}
+	for _, message := range server.received() {
+		parsed, err := mail.ReadMessage(strings.NewReader(message.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		recipient := message.recipients[0]
+		if got, want := parsed.Header.Get("Message-ID"), email.MessageIDs[recipient]; got != want {
+			t.Errorf("%s got Message-ID %q, recorded %q", recipient, got, want)
+		}
+	}
+	if email.MessageIDs["alice@example.com"] == email.MessageIDs["bob@example.com"] {
+		t.Error("both copies share a Message-ID")
+	}
+}
+
+func TestContactsFailureOnlyWarns(t *testing.T) {
+	tests := []struct {
+		name  string
+		email EmailForm
+	}{
+		{name: "one email", email: EmailForm{To: "alice@example.com", Subject: "Hi", Body: "Hello", Confirm: true}},
+		{name: "individual emails", email: EmailForm{To: "alice@example.com, bob@example.com", Subject: "Hi", Body: "Hello", Individual: true, Confirm: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			path, err := contactsPath()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// An address book that cannot be parsed
+			if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte("not json"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			server, config := newMockSMTP(t)
+			config.collectContacts = true
+			session := newSMTPSession(config)
+			var out strings.Builder
+			session.out = &out
+			defer session.close()
+
+			if err := session.sendEmail(&tt.email); err != nil {
+				t.Fatalf("sendEmail failed after sending: %v", err)
+			}
+			if got := server.copiesFor("alice@example.com"); got != 1 {
+				t.Errorf("alice got %d copies, want 1", got)
+			}
+			if !strings.Contains(out.String(), "Could not add the recipients to your contacts") {
+				t.Errorf("no warning in the output:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestBuildEmailMessageHTML(t *testing.T) {
+	config := smtpConfig{username: "me@example.com", from: "me@example.com"}
+	tests := []struct {
+		name      string
+		html      bool
+		wantType  string
+		wantParts map[string]string
+	}{
+		{name: "plain text by default", wantType: "text/plain"},
+		{
+			name:     "markdown as HTML",
+			html:     true,
+			wantType: "multipart/alternative",
+			wantParts: map[string]string{
+				"text/plain": "Hello **world**",
+				"text/html":  "<strong>world</strong>",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := &EmailForm{To: "bob@example.com", Subject: "Hi", Body: "Hello **world**", HTML: tt.html}
+			message, _, err := buildEmailMessage(email, config, []*mail.Address{{Address: "bob@example.com"}}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, err := mail.ReadMessage(strings.NewReader(message))
+			if err != nil {
+				t.Fatal(err)
+			}
+			mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+			if err != nil || mediaType != tt.wantType {
+				t.Fatalf("Content-Type = %q, want %s", msg.Header.Get("Content-Type"), tt.wantType)
+			}
+			if tt.wantParts == nil {
+				return
+			}
+			reader := multipart.NewReader(msg.Body, params["boundary"])
+			found := 0
+			for {
+				part, err := reader.NextPart()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+				content, _ := io.ReadAll(part)
+				if want, ok := tt.wantParts[partType]; !ok || !strings.Contains(string(content), want) {
+					t.Errorf("%s part = %q, want %q", partType, content, want)
+				}
+				found++
+			}
+			if found != len(tt.wantParts) {
+				t.Errorf("%d parts, want %d", found, len(tt.wantParts))
+			}
+		})
+	}
+}
+
+func TestBuildAlternativeBodySignature(t *testing.T) {
+	body, boundary, err := buildAlternativeBody("Hello", "Alice\nExample Inc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if boundary == "" || !strings.Contains(body, "--"+boundary+"--") {
+		t.Fatalf("body is not closed with its boundary %q", boundary)
+	}
+	for _, want := range []string{"--=20\r\nAlice", `<div class=3D"signature">`, "Alice<br>"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body does not contain %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestEncodeHeader(t *testing.T) {
+	tests := []struct {
+		value  string
+		prefix string
+	}{
+		{value: "Hello", prefix: "Hello"},
+		{value: "Café au lait", prefix: "=?UTF-8?q?"},
+		{value: "日本語の件名", prefix: "=?UTF-8?b?"},
+		{value: "", prefix: ""},
+	}
+	var decoder mime.WordDecoder
+	for _, tt := range tests {
+		encoded := encodeHeader(tt.value)
+		if !strings.HasPrefix(encoded, tt.prefix) {
+			t.Errorf("encodeHeader(%q) = %q, want it to start with %q", tt.value, encoded, tt.prefix)
+		}
+		if countNonASCII(encoded) != 0 {
+			t.Errorf("encodeHeader(%q) = %q is not ASCII", tt.value, encoded)
+		}
+		if decoded, err := decoder.DecodeHeader(encoded); err != nil || decoded != tt.value {
+			t.Errorf("%q decodes to %q, %v", encoded, decoded, err)
+		}
+	}
+}
+
+func TestNonASCIIHeadersReachTheServerEncoded(t *testing.T) {
+	tests := []struct {
+		name        string
+		email       EmailForm
+		wantSubject string
+		wantTo      string
+	}{
+		{name: "ASCII", email: EmailForm{To: "bob@example.com", Subject: "Lunch"}, wantSubject: "Lunch", wantTo: "bob@example.com"},
+		{name: "accented subject", email: EmailForm{To: "bob@example.com", Subject: "Réunion à 14h"}, wantSubject: "Réunion à 14h", wantTo: "bob@example.com"},
+		{name: "display name", email: EmailForm{To: "Zoë Müller <zoe@example.com>", Subject: "Grüße"}, wantSubject: "Grüße", wantTo: "Zoë Müller <zoe@example.com>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.email.Body = "Hello"
+			server, _, err := sendThroughMock(t, &tt.email, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			received := server.received()
+			if len(received) != 1 {
+				t.Fatalf("the server got %d messages", len(received))
+			}
+			header, _, _ := strings.Cut(received[0].data, "\r\n\r\n")
+			if countNonASCII(header) != 0 {
+				t.Errorf("the header is not ASCII:\n%s", header)
+			}
+			msg := received[0].parsed(t)
+			var decoder mime.WordDecoder
+			if subject, _ := decoder.DecodeHeader(msg.Header.Get("Subject")); subject != tt.wantSubject {
+				t.Errorf("Subject = %q, want %q", subject, tt.wantSubject)
+			}
+			to, err := msg.Header.AddressList("To")
+			if err != nil || len(to) != 1 {
+				t.Fatalf("To = %q: %v", msg.Header.Get("To"), err)
+			}
+			got := to[0].Address
+			if to[0].Name != "" {
+				got = to[0].Name + " <" + got + ">"
+			}
+			if got != tt.wantTo {
+				t.Errorf("To = %q, want %q", got, tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestValidateHeaderValue(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantErr bool
+	}{
+		{value: "Hello"},
+		{value: ""},
+		{value: "Hi\r\nBcc: evil@example.com", wantErr: true},
+		{value: "Hi\nBcc: evil@example.com", wantErr: true},
+		{value: "Hi\r", wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := validateHeaderValue("Subject", tt.value); (err != nil) != tt.wantErr {
+			t.Errorf("validateHeaderValue(%q) = %v, want an error: %v", tt.value, err, tt.wantErr)
+		}
+	}
+}
+
+func TestHeaderInjectionIsRefused(t *testing.T) {
+	tests := []struct {
+		name    string
+		email   EmailForm
+		wantErr string
+	}{
+		{name: "subject", email: EmailForm{To: "bob@example.com", Subject: "Hi\r\nBcc: evil@example.com"}, wantErr: "Subject must not contain line breaks"},
+		{name: "reply-to", email: EmailForm{To: "bob@example.com", Subject: "Hi", ReplyTo: "me@example.com\nBcc: evil@example.com"}, wantErr: "line breaks"},
+		{name: "to", email: EmailForm{To: "bob@example.com\r\nBcc: evil@example.com", Subject: "Hi"}},
+		{name: "cc", email: EmailForm{To: "bob@example.com", Cc: "carol@example.com\nBcc: evil@example.com", Subject: "Hi"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.email.Body = "Hello"
+			server, _, err := sendThroughMock(t, &tt.email, nil)
+			if err == nil {
+				t.Fatal("the email was sent")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+			if got := len(server.received()); got != 0 {
+				t.Errorf("the server got %d messages", got)
+			}
+		})
+	}
+}
+
+func TestValidateRecipients(t *testing.T) {
+	tests := []struct {
+		field   string
+		value   string
+		wantErr string
+	}{
+		{field: "Cc", value: ""},
+		{field: "Cc", value: "carol@example.com"},
+		{field: "Cc", value: "carol@example.com, Dan <dan@example.org>"},
+		{field: "Cc", value: "carol", wantErr: "invalid Cc address list"},
+		{field: "Cc", value: "carol@localhost", wantErr: "invalid Cc address: carol@localhost"},
+		{field: "Bcc", value: "audit@example.com"},
+		{field: "Bcc", value: "audit@example.com, not an address", wantErr: "invalid Bcc address list"},
+		{field: "Bcc", value: "audit@example.com\r\nSubject: x", wantErr: "Bcc must not contain line breaks"},
+	}
+	for _, tt := range tests {
+		err := validateRecipients(tt.field, tt.value)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("validateRecipients(%s, %q) = %v", tt.field, tt.value, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("validateRecipients(%s, %q) = %v, want %q", tt.field, tt.value, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateFlagEmailChecksCcAndBcc(t *testing.T) {
+	tests := []struct {
+		name    string
+		cc, bcc string
+		wantErr string
+	}{
+		{name: "valid", cc: "carol@example.com", bcc: "audit@example.com"},
+		{name: "bad cc", cc: "carol", wantErr: "Cc"},
+		{name: "bad bcc", bcc: "audit@", wantErr: "Bcc"},
+	}
+	for _, tt := range tests {
+		email := &EmailForm{To: "bob@example.com", Cc: tt.cc, Bcc: tt.bcc, Subject: "Hi", Body: "Hello"}
+		err := validateFlagEmail(email)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: error = %v, want a mention of %s", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCcAndBccDelivery(t *testing.T) {
+	email := &EmailForm{To: "bob@example.com", Cc: "carol@example.com", Bcc: "audit@example.com", Subject: "Hi", Body: "Hello"}
+	server, _, err := sendThroughMock(t, email, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, address := range []string{"bob@example.com", "carol@example.com", "audit@example.com"} {
+		if got := server.copiesFor(address); got != 1 {
+			t.Errorf("%s got %d copies, want 1", address, got)
+		}
+	}
+	msg := server.received()[0].parsed(t)
+	if got := msg.Header.Get("Cc"); got != "carol@example.com" {
+		t.Errorf("Cc = %q", got)
+	}
+	if got := msg.Header.Get("Bcc"); got != "" {
+		t.Errorf("Bcc is in the header: %q", got)
+	}
+}
+
+func TestParseRecipients(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{value: "", want: nil},
+		{value: " , ", want: nil},
+		{value: "bob@example.com", want: []string{"bob@example.com"}},
+		{value: "bob@example.com, carol@example.com,", want: []string{"bob@example.com", "carol@example.com"}},
+		{value: `"Doe, Jane" <jane@example.com>, bob@example.com`, want: []string{"Doe, Jane <jane@example.com>", "bob@example.com"}},
+		{value: "=?UTF-8?q?Zo=C3=AB?= <zoe@example.com>", want: []string{"Zoë <zoe@example.com>"}},
+		{value: "Jane <jane@example.com", wantErr: true},
+		{value: "bob@example.com carol@example.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		addresses, err := parseRecipients(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("parseRecipients(%q) error = %v", tt.value, err)
+		}
+		var got []string
+		for _, address := range addresses {
+			if address.Name == "" {
+				got = append(got, address.Address)
+			} else {
+				got = append(got, address.Name+" <"+address.Address+">")
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseRecipients(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestQuotedDisplayNamesAreOneRecipient(t *testing.T) {
+	email := &EmailForm{To: `"Doe, Jane" <jane@example.com>, bob@example.com`, Subject: "Hi", Body: "Hello"}
+	server, _, err := sendThroughMock(t, email, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := server.received()
+	if len(received) != 1 || !slices.Equal(received[0].recipients, []string{"jane@example.com", "bob@example.com"}) {
+		t.Fatalf("recipients = %v", received)
+	}
+	to, err := received[0].parsed(t).Header.AddressList("To")
+	if err != nil || len(to) != 2 || to[0].Name != "Doe, Jane" {
+		t.Errorf("To = %v, %v", to, err)
+	}
+}
+
+func TestDialSMTPTLSModes(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  func(t *testing.T) (*mockSMTP, smtpConfig)
+		mode    string
+		wantErr string
+	}{
+		{name: "implicit", server: func(t *testing.T) (*mockSMTP, smtpConfig) { return newMockSMTP(t) }, mode: smtpTLSImplicit},
+		{name: "auto on a TLS port", server: func(t *testing.T) (*mockSMTP, smtpConfig) { return newMockSMTP(t) }, mode: smtpTLSAuto},
+		{name: "starttls", server: func(t *testing.T) (*mockSMTP, smtpConfig) { return newPlainMockSMTP(t, true) }, mode: smtpTLSStartTLS},
+		{name: "auto falls back to starttls", server: func(t *testing.T) (*mockSMTP, smtpConfig) { return newPlainMockSMTP(t, true) }, mode: smtpTLSAuto},
+		{
+			name:    "no starttls offered",
+			server:  func(t *testing.T) (*mockSMTP, smtpConfig) { return newPlainMockSMTP(t, false) },
+			mode:    smtpTLSStartTLS,
+			wantErr: "does not support STARTTLS",
+		},
+		{
+			name:    "implicit on a plain server",
+			server:  func(t *testing.T) (*mockSMTP, smtpConfig) { return newPlainMockSMTP(t, true) },
+			mode:    smtpTLSImplicit,
+			wantErr: "could not connect",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			server, config := tt.server(t)
+			config.tlsMode = tt.mode
+			config.dialTimeout = time.Second
+			err := sendEmail(&EmailForm{To: "bob@example.com", Subject: "Hi", Body: "Hello", Confirm: true}, config)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := server.copiesFor("bob@example.com"); got != 1 {
+					t.Errorf("bob got %d copies, want 1", got)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSMTPTLSSetting(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "", want: smtpTLSAuto},
+		{value: "STARTTLS", want: smtpTLSStartTLS},
+		{value: "implicit", want: smtpTLSImplicit},
+		{value: "ssl", wantErr: true},
+	}
+	for _, tt := range tests {
+		setServerEnv(t)
+		t.Setenv("CLEU_SMTP_TLS", tt.value)
+		_, config, err := loadConfigs(smtpFlags())
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("CLEU_SMTP_TLS=%q: error = %v", tt.value, err)
+		}
+		if err == nil && config.tlsMode != tt.want {
+			t.Errorf("CLEU_SMTP_TLS=%q gives %q, want %q", tt.value, config.tlsMode, tt.want)
+		}
+	}
+}
+
+func TestSMTPConnectionError(t *testing.T) {
+	config := smtpConfig{host: "smtp.example.com", port: "465", dialTimeout: 5 * time.Second}
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "dns", err: &net.DNSError{Err: "no such host", Name: "smtp.example.com", IsNotFound: true}, want: `could not resolve "smtp.example.com", check SMTP_HOST`},
+		{name: "refused", err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, want: "smtp.example.com:465 refused the connection"},
+		{name: "timeout", err: timeoutError{}, want: "no answer from smtp.example.com:465 within 5s"},
+		{name: "other", err: errors.New("boom"), want: "could not connect to the SMTP server: boom"},
+	}
+	for _, tt := range tests {
+		err := smtpConnectionError(tt.err, config)
+		if !errors.Is(err, errSMTPConnection) {
+			t.Errorf("%s: %v does not wrap errSMTPConnection", tt.name, err)
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %q, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestSMTPFailuresAreExplained(t *testing.T) {
+	// A port nothing listens on
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, closedPort, _ := net.SplitHostPort(closed.Addr().String())
+	closed.Close()
+
+	// A server that accepts connections and never answers
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	go func() {
+		for {
+			conn, err := silent.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	_, silentPort, _ := net.SplitHostPort(silent.Addr().String())
+
+	tests := []struct {
+		name      string
+		configure func(*mockSMTP, *smtpConfig)
+		wantIs    error
+		want      string
+	}{
+		{name: "refused", configure: func(_ *mockSMTP, c *smtpConfig) { c.port = closedPort }, wantIs: errSMTPConnection, want: "refused the connection"},
+		{name: "no answer", configure: func(_ *mockSMTP, c *smtpConfig) { c.port = silentPort }, wantIs: errSMTPConnection, want: "within 200ms"},
+		{name: "bad password", configure: func(m *mockSMTP, _ *smtpConfig) { m.refuseAuth = true }, wantIs: errSMTPAuth, want: "check SMTP_USERNAME and SMTP_PASSWORD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			server, config := newMockSMTP(t)
+			config.dialTimeout = 200 * time.Millisecond
+			tt.configure(server, &config)
+			err := sendEmail(&EmailForm{To: "bob@example.com", Subject: "Hi", Body: "Hello", Confirm: true}, config)
+			if !errors.Is(err, tt.wantIs) {
+				t.Fatalf("error = %v, want %v", err, tt.wantIs)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// bodyFlagsCommand runs bodyFromFlagsOrStdin with args and stdin on a
+// command that has just the body flags.
+func bodyFlagsCommand(t *testing.T, stdin *os.File, args ...string) (string, bool, error) {
+	t.Helper()
+	var body string
+	var ok bool
+	cmd := &cli.Command{
+		Name:  "send",
+		Flags: []cli.Flag{&cli.StringFlag{Name: "body"}, &cli.StringFlag{Name: "body-file"}},
+		Action: func(ctx context.Context, c *cli.Command) error {
+			var err error
+			body, ok, err = bodyFromFlagsOrStdin(c, stdin)
+			return err
+		},
+	}
+	err := cmd.Run(context.Background(), append([]string{"send"}, args...))
+	return body, ok, err
+}
+
+// pipedStdin returns the read end of a pipe holding input.
+func pipedStdin(t *testing.T, input string) *os.File {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	go func() {
+		w.WriteString(input)
+		w.Close()
+	}()
+	return r
+}
+
+func TestBodyFromFlagsOrStdin(t *testing.T) {
+	bodyFile := filepath.Join(t.TempDir(), "body.txt")
+	if err := os.WriteFile(bodyFile, []byte("From a file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		args     []string
+		stdin    string
+		wantBody string
+		wantOK   bool
+		wantErr  string
+	}{
+		{name: "piped stdin", stdin: "Piped\n", wantBody: "Piped\n", wantOK: true},
+		{name: "empty pipe", stdin: "", wantBody: "", wantOK: true},
+		{name: "--body wins over stdin", args: []string{"--body", "Flag"}, stdin: "Piped", wantBody: "Flag", wantOK: true},
+		{name: "empty --body", args: []string{"--body", ""}, stdin: "Piped", wantBody: "", wantOK: true},
+		{name: "--body-file", args: []string{"--body-file", bodyFile}, stdin: "Piped", wantBody: "From a file\n", wantOK: true},
+		{name: "missing --body-file", args: []string{"--body-file", bodyFile + ".missing"}, wantErr: "could not read body file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, ok, err := bodyFlagsCommand(t, pipedStdin(t, tt.stdin), tt.args...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body != tt.wantBody || ok != tt.wantOK {
+				t.Errorf("got %q, %v, want %q, %v", body, ok, tt.wantBody, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestBodyFromTerminalUsesTheForm(t *testing.T) {
+	// The controlling side of a pseudo-terminal is a terminal too
+	tty, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skipf("no pseudo-terminal: %v", err)
+	}
+	defer tty.Close()
+	if !term.IsTerminal(int(tty.Fd())) {
+		t.Skip("/dev/ptmx is not a terminal here")
+	}
+
+	body, ok, err := bodyFlagsCommand(t, tty)
+	if err != nil || ok || body != "" {
+		t.Errorf("got %q, %v, %v, want the form", body, ok, err)
+	}
+	if _, ok, _ := bodyFlagsCommand(t, tty, "--body", "Flag"); !ok {
+		t.Error("--body on a terminal still opens the form")
+	}
+}
+
+func TestPipedBodyIsSent(t *testing.T) {
+	body, ok, err := bodyFlagsCommand(t, pipedStdin(t, "Sent from a script\n"))
+	if err != nil || !ok {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+	email := &EmailForm{To: "bob@example.com", Subject: "Script", Body: body}
+	if err := validateFlagEmail(email); err != nil {
+		t.Fatal(err)
+	}
+	server, _, err := sendThroughMock(t, email, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received := server.received(); len(received) != 1 || !strings.Contains(received[0].data, "Sent from a script") {
+		t.Errorf("the server got %v", received)
+	}
+}
+
+func TestReadReceipt(t *testing.T) {
+	tests := []struct {
+		name    string
+		receipt bool
+		want    bool
+	}{
+		{name: "not requested"},
+		{name: "requested", receipt: true, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := &EmailForm{To: "bob@example.com", Subject: "Important", Body: "Please confirm", ReadReceipt: tt.receipt}
+			server, _, err := sendThroughMock(t, email, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			received := server.received()
+			if len(received) != 1 {
+				t.Fatalf("%d messages sent, want 1", len(received))
+			}
+			header := received[0].parsed(t).Header
+			// Receipts go back to the sender
+			want := ""
+			if tt.want {
+				if want = header.Get("From"); want == "" {
+					t.Fatal("the message has no From")
+				}
+			}
+			for _, name := range []string{"Disposition-Notification-To", "Return-Receipt-To"} {
+				if got := header.Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestExceedsRecipientLimit(t *testing.T) {
+	tests := []struct {
+		count, limit int
+		want         bool
+	}{
+		{count: 10, limit: 10},
+		{count: 11, limit: 10, want: true},
+		{count: 1, limit: 0},
+		{count: 500, limit: -1},
+		{count: 2, limit: 1, want: true},
+	}
+	for _, tt := range tests {
+		if got := exceedsRecipientLimit(tt.count, tt.limit); got != tt.want {
+			t.Errorf("exceedsRecipientLimit(%d, %d) = %v, want %v", tt.count, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestRecipientCount(t *testing.T) {
+	tests := []struct {
+		name  string
+		email EmailForm
+		want  int
+	}{
+		{name: "none"},
+		{name: "to only", email: EmailForm{To: "a@example.com, b@example.com"}, want: 2},
+		{name: "every list", email: EmailForm{To: "a@example.com", Cc: "b@example.com, c@example.com", Bcc: "d@example.com"}, want: 4},
+		{name: "quoted comma", email: EmailForm{To: `"Doe, Jane" <jane@example.com>`}, want: 1},
+		{name: "invalid list skipped", email: EmailForm{To: "a@example.com", Cc: "not an address"}, want: 1},
+	}
+	for _, tt := range tests {
+		if got := recipientCount(&tt.email); got != tt.want {
+			t.Errorf("%s: recipientCount = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConfirmFormWarnsAboutManyRecipients(t *testing.T) {
+	many := make([]string, 11)
+	for i := range many {
+		many[i] = fmt.Sprintf("user%d@example.com", i)
+	}
+	tests := []struct {
+		name  string
+		email EmailForm
+		limit int
+		want  bool
+	}{
+		{name: "at the limit", email: EmailForm{To: strings.Join(many[:10], ", ")}, limit: 10},
+		{name: "over the limit", email: EmailForm{To: strings.Join(many[:5], ", "), Bcc: strings.Join(many[5:], ", ")}, limit: 10, want: true},
+		{name: "disabled", email: EmailForm{To: strings.Join(many, ", ")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var action string
+			form := createConfirmForm(&tt.email, "me@example.com", tt.limit, nil, &action)
+			form.Init()
+			form.Update(tea.WindowSizeMsg{Width: 100, Height: 60})
+			view := form.View()
+			if got := strings.Contains(view, "Large recipient list"); got != tt.want {
+				t.Errorf("warning shown = %v, want %v:\n%s", got, tt.want, view)
+			}
+			if tt.want && !strings.Contains(view, "11 recipients") {
+				t.Errorf("the warning does not give the count:\n%s", view)
+			}
+		})
+	}
+}
+
+func TestMaxRecipientsSetting(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		args []string
+		want int
+	}{
+		{name: "default", want: 10},
+		{name: "env", env: "25", want: 25},
+		{name: "flag", env: "25", args: []string{"--max-recipients", "0"}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setServerEnv(t)
+			t.Setenv("CLEU_MAX_RECIPIENTS", tt.env)
+			if tt.env == "" {
+				os.Unsetenv("CLEU_MAX_RECIPIENTS")
+			}
+			_, smtp := loadConfigsWith(t, smtpFlags(), tt.args...)
+			if smtp.maxRecipients != tt.want {
+				t.Errorf("maxRecipients = %d, want %d", smtp.maxRecipients, tt.want)
+			}
+		})
+	}
+}
+
+func TestYesSendsToManyRecipientsWithoutATerminal(t *testing.T) {
+	server, config := newMockSMTP(t)
+	setServerEnv(t)
+	t.Setenv("SMTP_HOST", config.host)
+	t.Setenv("SMTP_PORT", config.port)
+	t.Setenv("CLEU_SMTP_TLS", smtpTLSImplicit)
+	t.Setenv("CLEU_INSECURE", "true")
+
+	// Flags, since cli only reads environment variables on a command's first run
+	tests := []struct {
+		name     string
+		args     []string
+		wantSent bool
+	}{
+		{name: "under the limit", args: []string{"--to", "a@example.com", "--cc", "b@example.com"}, wantSent: true},
+		{name: "over the limit", args: []string{"--to", "a@example.com", "--cc", "b@example.com", "--bcc", "c@example.com"}},
+		{name: "over the limit with --yes", args: []string{"--to", "a@example.com", "--cc", "b@example.com", "--bcc", "c@example.com", "--yes"}, wantSent: true},
+		{name: "limit disabled", args: []string{"--to", "a@example.com", "--cc", "b@example.com", "--bcc", "c@example.com", "--max-recipients", "0"}, wantSent: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(server.received())
+			args := append([]string{"send", "--subject", "Hi", "--body", "Hello", "--max-recipients", "2"}, tt.args...)
+			err := Send.Run(context.Background(), args)
+			sent := len(server.received()) > before
+			if sent != tt.wantSent {
+				t.Fatalf("sent = %v, want %v (error %v)", sent, tt.wantSent, err)
+			}
+			if !tt.wantSent && (err == nil || !strings.Contains(err.Error(), "3 recipients") || !strings.Contains(err.Error(), "--yes")) {
+				t.Errorf("error = %v, want one giving the count and --yes", err)
+			}
+		})
+	}
+}
+
+func TestReplyTo(t *testing.T) {
+	tests := []struct {
+		name    string
+		replyTo string
+		want    string
+		wantErr bool
+	}{
+		{name: "empty"},
+		{name: "blank", replyTo: "  "},
+		{name: "address", replyTo: "team@example.com", want: "team@example.com"},
+		{name: "several", replyTo: "a@example.com, Bob <b@example.com>", want: `a@example.com, "Bob" <b@example.com>`},
+		{name: "invalid", replyTo: "not an address", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := &EmailForm{To: "bob@example.com", Subject: "Hi", Body: "Hello", ReplyTo: tt.replyTo}
+			if err := validateFlagEmail(email); (err != nil) != tt.wantErr {
+				t.Fatalf("validateFlagEmail = %v, want an error: %v", err, tt.wantErr)
+			}
+			server, _, err := sendThroughMock(t, email, nil)
+			if tt.wantErr {
+				if err == nil || len(server.received()) != 0 {
+					t.Errorf("sent with an invalid Reply-To (error %v)", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			header := server.received()[0].parsed(t).Header
+			if _, present := header["Reply-To"]; present != (tt.want != "") {
+				t.Errorf("Reply-To present = %v, want %v", present, tt.want != "")
+			}
+			if got := header.Get("Reply-To"); got != tt.want {
+				t.Errorf("Reply-To = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
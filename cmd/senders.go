@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"hash/fnv"
+	"io"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// senderStyle returns the color a sender is shown with in the list. The same
+// address always maps to the same color, so mail from one person stands out
+// as a group.
+func senderStyle(address string) lipgloss.Style {
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(strings.TrimSpace(address))))
+	return senderStyles[h.Sum32()%uint32(len(senderStyles))]
+}
+
+// emailDelegate draws the list like the default delegate, but tells each
+// email which description style it is drawn with, so that the text after a
+// colored sender name goes back to that style instead of the terminal's.
+type emailDelegate struct {
+	list.DefaultDelegate
+}
+
+func (d emailDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	if email, ok := item.(Email); ok && email.senderColors {
+		style := d.Styles.NormalDesc
+		if m.FilterState() == list.Filtering && m.FilterValue() == "" {
+			style = d.Styles.DimmedDesc
+		} else if index == m.Index() && m.FilterState() != list.Filtering {
+			style = d.Styles.SelectedDesc
+		}
+		email.descStyle = lipgloss.NewStyle().Foreground(style.GetForeground())
+		item = email
+	}
+	d.DefaultDelegate.Render(w, m, index, item)
+}
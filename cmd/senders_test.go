@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+func TestSenderStyleIsStable(t *testing.T) {
+	tests := []struct {
+		name string
+		same []string
+	}{
+		{name: "repeated", same: []string{"alice@example.com", "alice@example.com"}},
+		{name: "case", same: []string{"alice@example.com", "Alice@Example.COM"}},
+		{name: "spaces", same: []string{"bob@example.com", "  bob@example.com\t"}},
+	}
+	for _, tt := range tests {
+		want := senderStyle(tt.same[0]).GetForeground()
+		for _, address := range tt.same[1:] {
+			if got := senderStyle(address).GetForeground(); got != want {
+				t.Errorf("%s: %q is %v, %q is %v", tt.name, tt.same[0], want, address, got)
+			}
+		}
+	}
+
+	// Across themes the palette changes but an address keeps its place in it
+	t.Cleanup(func() { applyTheme(themeAuto) })
+	index := func(address string) int {
+		for i, style := range senderStyles {
+			if style.GetForeground() == senderStyle(address).GetForeground() {
+				return i
+			}
+		}
+		return -1
+	}
+	var dark []int
+	addresses := []string{"alice@example.com", "bob@example.com", "carol@example.org", "dave@example.net", "erin@example.com"}
+	if err := applyTheme(themeDark); err != nil {
+		t.Fatal(err)
+	}
+	colors := map[lipgloss.TerminalColor]bool{}
+	for _, address := range addresses {
+		dark = append(dark, index(address))
+		colors[senderStyle(address).GetForeground()] = true
+	}
+	if len(colors) < 2 {
+		t.Errorf("%d addresses share %d color", len(addresses), len(colors))
+	}
+	if err := applyTheme(themeLight); err != nil {
+		t.Fatal(err)
+	}
+	for i, address := range addresses {
+		if got := index(address); got != dark[i] {
+			t.Errorf("%s is color %d in the light theme, %d in the dark one", address, got, dark[i])
+		}
+	}
+}
+
+func TestSenderColorsInTheDescription(t *testing.T) {
+	t.Cleanup(func() {
+		lipgloss.SetColorProfile(termenv.Ascii)
+		applyTheme(themeAuto)
+	})
+	email := Email{
+		From:        "Alice",
+		FromName:    "Alice",
+		FromAddress: "alice@example.com",
+		Seen:        true,
+		Date:        time.Now().Add(-time.Hour),
+	}
+	tests := []struct {
+		name        string
+		theme       string
+		colors      bool
+		wantEscapes bool
+	}{
+		{name: "colors", theme: themeDark, colors: true, wantEscapes: true},
+		{name: "disabled", theme: themeDark},
+		{name: "nocolor theme", theme: themeNoColor, colors: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lipgloss.SetColorProfile(termenv.TrueColor)
+			if err := applyTheme(tt.theme); err != nil {
+				t.Fatal(err)
+			}
+			email.senderColors = tt.colors
+			description := email.Description()
+			if got := strings.Contains(description, "\x1b["); got != tt.wantEscapes {
+				t.Errorf("escape codes = %v, want %v: %q", got, tt.wantEscapes, description)
+			}
+			if tt.wantEscapes && !strings.Contains(description, senderStyle("alice@example.com").Render("Alice")) {
+				t.Errorf("the sender is not in its color: %q", description)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+)
+
+// smtpSession is one authenticated SMTP connection used for several
+// messages. It connects on the first message, so dry runs never dial.
+type smtpSession struct {
+	config smtpConfig
+	client *smtp.Client
+	used   bool
+	out    io.Writer // where results are reported, stdout by default
+}
+
+func newSMTPSession(config smtpConfig) *smtpSession {
+	return &smtpSession{config: config, out: os.Stdout}
+}
+
+// send delivers message to recipients, connecting first if needed. Every
+// message after the first starts with RSET, which also clears whatever was
+// left of a failed transaction. Progress is shown unless config.quiet is set.
+func (s *smtpSession) send(recipients []string, message string) error {
+	return withStages(s.config.quiet, func(stage stageFunc) error {
+		if s.client == nil {
+			client, err := connectSMTP(s.config, stage)
+			if err != nil {
+				return err
+			}
+			s.client = client
+		} else if s.used {
+			if err := s.client.Reset(); err != nil {
+				return fmt.Errorf("failed to reset the SMTP session: %w", err)
+			}
+		}
+		s.used = true
+		stage.report("sending")
+		return transmit(s.client, s.config.username, recipients, message)
+	})
+}
+
+// close ends the session with QUIT.
+func (s *smtpSession) close() error {
+	if s.client == nil {
+		return nil
+	}
+	err := s.client.Quit()
+	s.client = nil
+	return err
+}
+
+// connectSMTP dials the SMTP server and authenticates, reporting each step
+// to stage.
+func connectSMTP(config smtpConfig, stage stageFunc) (*smtp.Client, error) {
+	stage.report(fmt.Sprintf("connecting to %s:%s", config.host, config.port))
+	smtpClient, err := dialSMTP(config)
+	if err != nil {
+		logger.Printf("SMTP: %v", err)
+		return nil, err
+	}
+	logger.Printf("SMTP: connected to %s:%s", config.host, config.port)
+	stage.report("authenticating as " + config.username)
+	auth := smtp.PlainAuth("", config.username, config.password, config.host)
+	if err := smtpClient.Auth(auth); err != nil {
+		smtpClient.Close()
+		logger.Printf("SMTP: authentication as %s failed: %v", config.username, err)
+		return nil, fmt.Errorf("%w, check SMTP_USERNAME and SMTP_PASSWORD: %v", errSMTPAuth, err)
+	}
+	logger.Printf("SMTP: authenticated as %s", config.username)
+	return smtpClient, nil
+}
+
+// rejectedRecipient is a recipient the server refused, with its reply.
+type rejectedRecipient struct {
+	Address string
+	Reason  string
+}
+
+// recipientsRejectedError reports the recipients the server refused. When
+// Accepted is not empty the message was still delivered to those.
+type recipientsRejectedError struct {
+	Accepted []string
+	Rejected []rejectedRecipient
+}
+
+func (e *recipientsRejectedError) Error() string {
+	reasons := make([]string, len(e.Rejected))
+	for i, r := range e.Rejected {
+		reasons[i] = fmt.Sprintf("%s (%s)", r.Address, r.Reason)
+	}
+	if len(e.Accepted) == 0 {
+		return "the server rejected every recipient: " + strings.Join(reasons, ", ")
+	}
+	return fmt.Sprintf("sent to %d recipient(s), but the server rejected %d: %s", len(e.Accepted), len(e.Rejected), strings.Join(reasons, ", "))
+}
+
+// transmit sends one message to recipients over an authenticated connection.
+// Recipients the server refuses are skipped and reported together in a
+// *recipientsRejectedError once the message went to the others.
+func transmit(smtpClient *smtp.Client, from string, recipients []string, message string) error {
+	if err := smtpClient.Mail(from); err != nil {
+		return fmt.Errorf("failed to set sender: %w", err)
+	}
+	rejections := &recipientsRejectedError{}
+	for _, recipient := range recipients {
+		err := smtpClient.Rcpt(recipient)
+		var reply *textproto.Error
+		switch {
+		case err == nil:
+			rejections.Accepted = append(rejections.Accepted, recipient)
+			logger.Printf("SMTP: RCPT %s accepted", recipient)
+		case errors.As(err, &reply):
+			logger.Printf("SMTP: RCPT %s rejected: %v", recipient, err)
+			rejections.Rejected = append(rejections.Rejected, rejectedRecipient{Address: recipient, Reason: fmt.Sprintf("%d %s", reply.Code, reply.Msg)})
+		default:
+			return fmt.Errorf("failed to set recipient %s: %w", recipient, err)
+		}
+	}
+	if len(rejections.Accepted) == 0 {
+		return rejections
+	}
+	dataWriter, err := smtpClient.Data()
+	if err != nil {
+		return fmt.Errorf("failed to get data writer: %w", err)
+	}
+	if _, err := dataWriter.Write([]byte(message)); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := dataWriter.Close(); err != nil {
+		return fmt.Errorf("failed to close data writer: %w", err)
+	}
+	logger.Printf("SMTP: message of %d bytes accepted for %d recipient(s)", len(message), len(rejections.Accepted))
+	if len(rejections.Rejected) > 0 {
+		return rejections
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSessionSendsSeveralMessagesOnOneConnection(t *testing.T) {
+	tests := []struct {
+		name       string
+		recipients [][]string
+		wantSent   int
+		wantErrs   int
+	}{
+		{name: "one", recipients: [][]string{{"alice@example.com"}}, wantSent: 1},
+		{name: "two", recipients: [][]string{{"alice@example.com"}, {"bob@example.com"}}, wantSent: 2},
+		{name: "after a rejection", recipients: [][]string{{"nobody@example.com"}, {"alice@example.com"}, {"bob@example.com"}}, wantSent: 2, wantErrs: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, config := newMockSMTP(t, "nobody@example.com")
+			session := newSMTPSession(config)
+			session.out = io.Discard
+			errs := 0
+			for i, recipients := range tt.recipients {
+				message := "Subject: Message " + string(rune('A'+i)) + "\r\n\r\nHello\r\n"
+				if err := session.send(recipients, message); err != nil {
+					var rejected *recipientsRejectedError
+					if !errors.As(err, &rejected) {
+						t.Fatal(err)
+					}
+					errs++
+				}
+			}
+			if err := session.close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := len(server.received()); got != tt.wantSent {
+				t.Errorf("%d messages received, want %d", got, tt.wantSent)
+			}
+			if errs != tt.wantErrs {
+				t.Errorf("%d errors, want %d", errs, tt.wantErrs)
+			}
+			connections, resets := server.counts()
+			if connections != 1 {
+				t.Errorf("%d connections, want 1", connections)
+			}
+			// Every message after the first starts with RSET
+			if want := len(tt.recipients) - 1; resets != want {
+				t.Errorf("%d RSET, want %d", resets, want)
+			}
+		})
+	}
+}
+
+func TestSessionWithoutMessagesNeverDials(t *testing.T) {
+	server, config := newMockSMTP(t)
+	session := newSMTPSession(config)
+	if err := session.close(); err != nil {
+		t.Fatal(err)
+	}
+	if connections, _ := server.counts(); connections != 0 {
+		t.Errorf("%d connections, want 0", connections)
+	}
+}
+
+func TestIndividualSendUsesOneSession(t *testing.T) {
+	email := &EmailForm{To: "alice@example.com, bob@example.com, carol@example.com", Subject: "Each", Body: "Hello", Individual: true}
+	server, _, err := sendThroughMock(t, email, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := server.received()
+	if len(received) != 3 {
+		t.Fatalf("%d messages received, want 3", len(received))
+	}
+	for _, message := range received {
+		if len(message.recipients) != 1 || !strings.Contains(message.parsed(t).Header.Get("To"), message.recipients[0]) {
+			t.Errorf("a copy for %v is addressed to %q", message.recipients, message.parsed(t).Header.Get("To"))
+		}
+	}
+	if connections, resets := server.counts(); connections != 1 || resets != 2 {
+		t.Errorf("%d connections and %d RSET, want 1 and 2", connections, resets)
+	}
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// signatureDelimiter separates the body from the signature, as mail readers
+// expect (RFC 3676).
+const signatureDelimiter = "-- \n"
+
+// loadSignature returns the signature to append to emails sent from from:
+// --signature, --signature-file, or the account's file in the signatures
+// config directory (for example ~/.config/cleu/signatures/me@example.com).
+func loadSignature(c *cli.Command, from string) (string, error) {
+	if c.IsSet("signature") {
+		return strings.TrimSpace(c.String("signature")), nil
+	}
+	path := c.String("signature-file")
+	if path == "" {
+		dir, err := configSubdir("signatures")
+		if err != nil {
+			return "", err
+		}
+		address := from
+		if parsed, err := mail.ParseAddress(from); err == nil {
+			address = parsed.Address
+		}
+		path = filepath.Join(dir, address)
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("could not read signature: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// appendSignature adds signature below body after the standard delimiter.
+func appendSignature(body, signature string) string {
+	if signature == "" {
+		return body
+	}
+	return strings.TrimRight(body, "\r\n") + "\n\n" + signatureDelimiter + signature + "\n"
+}
+
+// htmlSignature renders signature for the HTML part, keeping its line breaks
+// instead of running it through markdown.
+func htmlSignature(signature string) string {
+	if signature == "" {
+		return ""
+	}
+	lines := strings.Split(html.EscapeString(signature), "\n")
+	return "<div class=\"signature\">-- <br>\n" + strings.Join(lines, "<br>\n") + "\n</div>\n"
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAppendSignature(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+		want      string
+	}{
+		{name: "no signature", body: "Hello\n", want: "Hello\n"},
+		{name: "after the body", body: "Hello", signature: "Alice", want: "Hello\n\n-- \nAlice\n"},
+		{name: "trailing newlines", body: "Hello\r\n\n\n", signature: "Alice", want: "Hello\n\n-- \nAlice\n"},
+		{name: "several lines", body: "Hello", signature: "Alice\nExample Inc", want: "Hello\n\n-- \nAlice\nExample Inc\n"},
+	}
+	for _, tt := range tests {
+		if got := appendSignature(tt.body, tt.signature); got != tt.want {
+			t.Errorf("%s: appendSignature = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHTMLSignature(t *testing.T) {
+	tests := []struct {
+		signature string
+		want      string
+	}{
+		{signature: "", want: ""},
+		{signature: "Alice", want: "<div class=\"signature\">-- <br>\nAlice\n</div>\n"},
+		{signature: "Alice <a@example.com>\n*Inc*", want: "<div class=\"signature\">-- <br>\nAlice &lt;a@example.com&gt;<br>\n*Inc*\n</div>\n"},
+	}
+	for _, tt := range tests {
+		if got := htmlSignature(tt.signature); got != tt.want {
+			t.Errorf("htmlSignature(%q) = %q, want %q", tt.signature, got, tt.want)
+		}
+	}
+}
+
+func TestLoadSignature(t *testing.T) {
+	for _, name := range []string{"CLEU_SIGNATURE", "CLEU_SIGNATURE_FILE", "FROM_EMAIL"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	file := filepath.Join(t.TempDir(), "signature.txt")
+	if err := os.WriteFile(file, []byte("From a file\n\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		args    []string
+		account string // written to the signatures directory for "me"
+		want    string
+		wantErr bool
+	}{
+		{name: "none"},
+		{name: "flag", args: []string{"--signature", "  Inline  "}, want: "Inline"},
+		{name: "env", env: map[string]string{"CLEU_SIGNATURE": "From env"}, want: "From env"},
+		{name: "file", args: []string{"--signature-file", file}, want: "From a file"},
+		{name: "account", account: "Per account\n", want: "Per account"},
+		{name: "flag over account", account: "Per account", args: []string{"--signature", "Inline"}, want: "Inline"},
+		{name: "empty flag disables", account: "Per account", args: []string{"--signature", ""}, want: ""},
+		{name: "other account", env: map[string]string{"FROM_EMAIL": "Other <other@example.com>"}, account: "Per account", want: ""},
+		{name: "missing file", args: []string{"--signature-file", filepath.Join(t.TempDir(), "missing")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setServerEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			if tt.account != "" {
+				dir, err := configSubdir("signatures")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.MkdirAll(dir, 0o700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, "me"), []byte(tt.account), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, smtp, err := loadConfigs(smtpFlags(), tt.args...)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if smtp.signature != tt.want {
+				t.Errorf("signature = %q, want %q", smtp.signature, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignatureIsSent(t *testing.T) {
+	tests := []struct {
+		name      string
+		email     EmailForm
+		wantPlain string
+		wantHTML  string
+		unwanted  string
+	}{
+		{
+			name:      "plain",
+			email:     EmailForm{To: "bob@example.com", Subject: "Hi", Body: "Hello Bob"},
+			wantPlain: "Hello Bob\r\n\r\n-- \r\nAlice\r\n",
+		},
+		{
+			name:      "html",
+			email:     EmailForm{To: "bob@example.com", Subject: "Hi", Body: "Hello *Bob*", HTML: true},
+			wantPlain: "Hello *Bob*\r\n\r\n--=20\r\nAlice",
+			wantHTML:  "<div class=3D\"signature\">-- <br>\r\nAlice",
+		},
+		{
+			name:      "opted out",
+			email:     EmailForm{To: "bob@example.com", Subject: "Hi", Body: "Hello Bob", NoSignature: true},
+			wantPlain: "Hello Bob\r\n",
+			unwanted:  "Alice",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _, err := sendThroughMock(t, &tt.email, func(config *smtpConfig) { config.signature = "Alice" })
+			if err != nil {
+				t.Fatal(err)
+			}
+			messages := server.received()
+			if len(messages) != 1 {
+				t.Fatalf("%d messages sent, want 1", len(messages))
+			}
+			data := messages[0].data
+			for _, want := range []string{tt.wantPlain, tt.wantHTML} {
+				if !strings.Contains(data, want) {
+					t.Errorf("message does not contain %q:\n%s", want, data)
+				}
+			}
+			if tt.unwanted != "" && strings.Contains(data, tt.unwanted) {
+				t.Errorf("message contains %q:\n%s", tt.unwanted, data)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"bufio"
+	"crypto/tls"
+	"net"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// mockSMTP is a minimal SMTP server over implicit TLS for tests. It accepts
+// any login unless told otherwise, refuses the recipients in reject with a
+// 550 and records every message it is given.
+type mockSMTP struct {
+	reject map[string]bool
+	// startTLS, when set, is offered to clients of a plain listener
+	startTLS *tls.Config
+	// refuseAuth makes every login fail; set it before connecting
+	refuseAuth bool
+
+	mu          sync.Mutex
+	messages    []mockMessage
+	connections int // connections accepted
+	resets      int // RSET commands received
+}
+
+// mockMessage is one DATA transaction received by mockSMTP.
+type mockMessage struct {
+	recipients []string
+	data       string
+}
+
+// newMockSMTP starts a mockSMTP for the duration of the test and returns it
+// with an smtpConfig pointing at it.
+func newMockSMTP(t *testing.T, reject ...string) (*mockSMTP, smtpConfig) {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: testCertificates()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return startMockSMTP(t, ln, nil, reject)
+}
+
+// newPlainMockSMTP is newMockSMTP on a plain listener, which offers STARTTLS
+// when startTLS is true. The smtpConfig asks for STARTTLS.
+func newPlainMockSMTP(t *testing.T, startTLS bool) (*mockSMTP, smtpConfig) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tlsConfig *tls.Config
+	if startTLS {
+		tlsConfig = &tls.Config{Certificates: testCertificates()}
+	}
+	m, config := startMockSMTP(t, ln, tlsConfig, nil)
+	config.tlsMode = smtpTLSStartTLS
+	return m, config
+}
+
+func startMockSMTP(t *testing.T, ln net.Listener, startTLS *tls.Config, reject []string) (*mockSMTP, smtpConfig) {
+	t.Cleanup(func() { ln.Close() })
+	m := &mockSMTP{reject: make(map[string]bool), startTLS: startTLS}
+	for _, address := range reject {
+		m.reject[address] = true
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go m.serve(conn)
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	config := smtpConfig{
+		host:     "127.0.0.1",
+		port:     port,
+		username: "me@example.com",
+		password: "secret",
+		from:     "me@example.com",
+		tlsMode:  smtpTLSImplicit,
+		insecure: true,
+		quiet:    true,
+	}
+	return m, config
+}
+
+// testCertificates returns a certificate for 127.0.0.1 to serve TLS with.
+func testCertificates() []tls.Certificate {
+	// httptest has a ready-made one
+	certServer := httptest.NewUnstartedServer(nil)
+	certServer.StartTLS()
+	defer certServer.Close()
+	return certServer.TLS.Certificates
+}
+
+func (m *mockSMTP) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	m.mu.Lock()
+	m.connections++
+	m.mu.Unlock()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 mock ready")
+
+	var recipients []string
+	var data strings.Builder
+	inData := false
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if inData {
+			if line == "." {
+				inData = false
+				m.mu.Lock()
+				m.messages = append(m.messages, mockMessage{recipients: recipients, data: data.String()})
+				m.mu.Unlock()
+				recipients = nil
+				data.Reset()
+				reply("250 queued")
+				continue
+			}
+			data.WriteString(line + "\r\n")
+			continue
+		}
+
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			reply("250-mock")
+			if _, secure := conn.(*tls.Conn); !secure && m.startTLS != nil {
+				reply("250-STARTTLS")
+			}
+			reply("250 AUTH PLAIN")
+		case "STARTTLS":
+			reply("220 go ahead")
+			conn = tls.Server(conn, m.startTLS)
+			r = bufio.NewReader(conn)
+		case "AUTH":
+			if m.refuseAuth {
+				reply("535 5.7.8 authentication failed")
+				continue
+			}
+			reply("235 authenticated")
+		case "MAIL", "RSET":
+			if verb == "RSET" {
+				m.mu.Lock()
+				m.resets++
+				m.mu.Unlock()
+			}
+			recipients = nil
+			reply("250 ok")
+		case "RCPT":
+			address := strings.Trim(strings.TrimPrefix(line[len("RCPT TO:"):], " "), "<>")
+			if m.reject[address] {
+				reply("550 5.1.1 no such user")
+				continue
+			}
+			recipients = append(recipients, address)
+			reply("250 ok")
+		case "DATA":
+			inData = true
+			reply("354 go ahead")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+// received returns the messages the server got so far.
+func (m *mockSMTP) received() []mockMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]mockMessage(nil), m.messages...)
+}
+
+// counts returns how many connections and RSET commands the server got.
+func (m *mockSMTP) counts() (connections, resets int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.connections, m.resets
+}
+
+// copiesFor counts the messages delivered to address.
+func (m *mockSMTP) copiesFor(address string) int {
+	count := 0
+	for _, message := range m.received() {
+		for _, recipient := range message.recipients {
+			if recipient == address {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// sendThroughMock sends email to a new mockSMTP, after configure adjusts the
+// settings when it is not nil, and returns the server with what sending
+// printed.
+func sendThroughMock(t *testing.T, email *EmailForm, configure func(*smtpConfig), reject ...string) (*mockSMTP, string, error) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	server, config := newMockSMTP(t, reject...)
+	if configure != nil {
+		configure(&config)
+	}
+	session := newSMTPSession(config)
+	var out strings.Builder
+	session.out = &out
+	defer session.close()
+	email.Confirm = true
+	err := session.sendEmail(email)
+	return server, out.String(), err
+}
+
+// parsed returns the message as net/mail reads it.
+func (m mockMessage) parsed(t *testing.T) *mail.Message {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(m.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// renderStatusBar shows the account, mailbox, message count and connection
+// state on the line above the help text.
+func (a *App) renderStatusBar() string {
+	var state string
+	switch {
+	case a.reconnecting:
+		state = statusBusyStyle.Render("● reconnecting")
+	case a.client == nil && a.syncing:
+		state = statusBusyStyle.Render("● connecting")
+	case a.client == nil || isClosed(a.client):
+		state = statusOfflineStyle.Render("● offline")
+	default:
+		state = statusOnlineStyle.Render("● connected")
+	}
+
+	parts := []string{
+		state,
+		a.config.username + "@" + a.config.host,
+		a.config.inbox,
+		fmt.Sprintf("%d messages", a.totalMessages),
+	}
+	if len(a.emails) > 0 && uint32(len(a.emails)) < a.totalMessages {
+		parts[3] += fmt.Sprintf(" (%d loaded)", len(a.emails))
+	}
+	if marked := len(a.marked); marked > 0 {
+		parts = append(parts, fmt.Sprintf("%d selected", marked))
+	}
+	if a.options.readOnly {
+		parts = append(parts, "read-only")
+	}
+	return statusBarStyle.Render(truncate(strings.Join(parts, " • "), max(a.width-2, 1)))
+}
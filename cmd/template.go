@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// emailTemplate is a reusable email read from the templates config
+// directory. Its header fields and body may use text/template placeholders
+// such as {{.name}}, filled from --var name=value.
+type emailTemplate struct {
+	fields map[string]string
+	body   string
+}
+
+// templateFields are the front-matter keys a template may set.
+var templateFields = []string{"to", "cc", "bcc", "subject", "priority"}
+
+// loadEmailTemplate reads ~/.config/cleu/templates/<name>.tmpl. The file
+// starts with a front matter block of "key: value" lines between "---"
+// lines, followed by the body.
+func loadEmailTemplate(name string) (emailTemplate, error) {
+	t := emailTemplate{fields: make(map[string]string)}
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return t, fmt.Errorf("invalid template name %q", name)
+	}
+	dir, err := configSubdir("templates")
+	if err != nil {
+		return t, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name+".tmpl"))
+	if errors.Is(err, os.ErrNotExist) {
+		return t, fmt.Errorf("template %q not found in %s", name, dir)
+	}
+	if err != nil {
+		return t, fmt.Errorf("could not read template: %w", err)
+	}
+
+	content := strings.ReplaceAll(string(data), "\r\n", "\n")
+	rest, ok := strings.CutPrefix(content, "---\n")
+	if !ok {
+		t.body = content
+		return t, nil
+	}
+	frontMatter, body, ok := strings.Cut(rest, "\n---\n")
+	if !ok {
+		return t, fmt.Errorf("template %q: front matter is not closed with ---", name)
+	}
+	t.body = body
+
+	scanner := bufio.NewScanner(strings.NewReader(frontMatter))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(text, ":")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok || !isTemplateField(key) {
+			return t, fmt.Errorf("template %q: line %d: expected one of %s followed by a colon", name, line+1, strings.Join(templateFields, ", "))
+		}
+		t.fields[key] = strings.TrimSpace(value)
+	}
+	return t, nil
+}
+
+func isTemplateField(key string) bool {
+	for _, field := range templateFields {
+		if key == field {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTemplateVars turns name=value pairs into template data.
+func parseTemplateVars(pairs []string) (map[string]string, error) {
+	vars := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid --var %q, expected name=value", pair)
+		}
+		vars[strings.TrimSpace(name)] = value
+	}
+	return vars, nil
+}
+
+var missingTemplateKey = regexp.MustCompile(`map has no entry for key "([^"]*)"`)
+
+// renderTemplateText fills the placeholders of the named template's text,
+// failing on any variable that was not given.
+func renderTemplateText(name, text string, vars map[string]string) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("could not parse template %q: %w", name, err)
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, vars); err != nil {
+		if m := missingTemplateKey.FindStringSubmatch(err.Error()); m != nil {
+			return "", fmt.Errorf("template %q needs the variable %q, pass it with --var %s=...", name, m[1], m[1])
+		}
+		return "", fmt.Errorf("could not render template %q: %w", name, err)
+	}
+	return out.String(), nil
+}
+
+// applyTemplate renders the named template into email. Fields already set,
+// for example from flags, are kept.
+func applyTemplate(email *EmailForm, name string, pairs []string) error {
+	t, err := loadEmailTemplate(name)
+	if err != nil {
+		return err
+	}
+	vars, err := parseTemplateVars(pairs)
+	if err != nil {
+		return err
+	}
+
+	targets := map[string]*string{
+		"to":       &email.To,
+		"cc":       &email.Cc,
+		"bcc":      &email.Bcc,
+		"subject":  &email.Subject,
+		"priority": &email.Priority,
+	}
+	for _, field := range templateFields {
+		value, ok := t.fields[field]
+		if !ok || *targets[field] != "" {
+			continue
+		}
+		rendered, err := renderTemplateText(name, value, vars)
+		if err != nil {
+			return err
+		}
+		*targets[field] = rendered
+	}
+	switch email.Priority {
+	case "", "low", "normal", "high":
+	default:
+		return fmt.Errorf("template %q: priority must be low, normal or high, got %q", name, email.Priority)
+	}
+
+	if email.Body == "" {
+		body, err := renderTemplateText(name, t.body, vars)
+		if err != nil {
+			return err
+		}
+		email.Body = body
+	}
+	return nil
+}
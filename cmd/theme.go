@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/urfave/cli/v3"
+	"golang.org/x/term"
+)
+
+// Themes accepted by --theme. The auto theme keeps the dark palette and lets
+// glamour pick its style from the terminal background.
+const (
+	themeAuto    = "auto"
+	themeDark    = "dark"
+	themeLight   = "light"
+	themeNoColor = "nocolor"
+)
+
+// palette holds the colors the styles are built from.
+type palette struct {
+	muted     lipgloss.Color
+	accent    lipgloss.Color
+	danger    lipgloss.Color
+	faint     lipgloss.Color
+	frame     lipgloss.Color
+	highlight lipgloss.Color
+	date      lipgloss.Color
+	text      lipgloss.Color
+	success   lipgloss.Color
+	warning   lipgloss.Color
+	onDanger  lipgloss.Color
+	senders   []lipgloss.Color // told apart in the list, see senderStyle
+}
+
+var darkPalette = palette{
+	muted:     "241",
+	accent:    "205",
+	danger:    "196",
+	faint:     "243",
+	frame:     "238",
+	highlight: "220",
+	date:      "242",
+	text:      "252",
+	success:   "46",
+	warning:   "208",
+	onDanger:  "15",
+	senders:   []lipgloss.Color{"39", "208", "42", "170", "214", "81", "204", "114"},
+}
+
+var lightPalette = palette{
+	muted:     "244",
+	accent:    "162",
+	danger:    "160",
+	faint:     "245",
+	frame:     "250",
+	highlight: "130",
+	date:      "243",
+	text:      "235",
+	success:   "28",
+	warning:   "166",
+	onDanger:  "15",
+	senders:   []lipgloss.Color{"25", "130", "28", "127", "94", "31", "161", "64"},
+}
+
+var (
+	helpStyle                  lipgloss.Style
+	statusBarStyle             lipgloss.Style
+	statusOnlineStyle          lipgloss.Style
+	statusBusyStyle            lipgloss.Style
+	statusOfflineStyle         lipgloss.Style
+	loadingStyle               lipgloss.Style
+	errorStyle                 lipgloss.Style
+	emptyStyle                 lipgloss.Style
+	helpOverlayStyle           lipgloss.Style
+	emailViewStyle             lipgloss.Style
+	subjectStyle               lipgloss.Style
+	fromStyle                  lipgloss.Style
+	dateStyle                  lipgloss.Style
+	bodyStyle                  lipgloss.Style
+	urlStyle                   lipgloss.Style
+	successStyle               lipgloss.Style
+	warningStyle               lipgloss.Style
+	dialogStyle                lipgloss.Style
+	emailInfoStyle             lipgloss.Style
+	confirmButtonStyle         lipgloss.Style
+	confirmButtonSelectedStyle lipgloss.Style
+	senderStyles               []lipgloss.Style
+
+	// markdownStyle is the glamour standard style used for email bodies,
+	// empty to detect it from the terminal.
+	markdownStyle string
+)
+
+func init() {
+	buildStyles(darkPalette)
+}
+
+// themeFlag is shared by the commands that print styled output.
+func themeFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "theme",
+		Usage:   "color theme: auto, dark, light or nocolor",
+		Value:   themeAuto,
+		Sources: cli.EnvVars("CLEU_THEME"),
+	}
+}
+
+// applyTheme switches every style to the named theme. It must be called
+// before the interface starts. The auto theme turns into nocolor when
+// NO_COLOR is set or stdout is not a terminal.
+func applyTheme(name string) error {
+	if (name == "" || name == themeAuto) && colorDisabled() {
+		name = themeNoColor
+	}
+	switch name {
+	case "", themeAuto:
+		buildStyles(darkPalette)
+		markdownStyle = ""
+	case themeDark:
+		buildStyles(darkPalette)
+		markdownStyle = "dark"
+	case themeLight:
+		buildStyles(lightPalette)
+		markdownStyle = "light"
+	case themeNoColor:
+		lipgloss.SetColorProfile(termenv.Ascii)
+		buildStyles(darkPalette)
+		markdownStyle = "notty"
+	default:
+		return fmt.Errorf("theme must be %q, %q, %q or %q, got %q", themeAuto, themeDark, themeLight, themeNoColor, name)
+	}
+	return nil
+}
+
+// colorDisabled follows the NO_COLOR convention (https://no-color.org) and
+// also turns colors off when stdout is redirected.
+func colorDisabled() bool {
+	return os.Getenv("NO_COLOR") != "" || !term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// glamourStyle returns the glamour option matching the current theme.
+func glamourStyle() glamour.TermRendererOption {
+	if markdownStyle == "" {
+		return glamour.WithAutoStyle()
+	}
+	return glamour.WithStandardStyle(markdownStyle)
+}
+
+func buildStyles(p palette) {
+	helpStyle = lipgloss.NewStyle().
+		Foreground(p.muted).
+		Padding(0, 1)
+	senderStyles = make([]lipgloss.Style, len(p.senders))
+	for i, color := range p.senders {
+		senderStyles[i] = lipgloss.NewStyle().Foreground(color)
+	}
+	statusBarStyle = lipgloss.NewStyle().
+		Foreground(p.faint).
+		Padding(0, 1)
+	statusOnlineStyle = lipgloss.NewStyle().
+		Foreground(p.success)
+	statusBusyStyle = lipgloss.NewStyle().
+		Foreground(p.warning)
+	statusOfflineStyle = lipgloss.NewStyle().
+		Foreground(p.danger)
+	loadingStyle = lipgloss.NewStyle().
+		Foreground(p.accent).
+		Bold(true).
+		Padding(1, 2)
+	errorStyle = lipgloss.NewStyle().
+		Foreground(p.danger).
+		Bold(true).
+		Padding(1, 2)
+	emptyStyle = lipgloss.NewStyle().
+		Foreground(p.faint).
+		Padding(1, 2)
+	helpOverlayStyle = lipgloss.NewStyle().
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(p.accent).
+		Padding(1, 3)
+	emailViewStyle = lipgloss.NewStyle().
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(p.frame).
+		Padding(1, 2)
+	subjectStyle = lipgloss.NewStyle().
+		Bold(true).
+		Foreground(p.accent)
+	fromStyle = lipgloss.NewStyle().
+		Bold(true).
+		Foreground(p.highlight)
+	dateStyle = lipgloss.NewStyle().
+		Foreground(p.date)
+	bodyStyle = lipgloss.NewStyle().
+		Foreground(p.text)
+	urlStyle = lipgloss.NewStyle().
+		Foreground(p.accent).
+		Underline(true)
+	successStyle = lipgloss.NewStyle().
+		Foreground(p.success).
+		Bold(true).
+		Padding(0, 1)
+	warningStyle = lipgloss.NewStyle().
+		Foreground(p.warning).
+		Bold(true)
+	dialogStyle = lipgloss.NewStyle().
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(p.warning).
+		Padding(2, 4).
+		MarginTop(2).
+		MarginLeft(4)
+	emailInfoStyle = lipgloss.NewStyle().
+		Foreground(p.text)
+	confirmButtonStyle = lipgloss.NewStyle().
+		Foreground(p.muted).
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(p.muted).
+		Padding(0, 1)
+	confirmButtonSelectedStyle = lipgloss.NewStyle().
+		Foreground(p.onDanger).
+		Background(p.danger).
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(p.danger).
+		Padding(0, 1).
+		Bold(true)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+func TestNoColorLeavesNoEscapeCodes(t *testing.T) {
+	t.Cleanup(func() {
+		lipgloss.SetColorProfile(termenv.Ascii)
+		applyTheme(themeAuto)
+	})
+	email := Email{
+		UID:      1,
+		Subject:  "Hello",
+		From:     "Alice",
+		To:       "me@example.com",
+		Date:     time.Date(2026, 1, 2, 15, 4, 0, 0, time.UTC),
+		Body:     "# Title\n\nSome **bold** text and https://example.com",
+		TextBody: "# Title\n\nSome **bold** text and https://example.com",
+	}
+
+	tests := []struct {
+		name        string
+		theme       string
+		noColor     string
+		wantEscapes bool
+	}{
+		{name: "dark theme", theme: themeDark, wantEscapes: true},
+		{name: "nocolor theme", theme: themeNoColor},
+		{name: "NO_COLOR with the auto theme", theme: themeAuto, noColor: "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", tt.noColor)
+			// As on a color terminal, so only the theme can turn colors off
+			lipgloss.SetColorProfile(termenv.TrueColor)
+			if err := applyTheme(tt.theme); err != nil {
+				t.Fatal(err)
+			}
+			for _, markdown := range []bool{false, true} {
+				out := formatEmailForView(email, 60, emailViewOptions{markdown: markdown}) +
+					subjectStyle.Render(email.Subject) + helpStyle.Render("q quit")
+				if got := strings.Contains(out, "\x1b["); got != tt.wantEscapes {
+					t.Errorf("markdown %v: escape codes = %v, want %v:\n%q", markdown, got, tt.wantEscapes, out)
+				}
+			}
+		})
+	}
+
+	if err := applyTheme("neon"); err == nil {
+		t.Error("an unknown theme was accepted")
+	}
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"io"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/emersion/go-imap"
+)
+
+// referencesSection fetches the References header alongside the envelope,
+// which already carries Message-Id and In-Reply-To.
+var referencesSection = &imap.BodySectionName{
+	Peek: true,
+	BodyPartName: imap.BodyPartName{
+		Specifier: imap.HeaderSpecifier,
+		Fields:    []string{"References"},
+	},
+}
+
+// parseReferences returns the message IDs listed in the References header
+// returned for referencesSection.
+func parseReferences(literal imap.Literal) []string {
+	if literal == nil {
+		return nil
+	}
+	header, err := io.ReadAll(literal)
+	if err != nil {
+		return nil
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(strings.TrimRight(string(header), "\r\n") + "\r\n\r\n"))
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(msg.Header.Get("References"))
+}
+
+var replyPrefix = regexp.MustCompile(`(?i)^\s*((re|fwd?|aw|sv)(\[\d+\])?\s*:\s*)+`)
+
+// normalizeSubject strips reply and forward prefixes so that replies land in
+// the same thread as the original when threading headers are missing.
+func normalizeSubject(subject string) string {
+	return strings.ToLower(strings.TrimSpace(replyPrefix.ReplaceAllString(subject, "")))
+}
+
+// groupThreads splits emails into conversations. Emails sharing a Message-Id,
+// In-Reply-To or References entry belong together. Failing that, emails are
+// grouped by subject, though never two conversations the headers already
+// tell apart, nor emails with an empty or "(No Subject)" subject. Threads
+// keep the order of their first email in emails, and each thread is sorted
+// oldest first.
+func groupThreads(emails []Email) [][]Email {
+	parent := make([]int, len(emails))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	owners := make(map[string]int)
+	join := func(i int, key string) {
+		if key == "" {
+			return
+		}
+		if j, ok := owners[key]; ok {
+			a, b := find(i), find(j)
+			if a != b {
+				parent[max(a, b)] = min(a, b)
+			}
+			return
+		}
+		owners[key] = i
+	}
+
+	for i, email := range emails {
+		join(i, email.MessageID)
+		join(i, email.InReplyTo)
+		for _, ref := range email.References {
+			join(i, ref)
+		}
+	}
+
+	// The subject only stands in for missing headers: it never merges two
+	// conversations the headers already link up on their own
+	linked := make(map[int]bool)
+	size := make(map[int]int)
+	for i := range emails {
+		size[find(i)]++
+		linked[find(i)] = size[find(i)] > 1
+	}
+	subjects := make(map[string]int)
+	for i, email := range emails {
+		subject := normalizeSubject(email.Subject)
+		if subject == "" || subject == "(no subject)" {
+			continue
+		}
+		j, ok := subjects[subject]
+		if !ok {
+			subjects[subject] = i
+			continue
+		}
+		a, b := find(i), find(j)
+		if a == b || linked[a] && linked[b] {
+			continue
+		}
+		root := min(a, b)
+		parent[max(a, b)] = root
+		linked[root] = linked[a] || linked[b]
+	}
+
+	var threads [][]Email
+	index := make(map[int]int)
+	for i, email := range emails {
+		root := find(i)
+		t, ok := index[root]
+		if !ok {
+			t = len(threads)
+			index[root] = t
+			threads = append(threads, nil)
+		}
+		threads[t] = append(threads[t], email)
+	}
+	for _, thread := range threads {
+		sort.SliceStable(thread, func(i, j int) bool {
+			return thread[i].Date.Before(thread[j].Date)
+		})
+	}
+	return threads
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestNormalizeSubject(t *testing.T) {
+	tests := []struct {
+		subject string
+		want    string
+	}{
+		{"Meeting", "meeting"},
+		{"Re: Meeting", "meeting"},
+		{"RE: Fwd: re[2]: Meeting ", "meeting"},
+		{"AW: SV: Meeting", "meeting"},
+		{"Re:", ""},
+		{"Ready: now", "ready: now"},
+	}
+	for _, tt := range tests {
+		if got := normalizeSubject(tt.subject); got != tt.want {
+			t.Errorf("normalizeSubject(%q) = %q, want %q", tt.subject, got, tt.want)
+		}
+	}
+}
+
+func TestGroupThreads(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2026, 3, 10, hour, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		name   string
+		emails []Email
+		want   [][]uint32
+	}{
+		{
+			name: "in-reply-to and references",
+			emails: []Email{
+				{UID: 3, Subject: "Re: Plan", Date: at(3), InReplyTo: "<b@x>", References: []string{"<a@x>", "<b@x>"}},
+				{UID: 1, Subject: "Plan", Date: at(1), MessageID: "<a@x>"},
+				{UID: 4, Subject: "Other", Date: at(4), MessageID: "<c@x>"},
+				{UID: 2, Subject: "Changed subject", Date: at(2), MessageID: "<b@x>", InReplyTo: "<a@x>"},
+			},
+			want: [][]uint32{{1, 2, 3}, {4}},
+		},
+		{
+			name: "subject when headers are missing",
+			emails: []Email{
+				{UID: 2, Subject: "Re: Lunch", Date: at(2)},
+				{UID: 1, Subject: "Lunch", Date: at(1)},
+			},
+			want: [][]uint32{{1, 2}},
+		},
+		{
+			name: "empty subjects stay apart",
+			emails: []Email{
+				{UID: 1, Subject: "", Date: at(1)},
+				{UID: 2, Subject: "Re:", Date: at(2)},
+				{UID: 3, Subject: "(No Subject)", Date: at(3)},
+				{UID: 4, Subject: "(No Subject)", Date: at(4)},
+			},
+			want: [][]uint32{{1}, {2}, {3}, {4}},
+		},
+		{
+			name: "headers win over a shared subject",
+			emails: []Email{
+				{UID: 1, Subject: "Weekly report", Date: at(1), MessageID: "<w1@x>"},
+				{UID: 2, Subject: "Re: Weekly report", Date: at(2), MessageID: "<w1r@x>", InReplyTo: "<w1@x>"},
+				{UID: 3, Subject: "Weekly report", Date: at(3), MessageID: "<w2@x>"},
+				{UID: 4, Subject: "Re: Weekly report", Date: at(4), MessageID: "<w2r@x>", InReplyTo: "<w2@x>"},
+			},
+			want: [][]uint32{{1, 2}, {3, 4}},
+		},
+		{
+			name: "a reply without headers joins by subject",
+			emails: []Email{
+				{UID: 1, Subject: "Trip", Date: at(1), MessageID: "<t@x>"},
+				{UID: 2, Subject: "Re: Trip", Date: at(2), InReplyTo: "<t@x>"},
+				{UID: 3, Subject: "RE: Trip", Date: at(3)},
+			},
+			want: [][]uint32{{1, 2, 3}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][]uint32
+			for _, thread := range groupThreads(tt.emails) {
+				var uids []uint32
+				for _, email := range thread {
+					uids = append(uids, email.UID)
+				}
+				got = append(got, uids)
+			}
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("groupThreads = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/urfave/cli/v3"
+)
+
+// Build details, set at build time with
+//
+//	go build -ldflags "-X github.com/alexisbcz/cleu/cmd.version=v1.2.0 -X github.com/alexisbcz/cleu/cmd.commit=$(git rev-parse HEAD) -X github.com/alexisbcz/cleu/cmd.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// When they are left empty they are read from the module and VCS information
+// the Go toolchain embeds.
+var (
+	version string
+	commit  string
+	date    string
+)
+
+var Version = &cli.Command{
+	Name:  "version",
+	Usage: "Print the version, commit and build date",
+	Action: func(ctx context.Context, c *cli.Command) error {
+		fmt.Println("cleu " + BuildVersion())
+		return nil
+	},
+}
+
+// BuildVersion describes this build, like "v1.2.0 (commit 3f9a0c2, built
+// 2026-01-02T15:04:05Z, go1.24.1)".
+func BuildVersion() string {
+	v, rev, built := version, commit, date
+	modified := false
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v == "" && info.Main.Version != "" {
+			v = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if rev == "" {
+					rev = setting.Value
+				}
+			case "vcs.time":
+				if built == "" {
+					built = setting.Value
+				}
+			case "vcs.modified":
+				modified = setting.Value == "true" && commit == ""
+			}
+		}
+	}
+	if v == "" {
+		v = "(devel)"
+	}
+
+	details := ""
+	if rev != "" {
+		if len(rev) > 7 {
+			rev = rev[:7]
+		}
+		if modified {
+			rev += "-dirty"
+		}
+		details += "commit " + rev + ", "
+	}
+	if built != "" {
+		details += "built " + built + ", "
+	}
+	return fmt.Sprintf("%s (%s%s)", v, details, runtime.Version())
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 
 	"github.com/alexisbcz/cleu/cmd"
@@ -9,14 +10,25 @@ import (
 )
 
 func main() {
-	cmd := &cli.Command{
+	// -v is taken by --verbose
+	cli.VersionFlag = &cli.BoolFlag{Name: "version", Usage: "print the version"}
+
+	app := &cli.Command{
 		Name:           "cleu",
 		Usage:          "Command-Line Emailing Utility",
-		Commands:       []*cli.Command{cmd.Read, cmd.Send},
+		Version:        cmd.BuildVersion(),
+		Commands:       []*cli.Command{cmd.Read, cmd.Send, cmd.List, cmd.Cat, cmd.Export, cmd.Drafts, cmd.FlushOutbox, cmd.Contacts, cmd.Doctor, cmd.Version},
 		DefaultCommand: "read",
+		Flags:          cmd.LogFlags(),
+		Before:         cmd.SetupLogging,
 	}
 
-	if err := cmd.Run(context.Background(), os.Args); err != nil {
-		panic(err)
+	if err := app.Run(context.Background(), os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		// 2 for settings the user has to fix, 1 for everything else
+		if cmd.IsConfigError(err) {
+			os.Exit(2)
+		}
+		os.Exit(1)
 	}
 }